@@ -1,44 +1,95 @@
 package atm
 
 import (
+	"errors"
 	"fmt"
+	"sync"
 	"sync/atomic"
+	"time"
 )
 
 type ATM struct {
+	id             string
 	bankingService *BankingService
+	host           AuthorizationHost
 	cashDispenser  *CashDispenser
 	txnCounter     int64
+	receipts       []*Receipt
+	onReceipt      func(*Receipt)
+	now            func() time.Time
+	mu             sync.Mutex
 }
 
+var atmCounter int64
+
+// NewATM 创建直接连接本地银行服务的ATM
 func NewATM(bankingService *BankingService, cashDispenser *CashDispenser) *ATM {
+	atm := NewATMWithHost(NewLocalHost(bankingService), cashDispenser)
+	atm.bankingService = bankingService
+	return atm
+}
+
+// NewATMWithHost 创建通过指定银行主机授权交易的ATM
+func NewATMWithHost(host AuthorizationHost, cashDispenser *CashDispenser) *ATM {
 	return &ATM{
-		bankingService: bankingService,
-		cashDispenser:  cashDispenser,
-		txnCounter:     0,
+		id:            fmt.Sprintf("ATM-%03d", atomic.AddInt64(&atmCounter, 1)),
+		host:          host,
+		cashDispenser: cashDispenser,
+		txnCounter:    0,
+		now:           time.Now,
 	}
 }
 
+// GetID 获取ATM编号
+func (a *ATM) GetID() string {
+	return a.id
+}
+
+// SetID 设置ATM编号（终端号，最长8个字符），风控规则据此区分不同的ATM
+func (a *ATM) SetID(id string) {
+	a.id = id
+}
+
 // AuthenticateUser 验证用户的卡和PIN
+// 返回的Card仅包含卡号和账号，不含PIN信息
 func (a *ATM) AuthenticateUser(cardNumber, pin string) (*Card, error) {
-	return a.bankingService.ValidateCard(cardNumber, pin)
+	resp, err := a.host.Authorize(a.newRequest(MTIAuthorizationRequest, ProcPINVerify, cardNumber, pin, 0))
+	if err != nil {
+		return nil, err
+	}
+	return &Card{
+		cardNumber:    cardNumber,
+		accountNumber: resp.AccountNumber,
+		status:        CardActive,
+	}, nil
 }
 
-// GetBalance 查询账户余额
-func (a *ATM) GetBalance(cardNumber, pin string) (float64, error) {
-	// 验证用户
-	card, err := a.AuthenticateUser(cardNumber, pin)
-	if err != nil {
-		return 0, err
+// ChangePIN 修改PIN
+func (a *ATM) ChangePIN(cardNumber, oldPIN, newPIN string) error {
+	if !validPINFormat(newPIN) {
+		return ErrInvalidPINFormat
 	}
+	_, err := a.host.Authorize(AuthorizationRequest{
+		MTI:            MTIAuthorizationRequest,
+		ProcessingCode: ProcPINChange,
+		PAN:            cardNumber,
+		PIN:            oldPIN,
+		NewPIN:         newPIN,
+		STAN:           a.nextSTAN(),
+		TerminalID:     a.id,
+	})
+	return err
+}
 
-	// 获取账户
-	account, err := a.bankingService.GetAccount(card.GetAccountNumber())
+// GetBalance 查询账户余额
+func (a *ATM) GetBalance(cardNumber, pin string) (float64, error) {
+	req := a.newRequest(MTIAuthorizationRequest, ProcBalanceInquiry, cardNumber, pin, 0)
+	resp, err := a.host.Authorize(req)
+	a.issueReceipt(req, TransactionBalanceInquiry, resp, err)
 	if err != nil {
 		return 0, err
 	}
-
-	return account.GetBalance(), nil
+	return resp.Balance, nil
 }
 
 // WithdrawCash 取款
@@ -48,32 +99,24 @@ func (a *ATM) WithdrawCash(cardNumber, pin string, amount float64) error {
 		return ErrInvalidAmount
 	}
 
-	// 验证用户
-	card, err := a.AuthenticateUser(cardNumber, pin)
-	if err != nil {
-		return err
-	}
-
-	// 获取账户
-	account, err := a.bankingService.GetAccount(card.GetAccountNumber())
-	if err != nil {
-		return err
-	}
-
 	// 检查ATM现金是否充足
-	if err := a.cashDispenser.DispenseCash(int(amount)); err != nil {
+	req := a.newRequest(MTIFinancialRequest, ProcWithdrawal, cardNumber, pin, amount)
+	if err := a.cashDispenser.dispense(int(amount), a.reference(req)); err != nil {
 		return err
 	}
 
-	// 创建并执行取款交易
-	transaction := NewWithdrawalTransaction(a.GenerateTransactionID(), account, amount)
-	if err := a.bankingService.ProcessTransaction(transaction); err != nil {
+	// 向银行主机请求授权（包括PIN验证、风控检查和扣款）
+	resp, err := a.host.Authorize(req)
+	if err != nil {
 		// 如果交易失败，将现金退回ATM
-		a.cashDispenser.AddCash(int(amount))
-		return err
+		a.cashDispenser.accept(int(amount), a.reference(req))
+		if errors.Is(err, ErrHostTimeout) {
+			// 主机可能已扣款，发起冲正
+			a.reverse(req)
+		}
 	}
-
-	return nil
+	a.issueReceipt(req, TransactionWithdrawal, resp, err)
+	return err
 }
 
 // DepositCash 存款
@@ -83,31 +126,72 @@ func (a *ATM) DepositCash(cardNumber, pin string, amount float64) error {
 		return ErrInvalidAmount
 	}
 
-	// 验证用户
-	card, err := a.AuthenticateUser(cardNumber, pin)
-	if err != nil {
-		return err
+	// 向银行主机请求入账
+	req := a.newRequest(MTIFinancialRequest, ProcDeposit, cardNumber, pin, amount)
+	resp, err := a.host.Authorize(req)
+	if err == nil {
+		// 将现金加入ATM
+		a.cashDispenser.accept(int(amount), a.reference(req))
 	}
+	a.issueReceipt(req, TransactionDeposit, resp, err)
+	return err
+}
 
-	// 获取账户
-	account, err := a.bankingService.GetAccount(card.GetAccountNumber())
-	if err != nil {
-		return err
-	}
+// GenerateTransactionID 生成唯一的交易ID
+func (a *ATM) GenerateTransactionID() string {
+	return fmt.Sprintf("TXN-%d", atomic.AddInt64(&a.txnCounter, 1))
+}
 
-	// 创建并执行存款交易
-	transaction := NewDepositTransaction(a.GenerateTransactionID(), account, amount)
-	if err := a.bankingService.ProcessTransaction(transaction); err != nil {
-		return err
+// nextSTAN 生成6位系统跟踪号
+func (a *ATM) nextSTAN() string {
+	return fmt.Sprintf("%06d", atomic.AddInt64(&a.txnCounter, 1)%1000000)
+}
+
+func (a *ATM) newRequest(mti string, code ProcessingCode, cardNumber, pin string, amount float64) AuthorizationRequest {
+	return AuthorizationRequest{
+		MTI:            mti,
+		ProcessingCode: code,
+		PAN:            cardNumber,
+		PIN:            pin,
+		Amount:         amount,
+		STAN:           a.nextSTAN(),
+		TerminalID:     a.id,
 	}
+}
 
-	// 将现金加入ATM
-	a.cashDispenser.AddCash(int(amount))
+// reference 与主机端一致的交易ID（终端号:流水号），用于对账
+func (a *ATM) reference(req AuthorizationRequest) string {
+	return req.TerminalID + ":" + req.STAN
+}
 
-	return nil
+// SetReceiptHandler 设置凭条回调，每生成一张凭条调用一次（如打印）
+func (a *ATM) SetReceiptHandler(handler func(*Receipt)) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.onReceipt = handler
 }
 
-// GenerateTransactionID 生成唯一的交易ID
-func (a *ATM) GenerateTransactionID() string {
-	return fmt.Sprintf("TXN-%d", atomic.AddInt64(&a.txnCounter, 1))
+// GetReceipts 返回本机生成的所有凭条
+func (a *ATM) GetReceipts() []*Receipt {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]*Receipt(nil), a.receipts...)
+}
+
+func (a *ATM) issueReceipt(req AuthorizationRequest, txnType TransactionType, resp *AuthorizationResponse, err error) {
+	receipt := newReceipt(req, txnType, resp, err, a.now())
+	a.mu.Lock()
+	a.receipts = append(a.receipts, receipt)
+	handler := a.onReceipt
+	a.mu.Unlock()
+	if handler != nil {
+		handler(receipt)
+	}
+}
+
+// reverse 尽力发送冲正报文，失败时由主机侧对账处理
+func (a *ATM) reverse(req AuthorizationRequest) {
+	req.MTI = MTIReversal
+	req.PIN = ""
+	a.host.Authorize(req)
 }
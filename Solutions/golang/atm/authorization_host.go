@@ -0,0 +1,272 @@
+package atm
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ProcessingCode 交易处理码（字段3）
+type ProcessingCode string
+
+const (
+	ProcWithdrawal     ProcessingCode = "010000"
+	ProcDeposit        ProcessingCode = "210000"
+	ProcBalanceInquiry ProcessingCode = "310000"
+	ProcPINChange      ProcessingCode = "920000"
+	ProcPINVerify      ProcessingCode = "960000"
+)
+
+// AuthorizationRequest ATM发往银行主机的请求
+type AuthorizationRequest struct {
+	MTI            string
+	ProcessingCode ProcessingCode
+	PAN            string
+	PIN            string
+	NewPIN         string
+	Amount         float64
+	STAN           string
+	TerminalID     string
+}
+
+// AuthorizationResponse 银行主机的应答
+type AuthorizationResponse struct {
+	MTI           string
+	ResponseCode  string
+	STAN          string
+	AccountNumber string
+	Balance       float64
+	StandIn       bool // 主机不可达时由代授权处理
+}
+
+// AuthorizationHost 银行主机接口
+// 拒绝交易时返回的应答中带有响应码，同时返回描述原因的错误；
+// 主机不可达时返回包装 ErrHostUnavailable 或 ErrHostTimeout 的错误
+type AuthorizationHost interface {
+	Authorize(req AuthorizationRequest) (*AuthorizationResponse, error)
+}
+
+// responseMTI 请求报文类型对应的应答报文类型
+func responseMTI(mti string) string {
+	n, err := strconv.Atoi(mti)
+	if err != nil {
+		return mti
+	}
+	return fmt.Sprintf("%04d", n+10)
+}
+
+// toMinorUnits 将金额转换为以分为单位的整数
+func toMinorUnits(amount float64) int64 {
+	return int64(math.Round(amount * 100))
+}
+
+// fromMinorUnits 将以分为单位的整数转换为金额
+func fromMinorUnits(units int64) float64 {
+	return float64(units) / 100
+}
+
+// approvedWithdrawal 主机已批准的取款，用于冲正
+type approvedWithdrawal struct {
+	account *Account
+	amount  float64
+	at      time.Time
+}
+
+// appliedAdvice 已处理的通知及其应答，重发的通知直接返回原应答
+type appliedAdvice struct {
+	req  AuthorizationRequest
+	resp AuthorizationResponse
+	err  error
+	at   time.Time
+}
+
+// LocalHost 进程内的银行主机，直接调用 BankingService
+// 流水号满 999999 后回绕，已批准的取款和已处理的通知需要在对账后用 Prune 清理
+type LocalHost struct {
+	bankingService *BankingService
+	approved       map[string]approvedWithdrawal // key: 终端号:流水号
+	advices        map[string]appliedAdvice      // key: 终端号:流水号
+	mu             sync.Mutex
+	adviceMu       sync.Mutex // 串行处理通知，保证重发的通知不会重复入账
+}
+
+// NewLocalHost 创建进程内银行主机
+func NewLocalHost(bankingService *BankingService) *LocalHost {
+	return &LocalHost{
+		bankingService: bankingService,
+		approved:       make(map[string]approvedWithdrawal),
+		advices:        make(map[string]appliedAdvice),
+	}
+}
+
+// Prune 清理 before 之前批准的取款和处理的通知，在这些交易完成对账后调用
+// 清理后不能再冲正这些取款，返回清理的记录数
+func (h *LocalHost) Prune(before time.Time) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	pruned := 0
+	for key, approved := range h.approved {
+		if approved.at.Before(before) {
+			delete(h.approved, key)
+			pruned++
+		}
+	}
+	for key, advice := range h.advices {
+		if advice.at.Before(before) {
+			delete(h.advices, key)
+			pruned++
+		}
+	}
+	return pruned
+}
+
+func (h *LocalHost) Authorize(req AuthorizationRequest) (*AuthorizationResponse, error) {
+	resp := &AuthorizationResponse{
+		MTI:  responseMTI(req.MTI),
+		STAN: req.STAN,
+	}
+	err := h.process(req, resp)
+	resp.ResponseCode = ResponseCodeForError(err)
+	return resp, err
+}
+
+func (h *LocalHost) process(req AuthorizationRequest, resp *AuthorizationResponse) error {
+	switch req.MTI {
+	case MTIReversal:
+		return h.reverse(req)
+	case MTIAdvice:
+		return h.applyAdvice(req, resp)
+	case MTIAuthorizationRequest, MTIFinancialRequest:
+	default:
+		return ErrInvalidTransaction
+	}
+
+	card, err := h.bankingService.ValidateCard(req.PAN, req.PIN)
+	if err != nil {
+		return err
+	}
+	account, err := h.bankingService.GetAccount(card.GetAccountNumber())
+	if err != nil {
+		return err
+	}
+	resp.AccountNumber = account.GetAccountNumber()
+
+	switch req.ProcessingCode {
+	case ProcPINVerify:
+		return nil
+	case ProcBalanceInquiry:
+		resp.Balance = account.GetBalance()
+		return nil
+	case ProcPINChange:
+		return card.setPIN(req.NewPIN)
+	case ProcWithdrawal:
+		if err := h.withdraw(req, card, account); err != nil {
+			return err
+		}
+	case ProcDeposit:
+		if err := h.bankingService.ProcessTransaction(NewDepositTransaction(h.txnID(req), account, req.Amount)); err != nil {
+			return err
+		}
+	default:
+		return ErrInvalidTransaction
+	}
+	resp.Balance = account.GetBalance()
+	return nil
+}
+
+func (h *LocalHost) withdraw(req AuthorizationRequest, card *Card, account *Account) error {
+	if req.Amount <= 0 {
+		return ErrInvalidAmount
+	}
+	txnID := h.txnID(req)
+	err := h.bankingService.AuthorizeWithdrawal(WithdrawalRequest{
+		TransactionID: txnID,
+		CardNumber:    card.GetCardNumber(),
+		AccountNumber: account.GetAccountNumber(),
+		ATMID:         req.TerminalID,
+		Amount:        req.Amount,
+	})
+	if err != nil {
+		return err
+	}
+	if err := h.bankingService.ProcessTransaction(NewWithdrawalTransaction(txnID, account, req.Amount)); err != nil {
+		h.bankingService.ReverseWithdrawal(txnID)
+		return err
+	}
+
+	h.mu.Lock()
+	h.approved[txnID] = approvedWithdrawal{account: account, amount: req.Amount, at: h.bankingService.now()}
+	h.mu.Unlock()
+	return nil
+}
+
+// reverse 冲正已批准的取款；找不到原交易时视为已冲正
+func (h *LocalHost) reverse(req AuthorizationRequest) error {
+	txnID := h.txnID(req)
+	h.mu.Lock()
+	original, ok := h.approved[txnID]
+	delete(h.approved, txnID)
+	h.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	h.bankingService.ReverseWithdrawal(txnID)
+	return h.bankingService.ProcessTransaction(NewReversalTransaction(txnID+"-R", txnID, original.account, original.amount))
+}
+
+// applyAdvice 入账代授权期间已完成的交易，此时无法再拒绝持卡人，因此不校验PIN和风控
+// 终端未收到应答时会重发通知，同一终端号和流水号的相同通知只入账一次，重发时返回原应答
+func (h *LocalHost) applyAdvice(req AuthorizationRequest, resp *AuthorizationResponse) error {
+	h.adviceMu.Lock()
+	defer h.adviceMu.Unlock()
+
+	txnID := h.txnID(req)
+	h.mu.Lock()
+	applied, ok := h.advices[txnID]
+	h.mu.Unlock()
+	// 流水号回绕后同一编号可能是另一笔交易，内容不同时按新通知处理
+	if ok && applied.req == req {
+		*resp = applied.resp
+		return applied.err
+	}
+
+	err := h.postAdvice(req, resp)
+	resp.ResponseCode = ResponseCodeForError(err)
+	h.mu.Lock()
+	h.advices[txnID] = appliedAdvice{req: req, resp: *resp, err: err, at: h.bankingService.now()}
+	h.mu.Unlock()
+	return err
+}
+
+func (h *LocalHost) postAdvice(req AuthorizationRequest, resp *AuthorizationResponse) error {
+	card, err := h.bankingService.GetCard(req.PAN)
+	if err != nil {
+		return err
+	}
+	account, err := h.bankingService.GetAccount(card.GetAccountNumber())
+	if err != nil {
+		return err
+	}
+	resp.AccountNumber = account.GetAccountNumber()
+
+	var txn Transaction
+	switch req.ProcessingCode {
+	case ProcWithdrawal:
+		txn = NewWithdrawalTransaction(h.txnID(req), account, req.Amount)
+	case ProcDeposit:
+		txn = NewDepositTransaction(h.txnID(req), account, req.Amount)
+	default:
+		return ErrInvalidTransaction
+	}
+	if err := h.bankingService.ProcessTransaction(txn); err != nil {
+		return err
+	}
+	resp.Balance = account.GetBalance()
+	return nil
+}
+
+func (h *LocalHost) txnID(req AuthorizationRequest) string {
+	return req.TerminalID + ":" + req.STAN
+}
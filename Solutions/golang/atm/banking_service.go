@@ -1,16 +1,24 @@
 package atm
 
-import "sync"
+import (
+	"sync"
+	"time"
+)
 
 type BankingService struct {
 	accounts sync.Map // key: string, value: *Account
 	cards    sync.Map // key: string (cardNumber), value: *Card
+	risk     *RiskEngine
+	history  []TransactionRecord
+	now      func() time.Time
+	mu       sync.Mutex
 }
 
 func NewBankingService() *BankingService {
 	return &BankingService{
 		accounts: sync.Map{},
 		cards:    sync.Map{},
+		now:      time.Now,
 	}
 }
 
@@ -51,12 +59,76 @@ func (b *BankingService) ValidateCard(cardNumber, pin string) (*Card, error) {
 	if err != nil {
 		return nil, err
 	}
-	if !card.ValidatePIN(pin) {
-		return nil, ErrInvalidPIN
+	if err := card.authenticate(pin); err != nil {
+		return nil, err
 	}
 	return card, nil
 }
 
+// ChangePIN 修改卡片PIN，仅允许可用状态的卡片修改
+func (b *BankingService) ChangePIN(cardNumber, oldPIN, newPIN string) error {
+	card, err := b.ValidateCard(cardNumber, oldPIN)
+	if err != nil {
+		return err
+	}
+	return card.setPIN(newPIN)
+}
+
+// ProcessTransaction 执行交易，成功后记入交易历史
 func (b *BankingService) ProcessTransaction(transaction Transaction) error {
-	return transaction.Execute()
+	if err := transaction.Execute(); err != nil {
+		return err
+	}
+
+	details := transaction.Details()
+	record := TransactionRecord{
+		TransactionID: details.TransactionID,
+		Type:          transaction.Type(),
+		Amount:        details.Amount,
+	}
+	if details.Account != nil {
+		record.AccountNumber = details.Account.GetAccountNumber()
+	}
+	if reversal, ok := transaction.(*ReversalTransaction); ok {
+		record.OriginalID = reversal.OriginalID
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	record.Time = b.now()
+	b.history = append(b.history, record)
+	return nil
+}
+
+// GetTransactionHistory 返回 [from, to) 时间段内入账的交易
+func (b *BankingService) GetTransactionHistory(from, to time.Time) []TransactionRecord {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var result []TransactionRecord
+	for _, record := range b.history {
+		if !record.Time.Before(from) && record.Time.Before(to) {
+			result = append(result, record)
+		}
+	}
+	return result
+}
+
+// SetRiskEngine 设置风控引擎，nil表示不做风控检查
+func (b *BankingService) SetRiskEngine(engine *RiskEngine) {
+	b.risk = engine
+}
+
+// AuthorizeWithdrawal 取款前的风控检查
+func (b *BankingService) AuthorizeWithdrawal(req WithdrawalRequest) error {
+	if b.risk == nil {
+		return nil
+	}
+	return b.risk.Authorize(req)
+}
+
+// ReverseWithdrawal 撤销已通过风控但未完成的取款
+func (b *BankingService) ReverseWithdrawal(txnID string) {
+	if b.risk != nil {
+		b.risk.Reverse(txnID)
+	}
 }
@@ -1,16 +1,46 @@
 package atm
 
+import "sync"
+
+// CardStatus 银行卡状态
+type CardStatus int
+
+const (
+	CardActive CardStatus = iota
+	CardBlocked
+	CardExpired
+	CardReportedStolen
+)
+
+func (s CardStatus) String() string {
+	switch s {
+	case CardActive:
+		return "active"
+	case CardBlocked:
+		return "blocked"
+	case CardExpired:
+		return "expired"
+	case CardReportedStolen:
+		return "reported-stolen"
+	}
+	return "unknown"
+}
+
 type Card struct {
-	cardNumber    string
-	pin           string
-	accountNumber string
+	cardNumber     string
+	pin            pinHash
+	accountNumber  string
+	status         CardStatus
+	failedAttempts int
+	mu             sync.Mutex
 }
 
 func NewCard(cardNumber, pin, accountNumber string) *Card {
 	return &Card{
 		cardNumber:    cardNumber,
-		pin:           pin,
+		pin:           newPINHash(pin),
 		accountNumber: accountNumber,
+		status:        CardActive,
 	}
 }
 
@@ -18,14 +48,105 @@ func (c *Card) GetCardNumber() string {
 	return c.cardNumber
 }
 
-func (c *Card) GetPIN() string {
-	return c.pin
-}
-
 func (c *Card) GetAccountNumber() string {
 	return c.accountNumber
 }
 
+// ValidatePIN 校验PIN，不计入错误次数
 func (c *Card) ValidatePIN(inputPIN string) bool {
-	return c.pin == inputPIN
+	return c.currentPIN().matches(inputPIN)
+}
+
+func (c *Card) currentPIN() pinHash {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.pin
+}
+
+// authenticate 校验PIN并更新错误次数，连续错误达到上限后锁卡
+// 哈希在锁外计算；状态检查、比较和计数在同一次加锁中完成，并发的错误尝试不会超过上限
+func (c *Card) authenticate(inputPIN string) error {
+	for {
+		pin := c.currentPIN()
+		computed := pin.derive(inputPIN)
+
+		c.mu.Lock()
+		if !samePINHash(c.pin, pin) {
+			// 计算期间PIN已被修改，按新PIN重新计算
+			c.mu.Unlock()
+			continue
+		}
+		defer c.mu.Unlock()
+		if c.status != CardActive {
+			return statusError(c.status)
+		}
+		if !pin.equal(computed) {
+			c.failedAttempts++
+			if c.failedAttempts >= maxPINRetries {
+				c.status = CardBlocked
+			}
+			return ErrInvalidPIN
+		}
+		c.failedAttempts = 0
+		return nil
+	}
+}
+
+// ChangePIN 修改PIN，需要提供旧PIN，旧PIN错误计入错误次数
+func (c *Card) ChangePIN(oldPIN, newPIN string) error {
+	if !validPINFormat(newPIN) {
+		return ErrInvalidPINFormat
+	}
+	if err := c.authenticate(oldPIN); err != nil {
+		return err
+	}
+	return c.setPIN(newPIN)
+}
+
+// setPIN 为已通过验证的持卡人设置新PIN，新哈希在锁外计算
+func (c *Card) setPIN(newPIN string) error {
+	if !validPINFormat(newPIN) {
+		return ErrInvalidPINFormat
+	}
+	pin := newPINHash(newPIN)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pin = pin
+	return nil
+}
+
+// GetStatus 获取卡片状态
+func (c *Card) GetStatus() CardStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.status
+}
+
+// SetStatus 设置卡片状态，重新激活时清零PIN错误次数
+func (c *Card) SetStatus(status CardStatus) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.status = status
+	if status == CardActive {
+		c.failedAttempts = 0
+	}
+}
+
+// CheckStatus 卡片不可用时返回对应的错误
+func (c *Card) CheckStatus() error {
+	return statusError(c.GetStatus())
+}
+
+func statusError(status CardStatus) error {
+	switch status {
+	case CardActive:
+		return nil
+	case CardBlocked:
+		return ErrCardBlocked
+	case CardExpired:
+		return ErrCardExpired
+	case CardReportedStolen:
+		return ErrCardReportedStolen
+	}
+	return ErrCardBlocked
 }
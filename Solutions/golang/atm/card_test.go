@@ -0,0 +1,145 @@
+package atm
+
+import (
+	"bytes"
+	"os"
+	"sync"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	// 测试中降低PIN哈希的迭代次数，避免在竞态检测下过慢
+	pinHashIterations = 1000
+	os.Exit(m.Run())
+}
+
+// 测试PIN以加盐哈希形式保存
+func TestCardPINIsHashed(t *testing.T) {
+	card1 := NewCard("CARD001", "1234", "ACC001")
+	card2 := NewCard("CARD002", "1234", "ACC002")
+
+	if bytes.Contains(card1.pin.hash, []byte("1234")) {
+		t.Error("PIN哈希中不应包含明文PIN")
+	}
+	if bytes.Equal(card1.pin.salt, card2.pin.salt) || bytes.Equal(card1.pin.hash, card2.pin.hash) {
+		t.Error("相同PIN的两张卡应使用不同的盐和哈希")
+	}
+}
+
+// 测试PBKDF2实现与RFC 7914给出的测试向量一致
+func TestPBKDF2SHA256(t *testing.T) {
+	got := pbkdf2SHA256([]byte("passwd"), []byte("salt"), 1, 64)
+	want := []byte{
+		0x55, 0xac, 0x04, 0x6e, 0x56, 0xe3, 0x08, 0x9f, 0xec, 0x16, 0x91, 0xc2, 0x25, 0x44, 0xb6, 0x05,
+		0xf9, 0x41, 0x85, 0x21, 0x6d, 0xde, 0x04, 0x65, 0xe6, 0x8b, 0x9d, 0x57, 0xc2, 0x0d, 0xac, 0xbc,
+		0x49, 0xca, 0x9c, 0xcc, 0xf1, 0x79, 0xb6, 0x45, 0x99, 0x16, 0x64, 0xb3, 0x9d, 0x77, 0xef, 0x31,
+		0x7c, 0x71, 0xb8, 0x45, 0xb1, 0xe3, 0x0b, 0xd5, 0x09, 0x11, 0x20, 0x41, 0xd3, 0xa1, 0x97, 0x83,
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("PBKDF2结果错误: %x", got)
+	}
+}
+
+// 测试修改PIN
+func TestChangePIN(t *testing.T) {
+	bankingService := NewBankingService()
+	bankingService.AddAccount(NewAccount("ACC001", 1000.0))
+	bankingService.AddCard(NewCard("CARD001", "1234", "ACC001"))
+	atm := NewATM(bankingService, NewCashDispenser(10000))
+
+	if err := atm.ChangePIN("CARD001", "0000", "4321"); err != ErrInvalidPIN {
+		t.Errorf("期望错误 %v, 得到 %v", ErrInvalidPIN, err)
+	}
+	if err := atm.ChangePIN("CARD001", "1234", "12ab"); err != ErrInvalidPINFormat {
+		t.Errorf("期望错误 %v, 得到 %v", ErrInvalidPINFormat, err)
+	}
+	if err := atm.ChangePIN("CARD001", "1234", "4321"); err != nil {
+		t.Fatalf("修改PIN失败: %v", err)
+	}
+
+	if _, err := atm.GetBalance("CARD001", "1234"); err != ErrInvalidPIN {
+		t.Errorf("旧PIN应失效, 得到 %v", err)
+	}
+	if _, err := atm.GetBalance("CARD001", "4321"); err != nil {
+		t.Errorf("新PIN验证失败: %v", err)
+	}
+}
+
+// 测试ValidateCard检查卡片状态
+func TestValidateCardStatus(t *testing.T) {
+	bankingService := NewBankingService()
+	card := NewCard("CARD001", "1234", "ACC001")
+	bankingService.AddCard(card)
+
+	tests := []struct {
+		status CardStatus
+		err    error
+	}{
+		{CardBlocked, ErrCardBlocked},
+		{CardExpired, ErrCardExpired},
+		{CardReportedStolen, ErrCardReportedStolen},
+		{CardActive, nil},
+	}
+	for _, tt := range tests {
+		card.SetStatus(tt.status)
+		if _, err := bankingService.ValidateCard("CARD001", "1234"); err != tt.err {
+			t.Errorf("状态 %v: 期望错误 %v, 得到 %v", tt.status, tt.err, err)
+		}
+	}
+}
+
+// 测试连续输错PIN后锁卡
+func TestCardBlockedAfterFailedAttempts(t *testing.T) {
+	bankingService := NewBankingService()
+	card := NewCard("CARD001", "1234", "ACC001")
+	bankingService.AddCard(card)
+
+	// 中间一次成功验证会清零错误次数
+	bankingService.ValidateCard("CARD001", "0000")
+	bankingService.ValidateCard("CARD001", "0000")
+	if _, err := bankingService.ValidateCard("CARD001", "1234"); err != nil {
+		t.Fatalf("PIN验证失败: %v", err)
+	}
+
+	for i := 0; i < maxPINRetries; i++ {
+		bankingService.ValidateCard("CARD001", "0000")
+	}
+	if card.GetStatus() != CardBlocked {
+		t.Errorf("期望状态 %v, 得到 %v", CardBlocked, card.GetStatus())
+	}
+	if _, err := bankingService.ValidateCard("CARD001", "1234"); err != ErrCardBlocked {
+		t.Errorf("期望错误 %v, 得到 %v", ErrCardBlocked, err)
+	}
+	if err := bankingService.ChangePIN("CARD001", "1234", "4321"); err != ErrCardBlocked {
+		t.Errorf("锁定的卡不应允许修改PIN, 得到 %v", err)
+	}
+}
+
+// 测试并发输错PIN时错误次数不会超过上限，锁卡后正确的PIN也被拒绝
+func TestConcurrentFailedAttemptsBlockCard(t *testing.T) {
+	bankingService := NewBankingService()
+	card := NewCard("CARD001", "1234", "ACC001")
+	bankingService.AddCard(card)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	wrongPIN := 0
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := bankingService.ValidateCard("CARD001", "0000"); err == ErrInvalidPIN {
+				mu.Lock()
+				wrongPIN++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if wrongPIN != maxPINRetries {
+		t.Errorf("应只有 %d 次尝试被计入错误次数, 得到 %d", maxPINRetries, wrongPIN)
+	}
+	if _, err := bankingService.ValidateCard("CARD001", "1234"); err != ErrCardBlocked {
+		t.Errorf("期望错误 %v, 得到 %v", ErrCardBlocked, err)
+	}
+}
@@ -1,36 +1,88 @@
 package atm
 
-import "sync"
+import (
+	"sync"
+	"time"
+)
+
+// CashMovement 现金分发器中的一次现金变动
+// Reference 为对应交易的主机交易ID（终端号:流水号），加钞等非交易变动为空
+type CashMovement struct {
+	Time      time.Time `json:"time"`
+	Amount    int       `json:"amount"` // 正数表示现金进入ATM，负数表示现金离开ATM
+	Reference string    `json:"reference,omitempty"`
+}
 
 type CashDispenser struct {
 	availableCash int
+	movements     []CashMovement
+	now           func() time.Time
 	mu            sync.Mutex
 }
 
 func NewCashDispenser(availableCash int) *CashDispenser {
 	return &CashDispenser{
 		availableCash: availableCash,
+		now:           time.Now,
 	}
 }
 
 func (c *CashDispenser) DispenseCash(amount int) error {
+	return c.dispense(amount, "")
+}
+
+func (c *CashDispenser) AddCash(amount int) {
+	c.accept(amount, "")
+}
+
+func (c *CashDispenser) GetAvailableCash() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.availableCash
+}
+
+// GetMovements 返回 [from, to) 时间段内的现金变动
+func (c *CashDispenser) GetMovements(from, to time.Time) []CashMovement {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var result []CashMovement
+	for _, m := range c.movements {
+		if !m.Time.Before(from) && m.Time.Before(to) {
+			result = append(result, m)
+		}
+	}
+	return result
+}
+
+// CashAt 根据变动记录推算指定时刻的现金余额
+func (c *CashDispenser) CashAt(t time.Time) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cash := c.availableCash
+	for _, m := range c.movements {
+		if !m.Time.Before(t) {
+			cash -= m.Amount
+		}
+	}
+	return cash
+}
+
+// dispense 取出现金并记录变动
+func (c *CashDispenser) dispense(amount int, reference string) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	if amount > c.availableCash {
 		return ErrInsufficientCashInATM
 	}
 	c.availableCash -= amount
+	c.movements = append(c.movements, CashMovement{Time: c.now(), Amount: -amount, Reference: reference})
 	return nil
 }
 
-func (c *CashDispenser) AddCash(amount int) {
+// accept 放入现金并记录变动
+func (c *CashDispenser) accept(amount int, reference string) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	c.availableCash += amount
-}
-
-func (c *CashDispenser) GetAvailableCash() int {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	return c.availableCash
+	c.movements = append(c.movements, CashMovement{Time: c.now(), Amount: amount, Reference: reference})
 }
@@ -25,3 +25,7 @@ func (t *DepositTransaction) Execute() error {
 	}
 	return t.Account.Credit(t.Amount)
 }
+
+func (t *DepositTransaction) Type() TransactionType {
+	return TransactionDeposit
+}
@@ -9,4 +9,21 @@ var (
 	ErrInvalidPIN            = errors.New("invalid PIN")
 	ErrAccountNotFound       = errors.New("account not found")
 	ErrCardNotFound          = errors.New("card not found")
+	ErrInvalidPINFormat      = errors.New("PIN must be 4 to 6 digits")
+	ErrCardBlocked           = errors.New("card is blocked")
+	ErrCardExpired           = errors.New("card has expired")
+	ErrCardReportedStolen    = errors.New("card has been reported stolen")
+	ErrInvalidTransaction    = errors.New("invalid transaction")
+	ErrSystemMalfunction     = errors.New("system malfunction")
+
+	// 主机通信相关错误
+	ErrMessageFormat   = errors.New("message format error")
+	ErrHostUnavailable = errors.New("authorization host unavailable")
+	ErrHostTimeout     = errors.New("authorization host timed out")
+
+	// 风控相关错误，通常包装在 *RiskError 中返回
+	ErrTransactionLimitExceeded = errors.New("per-transaction limit exceeded")
+	ErrDailyLimitExceeded       = errors.New("daily limit exceeded")
+	ErrVelocityLimitExceeded    = errors.New("withdrawal velocity limit exceeded")
+	ErrSuspiciousLocation       = errors.New("withdrawals from too many distinct ATMs")
 )
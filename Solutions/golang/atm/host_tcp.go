@@ -0,0 +1,327 @@
+package atm
+
+import (
+	"crypto/cipher"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// TCPHostClient 通过TCP以ISO 8583报文与银行主机通信
+// 每个请求使用独立的连接，连接和读写都受超时控制
+type TCPHostClient struct {
+	addr      string
+	timeout   time.Duration
+	pinCipher cipher.Block
+}
+
+// NewTCPHostClient 创建TCP主机客户端，使用默认的PIN加密密钥
+func NewTCPHostClient(addr string, timeout time.Duration) *TCPHostClient {
+	pinCipher, _ := newPINCipher(defaultPINKey)
+	return &TCPHostClient{
+		addr:      addr,
+		timeout:   timeout,
+		pinCipher: pinCipher,
+	}
+}
+
+// SetPINKey 设置加密PIN block的3DES密钥（24字节），需与主机一致
+func (c *TCPHostClient) SetPINKey(key []byte) error {
+	pinCipher, err := newPINCipher(key)
+	if err != nil {
+		return err
+	}
+	c.pinCipher = pinCipher
+	return nil
+}
+
+func (c *TCPHostClient) Authorize(req AuthorizationRequest) (*AuthorizationResponse, error) {
+	msg, err := requestToMessage(req, c.pinCipher)
+	if err != nil {
+		return nil, err
+	}
+	data, err := msg.Pack()
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := net.DialTimeout("tcp", c.addr, c.timeout)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrHostUnavailable, err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(c.timeout))
+
+	if err := writeFrame(conn, data); err != nil {
+		// 请求可能已部分发出，结果未知
+		return nil, fmt.Errorf("%w: %v", ErrHostTimeout, err)
+	}
+	reply, err := readFrame(conn)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrHostTimeout, err)
+	}
+
+	msg, err = UnpackMessage(reply)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := responseFromMessage(msg)
+	if err != nil {
+		return nil, err
+	}
+	if resp.STAN != req.STAN {
+		return nil, fmt.Errorf("%w: STAN mismatch", ErrMessageFormat)
+	}
+	additional, _ := msg.Get(FieldAdditionalResponseData)
+	return resp, ErrorForResponseCode(resp.ResponseCode, additional)
+}
+
+// HostServer 在TCP上提供银行主机服务，将报文转交给 AuthorizationHost 处理
+type HostServer struct {
+	host      AuthorizationHost
+	listener  net.Listener
+	timeout   time.Duration
+	pinCipher cipher.Block
+	conns     map[net.Conn]struct{}
+	closed    bool
+	wg        sync.WaitGroup
+	mu        sync.Mutex
+}
+
+// NewHostServer 创建主机服务器，空闲连接30秒后断开，使用默认的PIN加密密钥
+func NewHostServer(host AuthorizationHost) *HostServer {
+	pinCipher, _ := newPINCipher(defaultPINKey)
+	return &HostServer{
+		host:      host,
+		timeout:   30 * time.Second,
+		pinCipher: pinCipher,
+		conns:     make(map[net.Conn]struct{}),
+	}
+}
+
+// SetPINKey 设置解密PIN block的3DES密钥（24字节），需在 Listen 之前调用
+func (s *HostServer) SetPINKey(key []byte) error {
+	pinCipher, err := newPINCipher(key)
+	if err != nil {
+		return err
+	}
+	s.pinCipher = pinCipher
+	return nil
+}
+
+// Listen 在指定地址上监听并在后台处理连接
+func (s *HostServer) Listen(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.listener = listener
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go s.acceptLoop(listener)
+	return nil
+}
+
+// Addr 返回监听地址
+func (s *HostServer) Addr() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.listener == nil {
+		return ""
+	}
+	return s.listener.Addr().String()
+}
+
+// Close 停止监听并断开所有连接
+func (s *HostServer) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	var err error
+	if s.listener != nil {
+		err = s.listener.Close()
+	}
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+	return err
+}
+
+func (s *HostServer) acceptLoop(listener net.Listener) {
+	defer s.wg.Done()
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			conn.Close()
+			return
+		}
+		s.conns[conn] = struct{}{}
+		s.wg.Add(1)
+		s.mu.Unlock()
+
+		go s.serveConn(conn)
+	}
+}
+
+func (s *HostServer) serveConn(conn net.Conn) {
+	defer s.wg.Done()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		conn.Close()
+	}()
+
+	for {
+		conn.SetReadDeadline(time.Now().Add(s.timeout))
+		data, err := readFrame(conn)
+		if err != nil {
+			return
+		}
+		reply, err := s.handle(data).Pack()
+		if err != nil {
+			return
+		}
+		conn.SetWriteDeadline(time.Now().Add(s.timeout))
+		if err := writeFrame(conn, reply); err != nil {
+			return
+		}
+	}
+}
+
+// handle 处理一条请求报文，格式错误时返回响应码30
+func (s *HostServer) handle(data []byte) *Message {
+	msg, err := UnpackMessage(data)
+	if err != nil {
+		reply := NewMessage(MTIFinancialResponse)
+		reply.Set(FieldResponseCode, RespFormatError)
+		return reply
+	}
+
+	req, err := requestFromMessage(msg, s.pinCipher)
+	if err != nil {
+		reply := NewMessage(responseMTI(msg.MTI))
+		if stan, ok := msg.Get(FieldSTAN); ok {
+			reply.Set(FieldSTAN, stan)
+		}
+		reply.Set(FieldResponseCode, RespFormatError)
+		return reply
+	}
+
+	resp, err := s.host.Authorize(req)
+	if resp == nil {
+		resp = &AuthorizationResponse{
+			MTI:          responseMTI(req.MTI),
+			STAN:         req.STAN,
+			ResponseCode: ResponseCodeForError(err),
+		}
+	}
+	reply := responseToMessage(resp)
+	if data := additionalDataForError(err); data != "" {
+		reply.Set(FieldAdditionalResponseData, data)
+	}
+	return reply
+}
+
+// requestToMessage 将请求编码为报文，PIN和新PIN加密为PIN block
+func requestToMessage(req AuthorizationRequest, pinCipher cipher.Block) (*Message, error) {
+	msg := NewMessage(req.MTI)
+	msg.Set(FieldPAN, req.PAN)
+	msg.Set(FieldProcessingCode, string(req.ProcessingCode))
+	msg.Set(FieldAmount, strconv.FormatInt(toMinorUnits(req.Amount), 10))
+	msg.Set(FieldSTAN, req.STAN)
+	msg.Set(FieldTerminalID, req.TerminalID)
+	pins := []struct {
+		field int
+		pin   string
+	}{
+		{FieldPINData, req.PIN},
+		{FieldNewPINData, req.NewPIN},
+	}
+	for _, p := range pins {
+		if p.pin == "" {
+			continue
+		}
+		block, err := encryptPINBlock(pinCipher, p.pin)
+		if err != nil {
+			return nil, err
+		}
+		msg.Set(p.field, block)
+	}
+	return msg, nil
+}
+
+func requestFromMessage(msg *Message, pinCipher cipher.Block) (AuthorizationRequest, error) {
+	req := AuthorizationRequest{MTI: msg.MTI}
+	var ok bool
+	if req.PAN, ok = msg.Get(FieldPAN); !ok {
+		return req, fmt.Errorf("%w: missing PAN", ErrMessageFormat)
+	}
+	if req.STAN, ok = msg.Get(FieldSTAN); !ok {
+		return req, fmt.Errorf("%w: missing STAN", ErrMessageFormat)
+	}
+	code, _ := msg.Get(FieldProcessingCode)
+	req.ProcessingCode = ProcessingCode(code)
+	if amount, ok := msg.Get(FieldAmount); ok {
+		units, err := strconv.ParseInt(amount, 10, 64)
+		if err != nil {
+			return req, fmt.Errorf("%w: invalid amount", ErrMessageFormat)
+		}
+		req.Amount = fromMinorUnits(units)
+	}
+	req.TerminalID, _ = msg.Get(FieldTerminalID)
+	for field, pin := range map[int]*string{FieldPINData: &req.PIN, FieldNewPINData: &req.NewPIN} {
+		block, ok := msg.Get(field)
+		if !ok {
+			continue
+		}
+		decrypted, err := decryptPINBlock(pinCipher, block)
+		if err != nil {
+			return req, err
+		}
+		*pin = decrypted
+	}
+	return req, nil
+}
+
+func responseToMessage(resp *AuthorizationResponse) *Message {
+	msg := NewMessage(resp.MTI)
+	msg.Set(FieldSTAN, resp.STAN)
+	msg.Set(FieldResponseCode, resp.ResponseCode)
+	if resp.AccountNumber != "" {
+		msg.Set(FieldAccountID, resp.AccountNumber)
+	}
+	if resp.ResponseCode == RespApproved {
+		msg.Set(FieldAdditionalAmounts, strconv.FormatInt(toMinorUnits(resp.Balance), 10))
+	}
+	return msg
+}
+
+func responseFromMessage(msg *Message) (*AuthorizationResponse, error) {
+	resp := &AuthorizationResponse{MTI: msg.MTI}
+	var ok bool
+	if resp.ResponseCode, ok = msg.Get(FieldResponseCode); !ok {
+		return nil, fmt.Errorf("%w: missing response code", ErrMessageFormat)
+	}
+	resp.STAN, _ = msg.Get(FieldSTAN)
+	resp.AccountNumber, _ = msg.Get(FieldAccountID)
+	if balance, ok := msg.Get(FieldAdditionalAmounts); ok {
+		units, err := strconv.ParseInt(balance, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid balance", ErrMessageFormat)
+		}
+		resp.Balance = fromMinorUnits(units)
+	}
+	return resp, nil
+}
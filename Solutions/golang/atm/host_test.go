@@ -0,0 +1,363 @@
+package atm
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestBank() *BankingService {
+	bankingService := NewBankingService()
+	bankingService.AddAccount(NewAccount("ACC001", 1000.0))
+	bankingService.AddCard(NewCard("CARD001", "1234", "ACC001"))
+	return bankingService
+}
+
+func startTestHostServer(t *testing.T, host AuthorizationHost) *HostServer {
+	server := NewHostServer(host)
+	if err := server.Listen("127.0.0.1:0"); err != nil {
+		t.Fatalf("启动主机服务失败: %v", err)
+	}
+	t.Cleanup(func() { server.Close() })
+	return server
+}
+
+// unusedAddr 返回一个当前无人监听的地址
+func unusedAddr(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+	return addr
+}
+
+// 测试报文编解码，包括次位图
+func TestMessagePackUnpack(t *testing.T) {
+	msg := NewMessage(MTIFinancialRequest)
+	msg.Set(FieldPAN, "4111111111111111")
+	msg.Set(FieldProcessingCode, string(ProcWithdrawal))
+	msg.Set(FieldAmount, "20000")
+	msg.Set(FieldSTAN, "42")
+	msg.Set(FieldTerminalID, "ATM-1")
+	msg.Set(FieldAccountID, "ACC001")
+
+	data, err := msg.Pack()
+	if err != nil {
+		t.Fatalf("编码失败: %v", err)
+	}
+	want := "0200" + "F020000000800000" + "0000000004000000" +
+		"164111111111111111" + "010000" + "000000020000" + "000042" + "ATM-1   " + "06ACC001"
+	if string(data) != want {
+		t.Errorf("编码结果错误:\n得到 %s\n期望 %s", data, want)
+	}
+
+	decoded, err := UnpackMessage(data)
+	if err != nil {
+		t.Fatalf("解码失败: %v", err)
+	}
+	for _, field := range []int{FieldPAN, FieldProcessingCode, FieldTerminalID, FieldAccountID} {
+		got, _ := decoded.Get(field)
+		expected, _ := msg.Get(field)
+		if got != expected {
+			t.Errorf("字段%d: 期望 %q, 得到 %q", field, expected, got)
+		}
+	}
+	if stan, _ := decoded.Get(FieldSTAN); stan != "000042" {
+		t.Errorf("STAN应左补0, 得到 %q", stan)
+	}
+}
+
+// 测试格式错误的报文
+func TestUnpackMessageErrors(t *testing.T) {
+	inputs := []string{
+		"",
+		"02X0" + "0000000000000000",
+		"0200" + "ZZZZZZZZZZZZZZZZ",
+		"0200" + "4000000000000000" + "20123",
+		"0200" + "2000000000000000" + "0010000000",
+	}
+	for _, input := range inputs {
+		if _, err := UnpackMessage([]byte(input)); !errors.Is(err, ErrMessageFormat) {
+			t.Errorf("%q: 期望错误 %v, 得到 %v", input, ErrMessageFormat, err)
+		}
+	}
+
+	msg := NewMessage(MTIFinancialRequest)
+	msg.Set(FieldAmount, "12a")
+	if _, err := msg.Pack(); !errors.Is(err, ErrMessageFormat) {
+		t.Errorf("非数字金额应编码失败, 得到 %v", err)
+	}
+}
+
+// 测试响应码与错误的互相转换
+func TestResponseCodes(t *testing.T) {
+	errs := []error{ErrInsufficientFunds, ErrInvalidPIN, ErrCardNotFound, ErrCardBlocked, ErrInvalidAmount}
+	for _, err := range errs {
+		if got := ErrorForResponseCode(ResponseCodeForError(err), ""); got != err {
+			t.Errorf("期望错误 %v, 得到 %v", err, got)
+		}
+	}
+	if ResponseCodeForError(nil) != RespApproved || ErrorForResponseCode(RespApproved, "") != nil {
+		t.Error("批准的响应码应为00")
+	}
+	if ResponseCodeForError(errors.New("boom")) != RespSystemMalfunction {
+		t.Error("未知错误应映射为系统故障")
+	}
+}
+
+// 测试ATM通过TCP与主机完成所有操作
+func TestATMOverTCP(t *testing.T) {
+	bankingService := newTestBank()
+	server := startTestHostServer(t, NewLocalHost(bankingService))
+	dispenser := NewCashDispenser(10000)
+	atm := NewATMWithHost(NewTCPHostClient(server.Addr(), 5*time.Second), dispenser)
+
+	if _, err := atm.AuthenticateUser("CARD001", "1234"); err != nil {
+		t.Fatalf("认证失败: %v", err)
+	}
+	if err := atm.WithdrawCash("CARD001", "1234", 200.5); err != nil {
+		t.Fatalf("取款失败: %v", err)
+	}
+	if err := atm.DepositCash("CARD001", "1234", 50); err != nil {
+		t.Fatalf("存款失败: %v", err)
+	}
+	balance, err := atm.GetBalance("CARD001", "1234")
+	if err != nil || balance != 849.5 {
+		t.Errorf("期望余额 849.50, 得到 %.2f (%v)", balance, err)
+	}
+
+	if _, err := atm.GetBalance("CARD001", "0000"); err != ErrInvalidPIN {
+		t.Errorf("期望错误 %v, 得到 %v", ErrInvalidPIN, err)
+	}
+	if err := atm.WithdrawCash("CARD001", "1234", 5000); err != ErrInsufficientFunds {
+		t.Errorf("期望错误 %v, 得到 %v", ErrInsufficientFunds, err)
+	}
+	if dispenser.GetAvailableCash() != 9850 {
+		t.Errorf("期望ATM现金 9850, 得到 %d", dispenser.GetAvailableCash())
+	}
+
+	if err := atm.ChangePIN("CARD001", "1234", "9999"); err != nil {
+		t.Fatalf("修改PIN失败: %v", err)
+	}
+	if _, err := atm.AuthenticateUser("CARD001", "9999"); err != nil {
+		t.Errorf("新PIN认证失败: %v", err)
+	}
+}
+
+// 测试风控决策通过报文传回ATM
+func TestRiskDecisionOverTCP(t *testing.T) {
+	bankingService := newTestBank()
+	bankingService.SetRiskEngine(NewRiskEngine(NewGeoVelocityRule(1, time.Hour)))
+	server := startTestHostServer(t, NewLocalHost(bankingService))
+
+	atm1 := NewATMWithHost(NewTCPHostClient(server.Addr(), 5*time.Second), NewCashDispenser(10000))
+	atm2 := NewATMWithHost(NewTCPHostClient(server.Addr(), 5*time.Second), NewCashDispenser(10000))
+	if err := atm1.WithdrawCash("CARD001", "1234", 10); err != nil {
+		t.Fatalf("取款失败: %v", err)
+	}
+
+	err := atm2.WithdrawCash("CARD001", "1234", 10)
+	var riskErr *RiskError
+	if !errors.As(err, &riskErr) {
+		t.Fatalf("期望 *RiskError, 得到 %v", err)
+	}
+	if riskErr.Decision != DecisionStepUp || riskErr.Rule != "geo-velocity" || !errors.Is(err, ErrSuspiciousLocation) {
+		t.Errorf("风控决策还原错误: %+v", riskErr)
+	}
+}
+
+// 测试主机无应答时超时并发起冲正
+func TestTCPHostClientTimeout(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	client := NewTCPHostClient(listener.Addr().String(), 50*time.Millisecond)
+	_, err = client.Authorize(AuthorizationRequest{MTI: MTIFinancialRequest, ProcessingCode: ProcWithdrawal, PAN: "CARD001", STAN: "000001"})
+	if !errors.Is(err, ErrHostTimeout) {
+		t.Errorf("期望错误 %v, 得到 %v", ErrHostTimeout, err)
+	}
+
+	client = NewTCPHostClient(unusedAddr(t), 50*time.Millisecond)
+	_, err = client.Authorize(AuthorizationRequest{MTI: MTIFinancialRequest, ProcessingCode: ProcWithdrawal, PAN: "CARD001", STAN: "000001"})
+	if !errors.Is(err, ErrHostUnavailable) {
+		t.Errorf("期望错误 %v, 得到 %v", ErrHostUnavailable, err)
+	}
+}
+
+// 测试冲正退回已扣款项
+func TestLocalHostReversal(t *testing.T) {
+	bankingService := newTestBank()
+	host := NewLocalHost(bankingService)
+	req := AuthorizationRequest{
+		MTI:            MTIFinancialRequest,
+		ProcessingCode: ProcWithdrawal,
+		PAN:            "CARD001",
+		PIN:            "1234",
+		Amount:         300,
+		STAN:           "000001",
+		TerminalID:     "ATM-1",
+	}
+	if _, err := host.Authorize(req); err != nil {
+		t.Fatalf("取款失败: %v", err)
+	}
+
+	req.MTI = MTIReversal
+	req.PIN = ""
+	for i := 0; i < 2; i++ {
+		resp, err := host.Authorize(req)
+		if err != nil || resp.MTI != MTIReversalResponse {
+			t.Fatalf("冲正失败: %v", err)
+		}
+	}
+	account, _ := bankingService.GetAccount("ACC001")
+	if account.GetBalance() != 1000.0 {
+		t.Errorf("冲正后期望余额 1000.0, 得到 %.2f", account.GetBalance())
+	}
+}
+
+// 测试主机不可达时的代授权和恢复后的补发
+func TestStandInProcessing(t *testing.T) {
+	bankingService := newTestBank()
+	addr := unusedAddr(t)
+	standIn := NewStandInHost(NewTCPHostClient(addr, 100*time.Millisecond), 300)
+	atm := NewATMWithHost(standIn, NewCashDispenser(10000))
+
+	if err := atm.WithdrawCash("CARD001", "1234", 200); err != nil {
+		t.Fatalf("代授权取款失败: %v", err)
+	}
+	if err := atm.WithdrawCash("CARD001", "1234", 200); !errors.Is(err, ErrHostUnavailable) {
+		t.Errorf("超过代授权限额应拒绝, 得到 %v", err)
+	}
+	if err := atm.DepositCash("CARD001", "1234", 50); err != nil {
+		t.Fatalf("代授权存款失败: %v", err)
+	}
+	if _, err := atm.GetBalance("CARD001", "1234"); !errors.Is(err, ErrHostUnavailable) {
+		t.Errorf("主机不可达时不能查询余额, 得到 %v", err)
+	}
+	if standIn.Pending() != 2 {
+		t.Fatalf("期望2条待补发通知, 得到 %d", standIn.Pending())
+	}
+	if err := standIn.Flush(); !errors.Is(err, ErrHostUnavailable) {
+		t.Errorf("主机仍不可达时补发应失败, 得到 %v", err)
+	}
+
+	server := NewHostServer(NewLocalHost(bankingService))
+	if err := server.Listen(addr); err != nil {
+		t.Skipf("无法在原地址上启动主机: %v", err)
+	}
+	defer server.Close()
+
+	if err := standIn.Flush(); err != nil {
+		t.Fatalf("补发失败: %v", err)
+	}
+	if standIn.Pending() != 0 || len(standIn.Exceptions()) != 0 {
+		t.Errorf("补发后不应有剩余报文")
+	}
+	balance, err := atm.GetBalance("CARD001", "1234")
+	if err != nil || balance != 850.0 {
+		t.Errorf("期望余额 850.00, 得到 %.2f (%v)", balance, err)
+	}
+}
+
+// 测试重发的通知只入账一次并返回原应答，对账后清理的记录不再用于去重
+func TestAdviceAppliedOnce(t *testing.T) {
+	bankingService := newTestBank()
+	now := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	bankingService.now = func() time.Time { return now }
+	host := NewLocalHost(bankingService)
+	advice := AuthorizationRequest{
+		MTI:            MTIAdvice,
+		ProcessingCode: ProcWithdrawal,
+		PAN:            "CARD001",
+		Amount:         200,
+		STAN:           "000001",
+		TerminalID:     "ATM-1",
+	}
+	for i := 0; i < 2; i++ {
+		resp, err := host.Authorize(advice)
+		if err != nil || resp.ResponseCode != RespApproved || resp.Balance != 800.0 {
+			t.Fatalf("第%d次通知: 期望应答余额 800.00, 得到 %+v (%v)", i+1, resp, err)
+		}
+	}
+	account, _ := bankingService.GetAccount("ACC001")
+	if account.GetBalance() != 800.0 {
+		t.Errorf("重发的通知不应重复入账, 余额 %.2f", account.GetBalance())
+	}
+
+	// 流水号回绕后内容不同的通知按新交易处理
+	wrapped := advice
+	wrapped.Amount = 100
+	if _, err := host.Authorize(wrapped); err != nil || account.GetBalance() != 700.0 {
+		t.Errorf("流水号回绕的通知应入账, 余额 %.2f (%v)", account.GetBalance(), err)
+	}
+
+	if pruned := host.Prune(now); pruned != 0 {
+		t.Errorf("不应清理对账时间之后的记录, 清理了 %d 条", pruned)
+	}
+	if pruned := host.Prune(now.Add(time.Hour)); pruned != 1 {
+		t.Errorf("期望清理1条记录, 清理了 %d 条", pruned)
+	}
+}
+
+// 测试PIN以加密的PIN block传输，密钥不一致时主机无法解出PIN
+func TestPINBlockOverTCP(t *testing.T) {
+	pinCipher, _ := newPINCipher(defaultPINKey)
+	req := AuthorizationRequest{
+		MTI:            MTIFinancialRequest,
+		ProcessingCode: ProcPINChange,
+		PAN:            "CARD001",
+		PIN:            "1234",
+		NewPIN:         "567890",
+		STAN:           "000001",
+		TerminalID:     "ATM-1",
+	}
+	msg, err := requestToMessage(req, pinCipher)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, field := range []int{FieldPINData, FieldNewPINData} {
+		if block, _ := msg.Get(field); len(block) != pinBlockLength || strings.Contains(block, "1234") || strings.Contains(block, "567890") {
+			t.Errorf("字段%d不应包含明文PIN: %q", field, block)
+		}
+	}
+	data, err := msg.Pack()
+	if err != nil {
+		t.Fatal(err)
+	}
+	unpacked, err := UnpackMessage(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := requestFromMessage(unpacked, pinCipher)
+	if err != nil || decoded.PIN != "1234" || decoded.NewPIN != "567890" {
+		t.Errorf("解密PIN block失败: %+v (%v)", decoded, err)
+	}
+
+	server := startTestHostServer(t, NewLocalHost(newTestBank()))
+	client := NewTCPHostClient(server.Addr(), 5*time.Second)
+	if err := client.SetPINKey([]byte("ANOTHER-PIN-KEY-3DES-24B")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Authorize(req); err == nil {
+		t.Error("密钥不一致时交易应被拒绝")
+	}
+	if err := client.SetPINKey([]byte("short")); err == nil {
+		t.Error("密钥长度错误时应拒绝")
+	}
+}
@@ -0,0 +1,255 @@
+package atm
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 简化的 ISO 8583 报文：
+//   MTI(4位) + 主位图(16位十六进制) [+ 次位图(16位十六进制)] + 各字段
+// 定长数字字段左补0，定长字符字段右补空格，变长字段带2位长度前缀(LLVAR)
+// 在TCP上传输时每条报文前加2字节大端长度
+
+// 报文类型
+const (
+	MTIAuthorizationRequest  = "0100"
+	MTIAuthorizationResponse = "0110"
+	MTIFinancialRequest      = "0200"
+	MTIFinancialResponse     = "0210"
+	MTIAdvice                = "0220"
+	MTIAdviceResponse        = "0230"
+	MTIReversal              = "0400"
+	MTIReversalResponse      = "0410"
+)
+
+// 字段编号
+const (
+	FieldPAN                    = 2
+	FieldProcessingCode         = 3
+	FieldAmount                 = 4
+	FieldSTAN                   = 11
+	FieldResponseCode           = 39
+	FieldTerminalID             = 41
+	FieldAdditionalResponseData = 44
+	FieldPINData                = 52
+	FieldNewPINData             = 53
+	FieldAdditionalAmounts      = 54
+	FieldAccountID              = 102
+)
+
+type fieldSpec struct {
+	maxLength int
+	lengthLen int // 0表示定长，2表示LLVAR
+	numeric   bool
+}
+
+// PIN和新PIN以加密的PIN block传输，见 pin_block.go
+var fieldSpecs = map[int]fieldSpec{
+	FieldPAN:                    {19, 2, false}, // 演示卡号含字母，不限定为数字
+	FieldProcessingCode:         {6, 0, true},
+	FieldAmount:                 {12, 0, true},
+	FieldSTAN:                   {6, 0, true},
+	FieldResponseCode:           {2, 0, false},
+	FieldTerminalID:             {8, 0, false},
+	FieldAdditionalResponseData: {25, 2, false},
+	FieldPINData:                {pinBlockLength, 0, false},
+	FieldNewPINData:             {pinBlockLength, 0, false},
+	FieldAdditionalAmounts:      {12, 0, true},
+	FieldAccountID:              {28, 2, false},
+}
+
+// Message ISO 8583 报文
+type Message struct {
+	MTI    string
+	fields map[int]string
+}
+
+// NewMessage 创建指定类型的报文
+func NewMessage(mti string) *Message {
+	return &Message{
+		MTI:    mti,
+		fields: make(map[int]string),
+	}
+}
+
+// Set 设置字段值
+func (m *Message) Set(field int, value string) {
+	m.fields[field] = value
+}
+
+// Get 获取字段值
+func (m *Message) Get(field int) (string, bool) {
+	value, ok := m.fields[field]
+	return value, ok
+}
+
+// Fields 返回已设置的字段编号（升序）
+func (m *Message) Fields() []int {
+	fields := make([]int, 0, len(m.fields))
+	for field := range m.fields {
+		fields = append(fields, field)
+	}
+	sort.Ints(fields)
+	return fields
+}
+
+// Pack 将报文编码为字节
+func (m *Message) Pack() ([]byte, error) {
+	if len(m.MTI) != 4 || !isDigits(m.MTI) {
+		return nil, fmt.Errorf("%w: invalid MTI %q", ErrMessageFormat, m.MTI)
+	}
+
+	var bitmap [2]uint64
+	var body strings.Builder
+	for _, field := range m.Fields() {
+		spec, ok := fieldSpecs[field]
+		if !ok {
+			return nil, fmt.Errorf("%w: unsupported field %d", ErrMessageFormat, field)
+		}
+		encoded, err := encodeField(field, spec, m.fields[field])
+		if err != nil {
+			return nil, err
+		}
+		bitmap[(field-1)/64] |= 1 << (63 - uint((field-1)%64))
+		body.WriteString(encoded)
+	}
+
+	var out strings.Builder
+	out.WriteString(m.MTI)
+	if bitmap[1] != 0 {
+		bitmap[0] |= 1 << 63 // 第1位表示存在次位图
+		fmt.Fprintf(&out, "%016X%016X", bitmap[0], bitmap[1])
+	} else {
+		fmt.Fprintf(&out, "%016X", bitmap[0])
+	}
+	out.WriteString(body.String())
+	return []byte(out.String()), nil
+}
+
+// UnpackMessage 从字节解码报文
+func UnpackMessage(data []byte) (*Message, error) {
+	s := string(data)
+	if len(s) < 20 {
+		return nil, fmt.Errorf("%w: message too short", ErrMessageFormat)
+	}
+	m := NewMessage(s[:4])
+	if !isDigits(m.MTI) {
+		return nil, fmt.Errorf("%w: invalid MTI %q", ErrMessageFormat, m.MTI)
+	}
+
+	var bitmap [2]uint64
+	var err error
+	if bitmap[0], err = strconv.ParseUint(s[4:20], 16, 64); err != nil {
+		return nil, fmt.Errorf("%w: invalid bitmap", ErrMessageFormat)
+	}
+	pos := 20
+	if bitmap[0]&(1<<63) != 0 {
+		if len(s) < 36 {
+			return nil, fmt.Errorf("%w: missing secondary bitmap", ErrMessageFormat)
+		}
+		if bitmap[1], err = strconv.ParseUint(s[20:36], 16, 64); err != nil {
+			return nil, fmt.Errorf("%w: invalid secondary bitmap", ErrMessageFormat)
+		}
+		pos = 36
+	}
+
+	for field := 2; field <= 128; field++ {
+		if bitmap[(field-1)/64]&(1<<(63-uint((field-1)%64))) == 0 {
+			continue
+		}
+		spec, ok := fieldSpecs[field]
+		if !ok {
+			return nil, fmt.Errorf("%w: unsupported field %d", ErrMessageFormat, field)
+		}
+		value, n, err := decodeField(field, spec, s[pos:])
+		if err != nil {
+			return nil, err
+		}
+		m.fields[field] = value
+		pos += n
+	}
+	if pos != len(s) {
+		return nil, fmt.Errorf("%w: %d trailing bytes", ErrMessageFormat, len(s)-pos)
+	}
+	return m, nil
+}
+
+func encodeField(field int, spec fieldSpec, value string) (string, error) {
+	if len(value) > spec.maxLength {
+		return "", fmt.Errorf("%w: field %d too long", ErrMessageFormat, field)
+	}
+	if spec.numeric && !isDigits(value) {
+		return "", fmt.Errorf("%w: field %d must be numeric", ErrMessageFormat, field)
+	}
+	if spec.lengthLen > 0 {
+		return fmt.Sprintf("%0*d%s", spec.lengthLen, len(value), value), nil
+	}
+	if spec.numeric {
+		return strings.Repeat("0", spec.maxLength-len(value)) + value, nil
+	}
+	return value + strings.Repeat(" ", spec.maxLength-len(value)), nil
+}
+
+func decodeField(field int, spec fieldSpec, s string) (string, int, error) {
+	length := spec.maxLength
+	if spec.lengthLen > 0 {
+		if len(s) < spec.lengthLen {
+			return "", 0, fmt.Errorf("%w: field %d truncated", ErrMessageFormat, field)
+		}
+		n, err := strconv.Atoi(s[:spec.lengthLen])
+		if err != nil || n > spec.maxLength {
+			return "", 0, fmt.Errorf("%w: field %d invalid length", ErrMessageFormat, field)
+		}
+		s = s[spec.lengthLen:]
+		length = n
+	}
+	if len(s) < length {
+		return "", 0, fmt.Errorf("%w: field %d truncated", ErrMessageFormat, field)
+	}
+	value := s[:length]
+	if spec.numeric && !isDigits(value) {
+		return "", 0, fmt.Errorf("%w: field %d must be numeric", ErrMessageFormat, field)
+	}
+	if spec.lengthLen == 0 && !spec.numeric {
+		value = strings.TrimRight(value, " ")
+	}
+	return value, spec.lengthLen + length, nil
+}
+
+func isDigits(s string) bool {
+	for _, ch := range s {
+		if ch < '0' || ch > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// writeFrame 写入带2字节长度前缀的报文
+func writeFrame(w io.Writer, data []byte) error {
+	if len(data) > 0xFFFF {
+		return fmt.Errorf("%w: message too long", ErrMessageFormat)
+	}
+	frame := make([]byte, 2+len(data))
+	binary.BigEndian.PutUint16(frame, uint16(len(data)))
+	copy(frame[2:], data)
+	_, err := w.Write(frame)
+	return err
+}
+
+// readFrame 读取带2字节长度前缀的报文
+func readFrame(r io.Reader) ([]byte, error) {
+	var header [2]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+	data := make([]byte, binary.BigEndian.Uint16(header[:]))
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
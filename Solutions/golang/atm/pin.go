@@ -0,0 +1,104 @@
+package atm
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/binary"
+)
+
+const (
+	pinSaltSize   = 16
+	pinHashSize   = 32
+	minPINLength  = 4
+	maxPINLength  = 6
+	maxPINRetries = 3
+)
+
+// pinHashIterations PBKDF2迭代次数，故意设置得较慢以抵御暴力破解
+var pinHashIterations = 100000
+
+// pinHash 加盐后的PIN哈希，不保存明文PIN
+type pinHash struct {
+	salt       []byte
+	hash       []byte
+	iterations int
+}
+
+// newPINHash 使用随机盐计算PIN哈希
+func newPINHash(pin string) pinHash {
+	salt := make([]byte, pinSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		panic("atm: failed to generate PIN salt: " + err.Error())
+	}
+	return pinHash{
+		salt:       salt,
+		hash:       pbkdf2SHA256([]byte(pin), salt, pinHashIterations, pinHashSize),
+		iterations: pinHashIterations,
+	}
+}
+
+// matches 使用常量时间比较校验PIN
+func (p pinHash) matches(pin string) bool {
+	return p.equal(p.derive(pin))
+}
+
+// derive 用相同的盐和迭代次数计算输入PIN的哈希，耗时较长，不应在持锁时调用
+func (p pinHash) derive(pin string) []byte {
+	return pbkdf2SHA256([]byte(pin), p.salt, p.iterations, len(p.hash))
+}
+
+// equal 常量时间比较 derive 的结果
+func (p pinHash) equal(computed []byte) bool {
+	return len(p.hash) > 0 && subtle.ConstantTimeCompare(computed, p.hash) == 1
+}
+
+// validPINFormat 检查PIN是否为4到6位数字
+func validPINFormat(pin string) bool {
+	if len(pin) < minPINLength || len(pin) > maxPINLength {
+		return false
+	}
+	for _, ch := range pin {
+		if ch < '0' || ch > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// pbkdf2SHA256 按 RFC 8018 实现的 PBKDF2-HMAC-SHA256
+func pbkdf2SHA256(password, salt []byte, iterations, keyLen int) []byte {
+	prf := hmac.New(sha256.New, password)
+	hashLen := prf.Size()
+	numBlocks := (keyLen + hashLen - 1) / hashLen
+
+	var buf [4]byte
+	key := make([]byte, 0, numBlocks*hashLen)
+	u := make([]byte, hashLen)
+	for block := 1; block <= numBlocks; block++ {
+		prf.Reset()
+		prf.Write(salt)
+		binary.BigEndian.PutUint32(buf[:], uint32(block))
+		prf.Write(buf[:])
+		t := prf.Sum(nil)
+		copy(u, t)
+
+		for i := 1; i < iterations; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+		key = append(key, t...)
+	}
+	return key[:keyLen]
+}
+
+// samePINHash 判断两个哈希是否为同一次设置的PIN
+func samePINHash(a, b pinHash) bool {
+	return bytes.Equal(a.salt, b.salt) && bytes.Equal(a.hash, b.hash)
+}
@@ -0,0 +1,60 @@
+package atm
+
+import (
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// PIN block：按 ISO 9564 格式1 将PIN编码为8字节，再用终端与主机共享的3DES密钥加密，
+// 报文中以16位十六进制传输，线路上不出现明文PIN。格式1: '1' + PIN长度(1位十六进制) + PIN数字 + 随机填充
+const pinBlockLength = 16 // 十六进制字符数
+
+// defaultPINKey 演示用的PIN加密密钥，终端和主机需配置相同的密钥
+var defaultPINKey = []byte("ATM-DEMO-PIN-KEY-3DES-24")
+
+// newPINCipher 根据24字节密钥创建3DES加密器
+func newPINCipher(key []byte) (cipher.Block, error) {
+	block, err := des.NewTripleDESCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid PIN key: %w", err)
+	}
+	return block, nil
+}
+
+// encryptPINBlock 将PIN编码为格式1的PIN block并加密
+func encryptPINBlock(block cipher.Block, pin string) (string, error) {
+	if pin == "" || len(pin) > pinBlockLength-2 || !isDigits(pin) {
+		return "", fmt.Errorf("%w: invalid PIN for PIN block", ErrMessageFormat)
+	}
+	fill := make([]byte, pinBlockLength/2)
+	if _, err := rand.Read(fill); err != nil {
+		return "", err
+	}
+	clear := fmt.Sprintf("1%X%s", len(pin), pin) + strings.ToUpper(hex.EncodeToString(fill))
+	plain, _ := hex.DecodeString(clear[:pinBlockLength])
+
+	encrypted := make([]byte, des.BlockSize)
+	block.Encrypt(encrypted, plain)
+	return strings.ToUpper(hex.EncodeToString(encrypted)), nil
+}
+
+// decryptPINBlock 解密PIN block并取出PIN
+func decryptPINBlock(block cipher.Block, data string) (string, error) {
+	encrypted, err := hex.DecodeString(data)
+	if err != nil || len(encrypted) != des.BlockSize {
+		return "", fmt.Errorf("%w: invalid PIN block", ErrMessageFormat)
+	}
+	plain := make([]byte, des.BlockSize)
+	block.Decrypt(plain, encrypted)
+	clear := strings.ToUpper(hex.EncodeToString(plain))
+
+	length := strings.IndexByte("0123456789ABCDEF", clear[1])
+	if clear[0] != '1' || length < 1 || 2+length > pinBlockLength || !isDigits(clear[2:2+length]) {
+		return "", fmt.Errorf("%w: invalid PIN block", ErrMessageFormat)
+	}
+	return clear[2 : 2+length], nil
+}
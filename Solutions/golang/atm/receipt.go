@@ -0,0 +1,99 @@
+package atm
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Receipt 交易凭条
+type Receipt struct {
+	ATMID        string          `json:"atmId"`
+	STAN         string          `json:"stan"`
+	Time         time.Time       `json:"time"`
+	CardNumber   string          `json:"cardNumber"` // 已掩码
+	Type         TransactionType `json:"type"`
+	Amount       float64         `json:"amount,omitempty"`
+	Balance      *float64        `json:"balance,omitempty"` // 代授权或交易失败时无余额
+	Approved     bool            `json:"approved"`
+	ResponseCode string          `json:"responseCode"`
+	Message      string          `json:"message,omitempty"`
+	StandIn      bool            `json:"standIn,omitempty"`
+}
+
+var transactionTypeNames = map[TransactionType]string{
+	TransactionWithdrawal:     "取款",
+	TransactionDeposit:        "存款",
+	TransactionReversal:       "冲正",
+	TransactionBalanceInquiry: "余额查询",
+}
+
+// Text 以文本形式打印凭条
+func (r *Receipt) Text() string {
+	var b strings.Builder
+	b.WriteString("--------------------------------\n")
+	fmt.Fprintf(&b, "ATM: %s  流水号: %s\n", r.ATMID, r.STAN)
+	fmt.Fprintf(&b, "时间: %s\n", r.Time.Format("2006-01-02 15:04:05"))
+	fmt.Fprintf(&b, "卡号: %s\n", r.CardNumber)
+	fmt.Fprintf(&b, "业务: %s\n", transactionTypeNames[r.Type])
+	if r.Amount > 0 {
+		fmt.Fprintf(&b, "金额: %.2f\n", r.Amount)
+	}
+	if r.Balance != nil {
+		fmt.Fprintf(&b, "余额: %.2f\n", *r.Balance)
+	}
+	if r.Approved {
+		if r.StandIn {
+			b.WriteString("结果: 成功（代授权）\n")
+		} else {
+			b.WriteString("结果: 成功\n")
+		}
+	} else {
+		fmt.Fprintf(&b, "结果: 失败（%s，响应码%s）\n", r.Message, r.ResponseCode)
+	}
+	b.WriteString("--------------------------------\n")
+	return b.String()
+}
+
+// JSON 以JSON形式输出凭条
+func (r *Receipt) JSON() ([]byte, error) {
+	return json.MarshalIndent(r, "", "  ")
+}
+
+// MaskCardNumber 只保留卡号后4位
+func MaskCardNumber(cardNumber string) string {
+	if len(cardNumber) <= 4 {
+		return cardNumber
+	}
+	return strings.Repeat("*", len(cardNumber)-4) + cardNumber[len(cardNumber)-4:]
+}
+
+// newReceipt 根据主机请求和应答生成凭条
+func newReceipt(req AuthorizationRequest, txnType TransactionType, resp *AuthorizationResponse, err error, now time.Time) *Receipt {
+	receipt := &Receipt{
+		ATMID:        req.TerminalID,
+		STAN:         req.STAN,
+		Time:         now,
+		CardNumber:   MaskCardNumber(req.PAN),
+		Type:         txnType,
+		Amount:       req.Amount,
+		Approved:     err == nil,
+		ResponseCode: ResponseCodeForError(err),
+	}
+	if resp != nil && resp.ResponseCode != "" {
+		receipt.ResponseCode = resp.ResponseCode
+	}
+	if err != nil {
+		receipt.Message = describeError(err)
+		return receipt
+	}
+	if resp != nil {
+		receipt.StandIn = resp.StandIn
+		if !resp.StandIn {
+			balance := resp.Balance
+			receipt.Balance = &balance
+		}
+	}
+	return receipt
+}
@@ -0,0 +1,159 @@
+package atm
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DiscrepancyKind 对账差异类型
+type DiscrepancyKind string
+
+const (
+	CashWithoutPosting DiscrepancyKind = "cash-without-posting" // 现金有变动但账户未入账
+	PostingWithoutCash DiscrepancyKind = "posting-without-cash" // 账户已入账但现金无变动
+	AmountMismatch     DiscrepancyKind = "amount-mismatch"      // 现金与入账金额不一致
+)
+
+// Discrepancy 一笔对不上的交易
+// 金额均以流出ATM为正：取款为正，存款为负
+type Discrepancy struct {
+	Reference     string          `json:"reference"`
+	Kind          DiscrepancyKind `json:"kind"`
+	CashAmount    float64         `json:"cashAmount"`
+	AccountAmount float64         `json:"accountAmount"`
+}
+
+// ReconciliationReport 日终对账报告：核对现金分发器的实物现金变动与账户端的借贷记录
+type ReconciliationReport struct {
+	ATMID          string        `json:"atmId"`
+	From           time.Time     `json:"from"`
+	To             time.Time     `json:"to"`
+	OpeningCash    int           `json:"openingCash"`
+	ClosingCash    int           `json:"closingCash"`
+	CashDispensed  int           `json:"cashDispensed"`
+	CashAccepted   int           `json:"cashAccepted"`
+	Replenished    int           `json:"replenished"` // 加钞（负数表示清机）
+	AccountDebits  float64       `json:"accountDebits"`
+	AccountCredits float64       `json:"accountCredits"`
+	Discrepancies  []Discrepancy `json:"discrepancies"`
+}
+
+// Balanced 没有差异时返回true
+func (r *ReconciliationReport) Balanced() bool {
+	return len(r.Discrepancies) == 0
+}
+
+// EndOfDayReport 生成指定日期的对账报告
+// history 为银行端该日的交易记录，如 BankingService.GetTransactionHistory 的结果
+func (a *ATM) EndOfDayReport(day time.Time, history []TransactionRecord) *ReconciliationReport {
+	from := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, day.Location())
+	return Reconcile(a.id, a.cashDispenser, history, from, from.AddDate(0, 0, 1))
+}
+
+// Reconcile 对 [from, to) 时间段内指定ATM的现金变动和银行入账逐笔核对
+func Reconcile(atmID string, dispenser *CashDispenser, history []TransactionRecord, from, to time.Time) *ReconciliationReport {
+	report := &ReconciliationReport{
+		ATMID:       atmID,
+		From:        from,
+		To:          to,
+		OpeningCash: dispenser.CashAt(from),
+		ClosingCash: dispenser.CashAt(to),
+	}
+
+	// 按交易ID汇总流出ATM的金额
+	cashOut := make(map[string]float64)
+	for _, m := range dispenser.GetMovements(from, to) {
+		if m.Reference == "" {
+			report.Replenished += m.Amount
+			continue
+		}
+		cashOut[m.Reference] -= float64(m.Amount)
+	}
+	// 取款预留现金后被拒绝会产生一出一进两笔变动，按交易轧差后不计入出钞和入钞
+	for _, amount := range cashOut {
+		if amount > 0 {
+			report.CashDispensed += int(amount)
+		} else {
+			report.CashAccepted -= int(amount)
+		}
+	}
+
+	prefix := atmID + ":"
+	accountOut := make(map[string]float64)
+	for _, record := range history {
+		ref := record.TransactionID
+		if record.Type == TransactionReversal {
+			ref = record.OriginalID
+		}
+		if !strings.HasPrefix(ref, prefix) || record.Time.Before(from) || !record.Time.Before(to) {
+			continue
+		}
+		switch record.Type {
+		case TransactionWithdrawal:
+			report.AccountDebits += record.Amount
+			accountOut[ref] += record.Amount
+		case TransactionDeposit, TransactionReversal:
+			report.AccountCredits += record.Amount
+			accountOut[ref] -= record.Amount
+		}
+	}
+
+	refs := make(map[string]bool)
+	for ref := range cashOut {
+		refs[ref] = true
+	}
+	for ref := range accountOut {
+		refs[ref] = true
+	}
+	for ref := range refs {
+		cash, account := cashOut[ref], accountOut[ref]
+		if math.Abs(cash-account) < 0.005 {
+			continue
+		}
+		kind := AmountMismatch
+		if math.Abs(account) < 0.005 {
+			kind = CashWithoutPosting
+		} else if math.Abs(cash) < 0.005 {
+			kind = PostingWithoutCash
+		}
+		report.Discrepancies = append(report.Discrepancies, Discrepancy{
+			Reference:     ref,
+			Kind:          kind,
+			CashAmount:    cash,
+			AccountAmount: account,
+		})
+	}
+	sort.Slice(report.Discrepancies, func(i, j int) bool {
+		return report.Discrepancies[i].Reference < report.Discrepancies[j].Reference
+	})
+	return report
+}
+
+// Text 以文本形式输出对账报告
+func (r *ReconciliationReport) Text() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "=== 日终对账报告 %s ===\n", r.ATMID)
+	fmt.Fprintf(&b, "时间段: %s ~ %s\n", r.From.Format("2006-01-02 15:04"), r.To.Format("2006-01-02 15:04"))
+	fmt.Fprintf(&b, "期初现金: %d\n", r.OpeningCash)
+	fmt.Fprintf(&b, "出钞: %d  入钞: %d  加钞: %d\n", r.CashDispensed, r.CashAccepted, r.Replenished)
+	fmt.Fprintf(&b, "期末现金: %d\n", r.ClosingCash)
+	fmt.Fprintf(&b, "账户借记: %.2f  账户贷记: %.2f\n", r.AccountDebits, r.AccountCredits)
+	if r.Balanced() {
+		b.WriteString("对账结果: 平衡\n")
+		return b.String()
+	}
+	fmt.Fprintf(&b, "对账结果: %d笔差异\n", len(r.Discrepancies))
+	for _, d := range r.Discrepancies {
+		fmt.Fprintf(&b, "  %s  %s  现金: %.2f  账户: %.2f\n", d.Reference, d.Kind, d.CashAmount, d.AccountAmount)
+	}
+	return b.String()
+}
+
+// JSON 以JSON形式输出对账报告
+func (r *ReconciliationReport) JSON() ([]byte, error) {
+	return json.MarshalIndent(r, "", "  ")
+}
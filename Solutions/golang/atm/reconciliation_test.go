@@ -0,0 +1,152 @@
+package atm
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 测试每笔交易生成凭条
+func TestReceipts(t *testing.T) {
+	bankingService := newTestBank()
+	atm := NewATM(bankingService, NewCashDispenser(10000))
+	atm.SetID("ATM-R01")
+	var printed []*Receipt
+	atm.SetReceiptHandler(func(r *Receipt) { printed = append(printed, r) })
+
+	atm.WithdrawCash("CARD001", "1234", 200)
+	atm.WithdrawCash("CARD001", "1234", 5000)
+	atm.DepositCash("CARD001", "1234", 50)
+	atm.GetBalance("CARD001", "1234")
+
+	receipts := atm.GetReceipts()
+	if len(receipts) != 4 || len(printed) != 4 {
+		t.Fatalf("期望4张凭条, 得到 %d/%d", len(receipts), len(printed))
+	}
+
+	withdrawal := receipts[0]
+	if !withdrawal.Approved || withdrawal.Type != TransactionWithdrawal || withdrawal.Balance == nil || *withdrawal.Balance != 800.0 {
+		t.Errorf("取款凭条错误: %+v", withdrawal)
+	}
+	text := withdrawal.Text()
+	for _, want := range []string{"ATM: ATM-R01", "卡号: ***D001", "业务: 取款", "金额: 200.00", "余额: 800.00", "结果: 成功"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("凭条中缺少 %q:\n%s", want, text)
+		}
+	}
+
+	declined := receipts[1]
+	if declined.Approved || declined.ResponseCode != RespInsufficientFunds || declined.Balance != nil {
+		t.Errorf("拒绝凭条错误: %+v", declined)
+	}
+	if !strings.Contains(declined.Text(), "结果: 失败（余额不足，响应码51）") {
+		t.Errorf("拒绝凭条文本错误:\n%s", declined.Text())
+	}
+
+	data, err := receipts[2].JSON()
+	if err != nil {
+		t.Fatalf("JSON输出失败: %v", err)
+	}
+	var decoded map[string]any
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("JSON格式错误: %v", err)
+	}
+	if decoded["type"] != "deposit" || decoded["cardNumber"] != "***D001" || decoded["balance"] != 850.0 {
+		t.Errorf("JSON凭条错误: %s", data)
+	}
+}
+
+// 测试对账平衡
+func TestEndOfDayReportBalanced(t *testing.T) {
+	bankingService := newTestBank()
+	dispenser := NewCashDispenser(10000)
+	atm := NewATM(bankingService, dispenser)
+
+	atm.WithdrawCash("CARD001", "1234", 200)
+	atm.WithdrawCash("CARD001", "1234", 5000) // 余额不足，现金退回
+	atm.DepositCash("CARD001", "1234", 300)
+	dispenser.AddCash(1000) // 加钞
+
+	now := time.Now()
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	report := atm.EndOfDayReport(now, bankingService.GetTransactionHistory(day, day.AddDate(0, 0, 1)))
+
+	if !report.Balanced() {
+		t.Errorf("期望对账平衡:\n%s", report.Text())
+	}
+	if report.OpeningCash != 10000 || report.ClosingCash != 11100 {
+		t.Errorf("期初/期末现金错误: %d/%d", report.OpeningCash, report.ClosingCash)
+	}
+	if report.CashDispensed != 200 || report.CashAccepted != 300 || report.Replenished != 1000 {
+		t.Errorf("现金变动错误: %+v", report)
+	}
+	if report.AccountDebits != 200 || report.AccountCredits != 300 {
+		t.Errorf("账户借贷错误: %.2f/%.2f", report.AccountDebits, report.AccountCredits)
+	}
+	if !strings.Contains(report.Text(), "对账结果: 平衡") {
+		t.Errorf("报告文本错误:\n%s", report.Text())
+	}
+}
+
+// 测试对账发现差异
+func TestEndOfDayReportDiscrepancies(t *testing.T) {
+	bankingService := newTestBank()
+	dispenser := NewCashDispenser(10000)
+	atm := NewATM(bankingService, dispenser)
+	atm.SetID("ATM-D01")
+
+	// 金额含分，出钞只能按整数，产生金额差异
+	atm.WithdrawCash("CARD001", "1234", 100.5)
+	// 出钞后主机无记录
+	dispenser.dispense(50, "ATM-D01:999998")
+	// 主机有入账但现金无变动
+	account, _ := bankingService.GetAccount("ACC001")
+	bankingService.ProcessTransaction(NewWithdrawalTransaction("ATM-D01:999999", account, 80))
+	// 其他ATM的交易不参与对账
+	bankingService.ProcessTransaction(NewWithdrawalTransaction("ATM-X:000001", account, 10))
+
+	now := time.Now()
+	report := Reconcile(atm.GetID(), dispenser, bankingService.GetTransactionHistory(now.Add(-time.Hour), now.Add(time.Hour)), now.Add(-time.Hour), now.Add(time.Hour))
+
+	want := map[string]DiscrepancyKind{
+		"ATM-D01:000001": AmountMismatch,
+		"ATM-D01:999998": CashWithoutPosting,
+		"ATM-D01:999999": PostingWithoutCash,
+	}
+	if len(report.Discrepancies) != len(want) {
+		t.Fatalf("期望%d笔差异, 得到:\n%s", len(want), report.Text())
+	}
+	for _, d := range report.Discrepancies {
+		if want[d.Reference] != d.Kind {
+			t.Errorf("%s: 期望 %s, 得到 %s", d.Reference, want[d.Reference], d.Kind)
+		}
+	}
+
+	data, err := report.JSON()
+	if err != nil || !strings.Contains(string(data), `"kind": "cash-without-posting"`) {
+		t.Errorf("JSON报告错误: %s (%v)", data, err)
+	}
+}
+
+// 测试冲正后对账平衡
+func TestEndOfDayReportWithReversal(t *testing.T) {
+	bankingService := newTestBank()
+	dispenser := NewCashDispenser(10000)
+	host := NewLocalHost(bankingService)
+	atm := NewATMWithHost(host, dispenser)
+	atm.SetID("ATM-V01")
+
+	req := atm.newRequest(MTIFinancialRequest, ProcWithdrawal, "CARD001", "1234", 100)
+	dispenser.dispense(100, atm.reference(req))
+	host.Authorize(req)
+	// 模拟超时：现金退回并冲正
+	dispenser.accept(100, atm.reference(req))
+	atm.reverse(req)
+
+	now := time.Now()
+	report := atm.EndOfDayReport(now, bankingService.GetTransactionHistory(now.Add(-24*time.Hour), now.Add(time.Hour)))
+	if !report.Balanced() || report.AccountDebits != 100 || report.AccountCredits != 100 {
+		t.Errorf("冲正后期望对账平衡:\n%s", report.Text())
+	}
+}
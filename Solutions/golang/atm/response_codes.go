@@ -0,0 +1,99 @@
+package atm
+
+import (
+	"errors"
+	"strings"
+)
+
+// 响应码（字段39），N开头的为本系统自定义代码
+const (
+	RespApproved                = "00"
+	RespInvalidTransaction      = "12"
+	RespInvalidAmount           = "13"
+	RespInvalidCard             = "14"
+	RespAccountNotFound         = "25"
+	RespFormatError             = "30"
+	RespStolenCard              = "43"
+	RespInsufficientFunds       = "51"
+	RespExpiredCard             = "54"
+	RespIncorrectPIN            = "55"
+	RespExceedsAmountLimit      = "61"
+	RespRestrictedCard          = "62"
+	RespExceedsFrequencyLimit   = "65"
+	RespIssuerUnavailable       = "91"
+	RespSystemMalfunction       = "96"
+	RespExceedsTransactionLimit = "N1"
+	RespInvalidNewPIN           = "N2"
+	RespSuspiciousLocation      = "N3"
+)
+
+var responseCodeErrors = []struct {
+	code string
+	err  error
+}{
+	{RespInvalidTransaction, ErrInvalidTransaction},
+	{RespInvalidAmount, ErrInvalidAmount},
+	{RespInvalidCard, ErrCardNotFound},
+	{RespAccountNotFound, ErrAccountNotFound},
+	{RespFormatError, ErrMessageFormat},
+	{RespStolenCard, ErrCardReportedStolen},
+	{RespInsufficientFunds, ErrInsufficientFunds},
+	{RespExpiredCard, ErrCardExpired},
+	{RespIncorrectPIN, ErrInvalidPIN},
+	{RespExceedsAmountLimit, ErrDailyLimitExceeded},
+	{RespRestrictedCard, ErrCardBlocked},
+	{RespExceedsFrequencyLimit, ErrVelocityLimitExceeded},
+	{RespIssuerUnavailable, ErrHostUnavailable},
+	{RespExceedsTransactionLimit, ErrTransactionLimitExceeded},
+	{RespInvalidNewPIN, ErrInvalidPINFormat},
+	{RespSuspiciousLocation, ErrSuspiciousLocation},
+}
+
+// ResponseCodeForError 将错误转换为响应码
+func ResponseCodeForError(err error) string {
+	if err == nil {
+		return RespApproved
+	}
+	for _, entry := range responseCodeErrors {
+		if errors.Is(err, entry.err) {
+			return entry.code
+		}
+	}
+	return RespSystemMalfunction
+}
+
+// ErrorForResponseCode 将响应码还原为错误，批准时返回nil
+// 风控拒绝的决策和规则名通过字段44传递，格式为 "决策/规则"
+func ErrorForResponseCode(code, additionalData string) error {
+	if code == RespApproved {
+		return nil
+	}
+	err := ErrSystemMalfunction
+	for _, entry := range responseCodeErrors {
+		if entry.code == code {
+			err = entry.err
+			break
+		}
+	}
+	if decision, rule, ok := strings.Cut(additionalData, "/"); ok {
+		riskErr := &RiskError{Rule: rule, Decision: DecisionDeny, Err: err}
+		if decision == DecisionStepUp.String() {
+			riskErr.Decision = DecisionStepUp
+		}
+		return riskErr
+	}
+	return err
+}
+
+// additionalDataForError 风控错误携带的附加响应数据
+func additionalDataForError(err error) string {
+	var riskErr *RiskError
+	if errors.As(err, &riskErr) {
+		data := riskErr.Decision.String() + "/" + riskErr.Rule
+		if len(data) > fieldSpecs[FieldAdditionalResponseData].maxLength {
+			data = data[:fieldSpecs[FieldAdditionalResponseData].maxLength]
+		}
+		return data
+	}
+	return ""
+}
@@ -0,0 +1,34 @@
+package atm
+
+import "errors"
+
+// ReversalTransaction 冲正交易，将已扣除的取款退回账户
+type ReversalTransaction struct {
+	BaseTransaction
+	OriginalID string
+}
+
+func NewReversalTransaction(txnID, originalID string, account *Account, amount float64) *ReversalTransaction {
+	return &ReversalTransaction{
+		BaseTransaction: BaseTransaction{
+			TransactionID: txnID,
+			Account:       account,
+			Amount:        amount,
+		},
+		OriginalID: originalID,
+	}
+}
+
+func (t *ReversalTransaction) Execute() error {
+	if t.Account == nil {
+		return errors.New("account is nil")
+	}
+	if t.Amount <= 0 {
+		return ErrInvalidAmount
+	}
+	return t.Account.Credit(t.Amount)
+}
+
+func (t *ReversalTransaction) Type() TransactionType {
+	return TransactionReversal
+}
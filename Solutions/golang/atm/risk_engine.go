@@ -0,0 +1,194 @@
+package atm
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Decision 风控决策结果
+type Decision int
+
+const (
+	DecisionAllow  Decision = iota // 放行
+	DecisionStepUp                 // 需要加强验证
+	DecisionDeny                   // 拒绝
+)
+
+func (d Decision) String() string {
+	switch d {
+	case DecisionAllow:
+		return "allow"
+	case DecisionStepUp:
+		return "step-up"
+	case DecisionDeny:
+		return "deny"
+	}
+	return fmt.Sprintf("Decision(%d)", int(d))
+}
+
+// RiskError 风控规则拒绝或要求加强验证时返回的错误
+// 可通过 errors.Is 判断具体原因（如 ErrDailyLimitExceeded）
+type RiskError struct {
+	Rule     string
+	Decision Decision
+	Err      error
+}
+
+func (e *RiskError) Error() string {
+	return fmt.Sprintf("%s (%s by rule %s)", e.Err, e.Decision, e.Rule)
+}
+
+func (e *RiskError) Unwrap() error {
+	return e.Err
+}
+
+// WithdrawalRequest 提交给风控引擎评估的取款请求
+type WithdrawalRequest struct {
+	TransactionID string
+	CardNumber    string
+	AccountNumber string
+	ATMID         string
+	Amount        float64
+	Time          time.Time
+}
+
+// WithdrawalHistory 风控引擎记录的已授权取款，供规则查询
+type WithdrawalHistory struct {
+	records []WithdrawalRequest
+}
+
+// ByCard 返回指定卡片在 since 之后的取款记录
+func (h *WithdrawalHistory) ByCard(cardNumber string, since time.Time) []WithdrawalRequest {
+	var result []WithdrawalRequest
+	for _, r := range h.records {
+		if r.CardNumber == cardNumber && r.Time.After(since) {
+			result = append(result, r)
+		}
+	}
+	return result
+}
+
+// ByAccount 返回指定账户在 since 之后的取款记录
+func (h *WithdrawalHistory) ByAccount(accountNumber string, since time.Time) []WithdrawalRequest {
+	var result []WithdrawalRequest
+	for _, r := range h.records {
+		if r.AccountNumber == accountNumber && r.Time.After(since) {
+			result = append(result, r)
+		}
+	}
+	return result
+}
+
+// prune 删除早于 cutoff 的记录
+func (h *WithdrawalHistory) prune(cutoff time.Time) {
+	kept := h.records[:0]
+	for _, r := range h.records {
+		if r.Time.After(cutoff) {
+			kept = append(kept, r)
+		}
+	}
+	h.records = kept
+}
+
+// remove 删除指定交易ID的记录
+func (h *WithdrawalHistory) remove(txnID string) bool {
+	for i, r := range h.records {
+		if r.TransactionID == txnID {
+			h.records = append(h.records[:i], h.records[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// RiskRule 风控规则接口
+// Evaluate 返回 nil 表示放行，否则返回 *RiskError
+type RiskRule interface {
+	Name() string
+	Evaluate(req WithdrawalRequest, history *WithdrawalHistory) error
+}
+
+// DefaultRetention 历史记录的最短保留时长
+const DefaultRetention = 24 * time.Hour
+
+// WindowedRule 按滚动窗口查询历史记录的规则，引擎按最长的窗口保留历史
+type WindowedRule interface {
+	RiskRule
+	Lookback() time.Duration
+}
+
+// RiskEngine 风控引擎，依次评估所有规则
+// 评估和记录在同一把锁下完成，避免并发取款绕过限额
+type RiskEngine struct {
+	rules   []RiskRule
+	history WithdrawalHistory
+	now     func() time.Time
+	mu      sync.Mutex
+}
+
+// NewRiskEngine 创建风控引擎，历史记录至少保留24小时，规则窗口更长时按最长窗口保留
+func NewRiskEngine(rules ...RiskRule) *RiskEngine {
+	return &RiskEngine{
+		rules: rules,
+		now:   time.Now,
+	}
+}
+
+// retention 历史记录保留时长，规则窗口可能随时调整，每次评估时重新计算
+func (e *RiskEngine) retention() time.Duration {
+	retention := DefaultRetention
+	for _, rule := range e.rules {
+		if windowed, ok := rule.(WindowedRule); ok && windowed.Lookback() > retention {
+			retention = windowed.Lookback()
+		}
+	}
+	return retention
+}
+
+// AddRule 添加风控规则
+func (e *RiskEngine) AddRule(rule RiskRule) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.rules = append(e.rules, rule)
+}
+
+// Authorize 评估取款请求，放行时记录到历史中
+// 多条规则同时命中时，拒绝优先于加强验证
+func (e *RiskEngine) Authorize(req WithdrawalRequest) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if req.Time.IsZero() {
+		req.Time = e.now()
+	}
+	e.history.prune(req.Time.Add(-e.retention()))
+
+	var result *RiskError
+	for _, rule := range e.rules {
+		err := rule.Evaluate(req, &e.history)
+		if err == nil {
+			continue
+		}
+		riskErr, ok := err.(*RiskError)
+		if !ok {
+			riskErr = &RiskError{Rule: rule.Name(), Decision: DecisionDeny, Err: err}
+		}
+		if result == nil || riskErr.Decision > result.Decision {
+			result = riskErr
+		}
+	}
+	if result != nil {
+		return result
+	}
+
+	e.history.records = append(e.history.records, req)
+	return nil
+}
+
+// Reverse 撤销已授权的取款（如后续扣款失败），使其不再计入限额
+func (e *RiskEngine) Reverse(txnID string) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.history.remove(txnID)
+}
@@ -0,0 +1,217 @@
+package atm
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newRiskTestATM(engine *RiskEngine) (*ATM, *Account) {
+	bankingService := NewBankingService()
+	bankingService.SetRiskEngine(engine)
+	account := NewAccount("ACC001", 10000.0)
+	bankingService.AddAccount(account)
+	bankingService.AddCard(NewCard("CARD001", "1234", "ACC001"))
+	return NewATM(bankingService, NewCashDispenser(100000)), account
+}
+
+// 测试单笔限额和每日限额
+func TestLimitRule(t *testing.T) {
+	engine := NewRiskEngine(NewCardLimitRule(Limits{PerTransaction: 500, Daily: 1000}))
+	atm, account := newRiskTestATM(engine)
+
+	err := atm.WithdrawCash("CARD001", "1234", 600)
+	if !errors.Is(err, ErrTransactionLimitExceeded) {
+		t.Errorf("期望错误 %v, 得到 %v", ErrTransactionLimitExceeded, err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := atm.WithdrawCash("CARD001", "1234", 500); err != nil {
+			t.Fatalf("第%d次取款失败: %v", i+1, err)
+		}
+	}
+
+	err = atm.WithdrawCash("CARD001", "1234", 100)
+	if !errors.Is(err, ErrDailyLimitExceeded) {
+		t.Errorf("期望错误 %v, 得到 %v", ErrDailyLimitExceeded, err)
+	}
+	var riskErr *RiskError
+	if !errors.As(err, &riskErr) || riskErr.Decision != DecisionDeny {
+		t.Errorf("期望拒绝决策, 得到 %v", err)
+	}
+
+	if account.GetBalance() != 9000.0 {
+		t.Errorf("期望余额 9000.0, 得到 %.2f", account.GetBalance())
+	}
+}
+
+// 测试滚动窗口：超过24小时的取款不再计入限额
+func TestLimitRuleRollingWindow(t *testing.T) {
+	engine := NewRiskEngine(NewAccountLimitRule(Limits{Daily: 1000}))
+	now := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	engine.now = func() time.Time { return now }
+	atm, _ := newRiskTestATM(engine)
+
+	if err := atm.WithdrawCash("CARD001", "1234", 1000); err != nil {
+		t.Fatalf("取款失败: %v", err)
+	}
+
+	now = now.Add(23 * time.Hour)
+	if err := atm.WithdrawCash("CARD001", "1234", 100); !errors.Is(err, ErrDailyLimitExceeded) {
+		t.Errorf("期望错误 %v, 得到 %v", ErrDailyLimitExceeded, err)
+	}
+
+	now = now.Add(2 * time.Hour)
+	if err := atm.WithdrawCash("CARD001", "1234", 100); err != nil {
+		t.Errorf("窗口外取款应成功: %v", err)
+	}
+}
+
+// 测试超过24小时的窗口：历史记录按最长的规则窗口保留，多日限额不会少算
+func TestLimitRuleMultiDayWindow(t *testing.T) {
+	rule := NewAccountLimitRule(Limits{Daily: 1000})
+	rule.SetWindow(7 * 24 * time.Hour)
+	engine := NewRiskEngine(rule)
+	now := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	engine.now = func() time.Time { return now }
+	atm, _ := newRiskTestATM(engine)
+
+	if err := atm.WithdrawCash("CARD001", "1234", 600); err != nil {
+		t.Fatalf("取款失败: %v", err)
+	}
+	now = now.Add(3 * 24 * time.Hour)
+	if err := atm.WithdrawCash("CARD001", "1234", 400); err != nil {
+		t.Fatalf("取款失败: %v", err)
+	}
+	now = now.Add(3 * 24 * time.Hour)
+	if err := atm.WithdrawCash("CARD001", "1234", 100); !errors.Is(err, ErrDailyLimitExceeded) {
+		t.Errorf("期望错误 %v, 得到 %v", ErrDailyLimitExceeded, err)
+	}
+
+	// 第一笔取款移出7天窗口
+	now = now.Add(25 * time.Hour)
+	if err := atm.WithdrawCash("CARD001", "1234", 600); err != nil {
+		t.Errorf("窗口外取款应成功: %v", err)
+	}
+}
+
+// 测试为单张卡设置的限额覆盖默认值
+func TestLimitRuleOverride(t *testing.T) {
+	rule := NewCardLimitRule(Limits{PerTransaction: 100})
+	rule.SetLimits("CARD001", Limits{PerTransaction: 1000})
+	atm, _ := newRiskTestATM(NewRiskEngine(rule))
+
+	if err := atm.WithdrawCash("CARD001", "1234", 800); err != nil {
+		t.Errorf("覆盖限额后取款应成功: %v", err)
+	}
+}
+
+// 测试失败的取款不计入限额
+func TestRiskEngineReverseOnFailure(t *testing.T) {
+	engine := NewRiskEngine(NewCardLimitRule(Limits{Daily: 1000}))
+	bankingService := NewBankingService()
+	bankingService.SetRiskEngine(engine)
+	bankingService.AddAccount(NewAccount("ACC001", 10000.0))
+	bankingService.AddCard(NewCard("CARD001", "1234", "ACC001"))
+	atm := NewATM(bankingService, NewCashDispenser(500))
+
+	if err := atm.WithdrawCash("CARD001", "1234", 800); err != ErrInsufficientCashInATM {
+		t.Errorf("期望错误 %v, 得到 %v", ErrInsufficientCashInATM, err)
+	}
+	atm.cashDispenser.AddCash(1000)
+	if err := atm.WithdrawCash("CARD001", "1234", 1000); err != nil {
+		t.Errorf("之前失败的取款不应占用限额: %v", err)
+	}
+}
+
+// 测试取款频率规则
+func TestVelocityRule(t *testing.T) {
+	engine := NewRiskEngine(NewVelocityRule(3, 10*time.Minute))
+	now := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	engine.now = func() time.Time { return now }
+	atm, _ := newRiskTestATM(engine)
+
+	for i := 0; i < 3; i++ {
+		if err := atm.WithdrawCash("CARD001", "1234", 10); err != nil {
+			t.Fatalf("第%d次取款失败: %v", i+1, err)
+		}
+		now = now.Add(time.Minute)
+	}
+	if err := atm.WithdrawCash("CARD001", "1234", 10); !errors.Is(err, ErrVelocityLimitExceeded) {
+		t.Errorf("期望错误 %v, 得到 %v", ErrVelocityLimitExceeded, err)
+	}
+
+	now = now.Add(10 * time.Minute)
+	if err := atm.WithdrawCash("CARD001", "1234", 10); err != nil {
+		t.Errorf("窗口外取款应成功: %v", err)
+	}
+}
+
+// 测试多台ATM取款触发加强验证
+func TestGeoVelocityRule(t *testing.T) {
+	engine := NewRiskEngine(NewGeoVelocityRule(2, time.Hour))
+	bankingService := NewBankingService()
+	bankingService.SetRiskEngine(engine)
+	bankingService.AddAccount(NewAccount("ACC001", 10000.0))
+	bankingService.AddCard(NewCard("CARD001", "1234", "ACC001"))
+
+	ids := []string{"ATM-BJ", "ATM-SH", "ATM-GZ"}
+	atms := make([]*ATM, len(ids))
+	for i, id := range ids {
+		atms[i] = NewATM(bankingService, NewCashDispenser(10000))
+		atms[i].SetID(id)
+	}
+
+	for _, atm := range atms[:2] {
+		if err := atm.WithdrawCash("CARD001", "1234", 100); err != nil {
+			t.Fatalf("%s 取款失败: %v", atm.GetID(), err)
+		}
+	}
+	// 在已使用过的ATM上再次取款不受影响
+	if err := atms[0].WithdrawCash("CARD001", "1234", 100); err != nil {
+		t.Errorf("同一台ATM取款应成功: %v", err)
+	}
+
+	err := atms[2].WithdrawCash("CARD001", "1234", 100)
+	var riskErr *RiskError
+	if !errors.As(err, &riskErr) {
+		t.Fatalf("期望 *RiskError, 得到 %v", err)
+	}
+	if riskErr.Decision != DecisionStepUp || !errors.Is(err, ErrSuspiciousLocation) {
+		t.Errorf("期望加强验证决策, 得到 %v", err)
+	}
+}
+
+// 测试拒绝决策优先于加强验证
+func TestRiskEngineDecisionPriority(t *testing.T) {
+	engine := NewRiskEngine(
+		NewGeoVelocityRule(0, time.Hour),
+		NewCardLimitRule(Limits{PerTransaction: 50}),
+	)
+	err := engine.Authorize(WithdrawalRequest{TransactionID: "T1", CardNumber: "CARD001", ATMID: "ATM-1", Amount: 100})
+	var riskErr *RiskError
+	if !errors.As(err, &riskErr) || riskErr.Decision != DecisionDeny {
+		t.Errorf("期望拒绝决策, 得到 %v", err)
+	}
+}
+
+// 测试并发取款不能绕过每日限额
+func TestConcurrentWithdrawalsRespectDailyLimit(t *testing.T) {
+	engine := NewRiskEngine(NewCardLimitRule(Limits{Daily: 500}))
+	atm, account := newRiskTestATM(engine)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = atm.WithdrawCash("CARD001", "1234", 100)
+		}()
+	}
+	wg.Wait()
+
+	if account.GetBalance() != 9500.0 {
+		t.Errorf("期望余额 9500.0, 得到 %.2f", account.GetBalance())
+	}
+}
@@ -0,0 +1,182 @@
+package atm
+
+import (
+	"sync"
+	"time"
+)
+
+// Limits 取款限额，0表示不限制
+type Limits struct {
+	PerTransaction float64
+	Daily          float64
+}
+
+// LimitScope 限额作用范围
+type LimitScope int
+
+const (
+	CardScope LimitScope = iota
+	AccountScope
+)
+
+// LimitRule 单笔限额和滚动窗口（默认24小时）内的累计限额
+// 可为单张卡或单个账户设置覆盖默认值的限额
+type LimitRule struct {
+	scope     LimitScope
+	defaults  Limits
+	overrides map[string]Limits
+	window    time.Duration
+	mu        sync.RWMutex
+}
+
+// NewCardLimitRule 创建按卡片计算的限额规则
+func NewCardLimitRule(defaults Limits) *LimitRule {
+	return newLimitRule(CardScope, defaults)
+}
+
+// NewAccountLimitRule 创建按账户计算的限额规则
+func NewAccountLimitRule(defaults Limits) *LimitRule {
+	return newLimitRule(AccountScope, defaults)
+}
+
+func newLimitRule(scope LimitScope, defaults Limits) *LimitRule {
+	return &LimitRule{
+		scope:     scope,
+		defaults:  defaults,
+		overrides: make(map[string]Limits),
+		window:    24 * time.Hour,
+	}
+}
+
+// SetLimits 为指定卡号或账号设置限额
+func (r *LimitRule) SetLimits(key string, limits Limits) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.overrides[key] = limits
+}
+
+// SetWindow 设置累计限额的滚动窗口长度
+func (r *LimitRule) SetWindow(window time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.window = window
+}
+
+// Lookback 累计限额查询的窗口长度
+func (r *LimitRule) Lookback() time.Duration {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.window
+}
+
+func (r *LimitRule) Name() string {
+	if r.scope == AccountScope {
+		return "account-limit"
+	}
+	return "card-limit"
+}
+
+func (r *LimitRule) Evaluate(req WithdrawalRequest, history *WithdrawalHistory) error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	key := req.CardNumber
+	if r.scope == AccountScope {
+		key = req.AccountNumber
+	}
+	limits, ok := r.overrides[key]
+	if !ok {
+		limits = r.defaults
+	}
+
+	if limits.PerTransaction > 0 && req.Amount > limits.PerTransaction {
+		return &RiskError{Rule: r.Name(), Decision: DecisionDeny, Err: ErrTransactionLimitExceeded}
+	}
+
+	if limits.Daily > 0 {
+		since := req.Time.Add(-r.window)
+		var records []WithdrawalRequest
+		if r.scope == AccountScope {
+			records = history.ByAccount(key, since)
+		} else {
+			records = history.ByCard(key, since)
+		}
+		total := req.Amount
+		for _, record := range records {
+			total += record.Amount
+		}
+		if total > limits.Daily {
+			return &RiskError{Rule: r.Name(), Decision: DecisionDeny, Err: ErrDailyLimitExceeded}
+		}
+	}
+	return nil
+}
+
+// VelocityRule 同一张卡在 Window 内最多取款 MaxCount 次
+type VelocityRule struct {
+	MaxCount int
+	Window   time.Duration
+	Decision Decision
+}
+
+// NewVelocityRule 创建频率规则，超出时拒绝
+func NewVelocityRule(maxCount int, window time.Duration) *VelocityRule {
+	return &VelocityRule{
+		MaxCount: maxCount,
+		Window:   window,
+		Decision: DecisionDeny,
+	}
+}
+
+// Lookback 计数窗口长度
+func (r *VelocityRule) Lookback() time.Duration {
+	return r.Window
+}
+
+func (r *VelocityRule) Name() string {
+	return "velocity"
+}
+
+func (r *VelocityRule) Evaluate(req WithdrawalRequest, history *WithdrawalHistory) error {
+	records := history.ByCard(req.CardNumber, req.Time.Add(-r.Window))
+	if len(records)+1 > r.MaxCount {
+		return &RiskError{Rule: r.Name(), Decision: r.Decision, Err: ErrVelocityLimitExceeded}
+	}
+	return nil
+}
+
+// GeoVelocityRule 同一张卡在 Window 内最多在 MaxATMs 台不同的ATM上取款
+type GeoVelocityRule struct {
+	MaxATMs  int
+	Window   time.Duration
+	Decision Decision
+}
+
+// NewGeoVelocityRule 创建多地取款规则，超出时要求加强验证
+func NewGeoVelocityRule(maxATMs int, window time.Duration) *GeoVelocityRule {
+	return &GeoVelocityRule{
+		MaxATMs:  maxATMs,
+		Window:   window,
+		Decision: DecisionStepUp,
+	}
+}
+
+// Lookback 计数窗口长度
+func (r *GeoVelocityRule) Lookback() time.Duration {
+	return r.Window
+}
+
+func (r *GeoVelocityRule) Name() string {
+	return "geo-velocity"
+}
+
+func (r *GeoVelocityRule) Evaluate(req WithdrawalRequest, history *WithdrawalHistory) error {
+	atms := map[string]bool{req.ATMID: true}
+	for _, record := range history.ByCard(req.CardNumber, req.Time.Add(-r.Window)) {
+		atms[record.ATMID] = true
+	}
+	if len(atms) > r.MaxATMs {
+		return &RiskError{Rule: r.Name(), Decision: r.Decision, Err: ErrSuspiciousLocation}
+	}
+	return nil
+}
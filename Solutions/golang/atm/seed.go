@@ -0,0 +1,136 @@
+package atm
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Seed 初始化银行账户、银行卡和ATM现金的种子数据，通常从JSON文件加载
+//
+//	{
+//	  "atmID": "ATM-001",
+//	  "cash": 10000,
+//	  "accounts": [{"number": "ACC001", "balance": 1000}],
+//	  "cards": [{"number": "CARD001", "pin": "1234", "account": "ACC001", "status": "active"}]
+//	}
+type Seed struct {
+	ATMID    string        `json:"atmID"`
+	Cash     int           `json:"cash"`
+	Accounts []SeedAccount `json:"accounts"`
+	Cards    []SeedCard    `json:"cards"`
+}
+
+type SeedAccount struct {
+	Number  string  `json:"number"`
+	Balance float64 `json:"balance"`
+}
+
+type SeedCard struct {
+	Number  string `json:"number"`
+	PIN     string `json:"pin"`
+	Account string `json:"account"`
+	Status  string `json:"status,omitempty"` // active/blocked/expired/reported-stolen，默认为active
+}
+
+// LoadSeed 从JSON读取种子数据并校验
+func LoadSeed(r io.Reader) (*Seed, error) {
+	var seed Seed
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&seed); err != nil {
+		return nil, fmt.Errorf("invalid seed data: %w", err)
+	}
+	if err := seed.Validate(); err != nil {
+		return nil, err
+	}
+	return &seed, nil
+}
+
+// LoadSeedFile 从JSON文件读取种子数据
+func LoadSeedFile(path string) (*Seed, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return LoadSeed(f)
+}
+
+// Validate 检查种子数据的一致性
+func (s *Seed) Validate() error {
+	if s.Cash < 0 {
+		return fmt.Errorf("invalid seed data: negative cash %d", s.Cash)
+	}
+	accounts := make(map[string]bool)
+	for _, account := range s.Accounts {
+		if account.Number == "" {
+			return fmt.Errorf("invalid seed data: account without number")
+		}
+		if accounts[account.Number] {
+			return fmt.Errorf("invalid seed data: duplicate account %s", account.Number)
+		}
+		if account.Balance < 0 {
+			return fmt.Errorf("invalid seed data: account %s has negative balance", account.Number)
+		}
+		accounts[account.Number] = true
+	}
+
+	cards := make(map[string]bool)
+	for _, card := range s.Cards {
+		if card.Number == "" {
+			return fmt.Errorf("invalid seed data: card without number")
+		}
+		if cards[card.Number] {
+			return fmt.Errorf("invalid seed data: duplicate card %s", card.Number)
+		}
+		if !accounts[card.Account] {
+			return fmt.Errorf("invalid seed data: card %s references unknown account %s", card.Number, card.Account)
+		}
+		if !validPINFormat(card.PIN) {
+			return fmt.Errorf("invalid seed data: card %s: %w", card.Number, ErrInvalidPINFormat)
+		}
+		if _, err := parseCardStatus(card.Status); err != nil {
+			return fmt.Errorf("invalid seed data: card %s: %w", card.Number, err)
+		}
+		cards[card.Number] = true
+	}
+	return nil
+}
+
+// Apply 将账户和银行卡加入银行服务
+func (s *Seed) Apply(bankingService *BankingService) {
+	for _, account := range s.Accounts {
+		bankingService.AddAccount(NewAccount(account.Number, account.Balance))
+	}
+	for _, seedCard := range s.Cards {
+		card := NewCard(seedCard.Number, seedCard.PIN, seedCard.Account)
+		status, _ := parseCardStatus(seedCard.Status)
+		card.SetStatus(status)
+		bankingService.AddCard(card)
+	}
+}
+
+// NewATMFromSeed 根据种子数据创建银行服务和ATM
+func NewATMFromSeed(seed *Seed) (*ATM, *BankingService) {
+	bankingService := NewBankingService()
+	seed.Apply(bankingService)
+	atm := NewATM(bankingService, NewCashDispenser(seed.Cash))
+	if seed.ATMID != "" {
+		atm.SetID(seed.ATMID)
+	}
+	return atm, bankingService
+}
+
+func parseCardStatus(s string) (CardStatus, error) {
+	if s == "" {
+		return CardActive, nil
+	}
+	for _, status := range []CardStatus{CardActive, CardBlocked, CardExpired, CardReportedStolen} {
+		if status.String() == s {
+			return status, nil
+		}
+	}
+	return CardActive, fmt.Errorf("unknown card status %q", s)
+}
@@ -0,0 +1,55 @@
+package atm
+
+import (
+	"strings"
+	"testing"
+)
+
+const testSeed = `{
+  "atmID": "ATM-T01",
+  "cash": 5000,
+  "accounts": [{"number": "ACC001", "balance": 1000}],
+  "cards": [
+    {"number": "CARD001", "pin": "1234", "account": "ACC001"},
+    {"number": "CARD002", "pin": "5678", "account": "ACC001", "status": "blocked"}
+  ]
+}`
+
+// 测试从JSON加载种子数据
+func TestLoadSeed(t *testing.T) {
+	seed, err := LoadSeed(strings.NewReader(testSeed))
+	if err != nil {
+		t.Fatalf("加载种子数据失败: %v", err)
+	}
+	atm, bankingService := NewATMFromSeed(seed)
+
+	if atm.GetID() != "ATM-T01" {
+		t.Errorf("期望ATM编号 ATM-T01, 得到 %s", atm.GetID())
+	}
+	if atm.cashDispenser.GetAvailableCash() != 5000 {
+		t.Errorf("期望ATM现金 5000, 得到 %d", atm.cashDispenser.GetAvailableCash())
+	}
+	if balance, err := atm.GetBalance("CARD001", "1234"); err != nil || balance != 1000.0 {
+		t.Errorf("期望余额 1000.0, 得到 %.2f (%v)", balance, err)
+	}
+	if _, err := bankingService.ValidateCard("CARD002", "5678"); err != ErrCardBlocked {
+		t.Errorf("期望错误 %v, 得到 %v", ErrCardBlocked, err)
+	}
+}
+
+// 测试无效的种子数据
+func TestLoadSeedInvalid(t *testing.T) {
+	inputs := []string{
+		`{"accounts": [{"number": "ACC001"}, {"number": "ACC001"}]}`,
+		`{"cards": [{"number": "CARD001", "pin": "1234", "account": "ACC404"}]}`,
+		`{"accounts": [{"number": "ACC001"}], "cards": [{"number": "CARD001", "pin": "12", "account": "ACC001"}]}`,
+		`{"accounts": [{"number": "ACC001"}], "cards": [{"number": "CARD001", "pin": "1234", "account": "ACC001", "status": "lost"}]}`,
+		`{"cash": -1}`,
+		`{"unknown": true}`,
+	}
+	for _, input := range inputs {
+		if _, err := LoadSeed(strings.NewReader(input)); err == nil {
+			t.Errorf("%s: 期望加载失败", input)
+		}
+	}
+}
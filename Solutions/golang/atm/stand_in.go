@@ -0,0 +1,114 @@
+package atm
+
+import (
+	"errors"
+	"sync"
+)
+
+// StandInHost 代授权：主机不可达时按本地规则处理交易，并在恢复后补发通知
+// 脱机时无法校验PIN和余额，因此只批准不超过限额的取款，
+// 每张卡在一次脱机期间累计取款也不能超过该限额
+type StandInHost struct {
+	primary    AuthorizationHost
+	floorLimit float64
+	spent      map[string]float64     // key: PAN，脱机期间累计批准的取款金额
+	pending    []AuthorizationRequest // 待补发的冲正和通知
+	exceptions []AuthorizationRequest // 补发后被主机拒绝的通知
+	mu         sync.Mutex
+}
+
+// NewStandInHost 创建代授权主机
+func NewStandInHost(primary AuthorizationHost, floorLimit float64) *StandInHost {
+	return &StandInHost{
+		primary:    primary,
+		floorLimit: floorLimit,
+		spent:      make(map[string]float64),
+	}
+}
+
+func (s *StandInHost) Authorize(req AuthorizationRequest) (*AuthorizationResponse, error) {
+	resp, err := s.primary.Authorize(req)
+	if !errors.Is(err, ErrHostUnavailable) && !errors.Is(err, ErrHostTimeout) {
+		return resp, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// 超时的金融交易可能已被主机处理，先排队冲正
+	if errors.Is(err, ErrHostTimeout) && req.MTI == MTIFinancialRequest {
+		s.enqueue(req, MTIReversal)
+	}
+
+	switch {
+	case req.MTI == MTIReversal || req.MTI == MTIAdvice:
+		s.pending = append(s.pending, req)
+		return s.approve(req), nil
+	case req.MTI != MTIFinancialRequest:
+		return nil, err
+	case req.ProcessingCode == ProcDeposit && req.Amount > 0:
+		s.enqueue(req, MTIAdvice)
+		return s.approve(req), nil
+	case req.ProcessingCode == ProcWithdrawal && req.Amount > 0:
+		if s.spent[req.PAN]+req.Amount > s.floorLimit {
+			return nil, err
+		}
+		s.spent[req.PAN] += req.Amount
+		s.enqueue(req, MTIAdvice)
+		return s.approve(req), nil
+	}
+	return nil, err
+}
+
+// enqueue 以指定报文类型排队待补发，补发的报文不携带PIN
+func (s *StandInHost) enqueue(req AuthorizationRequest, mti string) {
+	req.MTI = mti
+	req.PIN = ""
+	req.NewPIN = ""
+	s.pending = append(s.pending, req)
+}
+
+func (s *StandInHost) approve(req AuthorizationRequest) *AuthorizationResponse {
+	return &AuthorizationResponse{
+		MTI:          responseMTI(req.MTI),
+		ResponseCode: RespApproved,
+		STAN:         req.STAN,
+		StandIn:      true,
+	}
+}
+
+// Pending 返回待补发的报文数量
+func (s *StandInHost) Pending() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.pending)
+}
+
+// Exceptions 返回补发后被主机拒绝的通知，需要人工处理
+func (s *StandInHost) Exceptions() []AuthorizationRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]AuthorizationRequest(nil), s.exceptions...)
+}
+
+// Flush 按顺序向主机补发排队的报文，主机仍不可达时停止并返回错误
+// 超时的报文留在队首下次重发，主机对重发的冲正和通知只处理一次
+// 全部补发完成后清零脱机累计金额
+func (s *StandInHost) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for len(s.pending) > 0 {
+		req := s.pending[0]
+		_, err := s.primary.Authorize(req)
+		if errors.Is(err, ErrHostUnavailable) || errors.Is(err, ErrHostTimeout) {
+			return err
+		}
+		if err != nil {
+			s.exceptions = append(s.exceptions, req)
+		}
+		s.pending = s.pending[1:]
+	}
+	s.spent = make(map[string]float64)
+	return nil
+}
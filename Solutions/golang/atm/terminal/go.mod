@@ -0,0 +1,7 @@
+module terminal
+
+go 1.23.4
+
+replace atm => ../
+
+require atm v0.0.0-00010101000000-000000000000
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"atm"
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+)
+
+func main() {
+	seedPath := flag.String("seed", "seed.json", "账户和银行卡的种子数据文件")
+	report := flag.Bool("report", false, "退出时打印日终对账报告")
+	flag.Parse()
+
+	seed, err := atm.LoadSeedFile(*seedPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "加载种子数据失败: %v\n", err)
+		os.Exit(1)
+	}
+	machine, bankingService := atm.NewATMFromSeed(seed)
+
+	ui := atm.NewTerminalUI(machine, os.Stdin, os.Stdout)
+	if isTerminal() {
+		ui.SetPINReader(func() (string, error) {
+			return readMaskedPIN(ui.Input())
+		})
+	}
+	if err := ui.Run(); err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *report {
+		now := time.Now()
+		day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+		history := bankingService.GetTransactionHistory(day, day.AddDate(0, 0, 1))
+		fmt.Print(machine.EndOfDayReport(now, history).Text())
+	}
+}
+
+// isTerminal 判断标准输入是否为交互式终端
+func isTerminal() bool {
+	info, err := os.Stdin.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// stty 修改终端模式
+func stty(args ...string) error {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = os.Stdin
+	return cmd.Run()
+}
+
+// readMaskedPIN 关闭回显逐字符读取PIN，每输入一位显示一个*
+func readMaskedPIN(in *bufio.Reader) (string, error) {
+	if err := stty("-echo", "-icanon", "min", "1"); err != nil {
+		// 无法切换终端模式时退化为普通输入
+		line, err := in.ReadString('\n')
+		if len(line) > 0 && line[len(line)-1] == '\n' {
+			line = line[:len(line)-1]
+		}
+		return line, err
+	}
+	defer stty("echo", "icanon")
+
+	var pin []byte
+	for {
+		b, err := in.ReadByte()
+		if err != nil {
+			fmt.Println()
+			return "", err
+		}
+		switch b {
+		case '\n', '\r':
+			fmt.Println()
+			return string(pin), nil
+		case 127, '\b':
+			if len(pin) > 0 {
+				pin = pin[:len(pin)-1]
+				fmt.Print("\b \b")
+			}
+		default:
+			if b >= '0' && b <= '9' {
+				pin = append(pin, b)
+				fmt.Print("*")
+			}
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package atm
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// TerminalUI 基于菜单的终端界面，驱动一台ATM完成插卡、输入PIN、选择业务和打印凭条
+type TerminalUI struct {
+	atm     *ATM
+	in      *bufio.Reader
+	out     io.Writer
+	readPIN func() (string, error)
+}
+
+// NewTerminalUI 创建终端界面，默认从输入中按行读取PIN
+// 成功的交易会通过ATM的凭条回调打印凭条
+func NewTerminalUI(atm *ATM, in io.Reader, out io.Writer) *TerminalUI {
+	ui := &TerminalUI{
+		atm: atm,
+		in:  bufio.NewReader(in),
+		out: out,
+	}
+	ui.readPIN = ui.readLine
+	atm.SetReceiptHandler(func(receipt *Receipt) {
+		if receipt.Approved {
+			fmt.Fprint(ui.out, receipt.Text())
+		}
+	})
+	return ui
+}
+
+// SetPINReader 设置PIN读取函数，真实终端上用于关闭回显并显示掩码
+func (ui *TerminalUI) SetPINReader(readPIN func() (string, error)) {
+	ui.readPIN = readPIN
+}
+
+// Input 返回界面使用的输入，供自定义的PIN读取函数共享缓冲
+func (ui *TerminalUI) Input() *bufio.Reader {
+	return ui.in
+}
+
+// Run 循环处理插卡，直到输入q或输入结束
+func (ui *TerminalUI) Run() error {
+	fmt.Fprintf(ui.out, "=== 欢迎使用ATM（%s）===\n", ui.atm.GetID())
+	for {
+		fmt.Fprint(ui.out, "\n请插卡（输入卡号，q退出）: ")
+		cardNumber, err := ui.readLine()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if cardNumber == "" {
+			continue
+		}
+		if cardNumber == "q" {
+			fmt.Fprintln(ui.out, "再见")
+			return nil
+		}
+		if err := ui.session(cardNumber); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+// session 处理一次插卡到退卡的过程
+func (ui *TerminalUI) session(cardNumber string) error {
+	fmt.Fprint(ui.out, "请输入PIN: ")
+	pin, err := ui.readPIN()
+	if err != nil {
+		return err
+	}
+	if _, err := ui.atm.AuthenticateUser(cardNumber, pin); err != nil {
+		fmt.Fprintf(ui.out, "认证失败: %s\n", describeError(err))
+		fmt.Fprintln(ui.out, "请取回您的卡")
+		return nil
+	}
+
+	for {
+		fmt.Fprintln(ui.out, "\n1. 查询余额  2. 取款  3. 存款  4. 修改PIN  5. 退卡")
+		fmt.Fprint(ui.out, "请选择业务: ")
+		choice, err := ui.readLine()
+		if err != nil {
+			return err
+		}
+
+		switch choice {
+		case "1":
+			if _, err := ui.atm.GetBalance(cardNumber, pin); err != nil {
+				ui.printError(err)
+			}
+		case "2", "3":
+			amount, err := ui.readAmount()
+			if err != nil {
+				if err == io.EOF {
+					return err
+				}
+				ui.printError(err)
+				continue
+			}
+			if choice == "2" {
+				err = ui.atm.WithdrawCash(cardNumber, pin, amount)
+			} else {
+				err = ui.atm.DepositCash(cardNumber, pin, amount)
+			}
+			if err != nil {
+				ui.printError(err)
+			}
+		case "4":
+			fmt.Fprint(ui.out, "请输入新PIN: ")
+			newPIN, err := ui.readPIN()
+			if err != nil {
+				return err
+			}
+			fmt.Fprint(ui.out, "请再次输入新PIN: ")
+			confirm, err := ui.readPIN()
+			if err != nil {
+				return err
+			}
+			if newPIN != confirm {
+				fmt.Fprintln(ui.out, "两次输入的PIN不一致")
+				continue
+			}
+			if err := ui.atm.ChangePIN(cardNumber, pin, newPIN); err != nil {
+				ui.printError(err)
+				continue
+			}
+			pin = newPIN
+			fmt.Fprintln(ui.out, "PIN修改成功")
+		case "5":
+			fmt.Fprintln(ui.out, "请取回您的卡")
+			return nil
+		default:
+			fmt.Fprintln(ui.out, "无效的选择")
+		}
+	}
+}
+
+func (ui *TerminalUI) readAmount() (float64, error) {
+	fmt.Fprint(ui.out, "请输入金额: ")
+	line, err := ui.readLine()
+	if err != nil {
+		return 0, err
+	}
+	amount, err := strconv.ParseFloat(line, 64)
+	if err != nil || amount <= 0 {
+		return 0, ErrInvalidAmount
+	}
+	return amount, nil
+}
+
+func (ui *TerminalUI) readLine() (string, error) {
+	line, err := ui.in.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", err
+	}
+	return strings.TrimSpace(line), nil
+}
+
+func (ui *TerminalUI) printError(err error) {
+	fmt.Fprintf(ui.out, "操作失败: %s\n", describeError(err))
+}
+
+// describeError 将错误转换为面向用户的提示
+func describeError(err error) string {
+	var riskErr *RiskError
+	if errors.As(err, &riskErr) && riskErr.Decision == DecisionStepUp {
+		return "交易需要进一步验证，请联系发卡行"
+	}
+	messages := []struct {
+		err     error
+		message string
+	}{
+		{ErrInvalidPIN, "PIN错误"},
+		{ErrCardNotFound, "无效的卡"},
+		{ErrCardBlocked, "卡片已锁定"},
+		{ErrCardExpired, "卡片已过期"},
+		{ErrCardReportedStolen, "卡片已挂失"},
+		{ErrInvalidPINFormat, "PIN必须为4到6位数字"},
+		{ErrInvalidAmount, "无效金额"},
+		{ErrInsufficientFunds, "余额不足"},
+		{ErrInsufficientCashInATM, "ATM现金不足"},
+		{ErrTransactionLimitExceeded, "超过单笔限额"},
+		{ErrDailyLimitExceeded, "超过每日限额"},
+		{ErrVelocityLimitExceeded, "取款过于频繁"},
+		{ErrHostUnavailable, "银行系统暂时无法连接"},
+		{ErrHostTimeout, "银行系统响应超时"},
+	}
+	for _, m := range messages {
+		if errors.Is(err, m.err) {
+			return m.message
+		}
+	}
+	return err.Error()
+}
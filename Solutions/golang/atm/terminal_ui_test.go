@@ -0,0 +1,59 @@
+package atm
+
+import (
+	"strings"
+	"testing"
+)
+
+func runTerminalUI(t *testing.T, atm *ATM, input string) string {
+	var out strings.Builder
+	ui := NewTerminalUI(atm, strings.NewReader(input), &out)
+	if err := ui.Run(); err != nil {
+		t.Fatalf("界面运行失败: %v", err)
+	}
+	return out.String()
+}
+
+// 测试完整的插卡、取款、存款和退卡流程
+func TestTerminalUISession(t *testing.T) {
+	bankingService := NewBankingService()
+	account := NewAccount("ACC001", 1000.0)
+	bankingService.AddAccount(account)
+	bankingService.AddCard(NewCard("CARD001", "1234", "ACC001"))
+	atm := NewATM(bankingService, NewCashDispenser(10000))
+
+	input := "CARD001\n1234\n1\n2\n200\n3\n50\n2\nabc\n5\nq\n"
+	out := runTerminalUI(t, atm, input)
+
+	if account.GetBalance() != 850.0 {
+		t.Errorf("期望余额 850.0, 得到 %.2f", account.GetBalance())
+	}
+	for _, want := range []string{"业务: 余额查询", "业务: 取款", "金额: 200.00", "余额: 850.00", "卡号: ***D001", "操作失败: 无效金额", "请取回您的卡", "再见"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("输出中缺少 %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "CARD001") {
+		t.Error("凭条中不应显示完整卡号")
+	}
+}
+
+// 测试PIN错误和修改PIN
+func TestTerminalUIPIN(t *testing.T) {
+	bankingService := NewBankingService()
+	bankingService.AddAccount(NewAccount("ACC001", 1000.0))
+	bankingService.AddCard(NewCard("CARD001", "1234", "ACC001"))
+	atm := NewATM(bankingService, NewCashDispenser(10000))
+
+	input := "CARD001\n0000\nCARD001\n1234\n4\n4321\n4321\n1\n5\n"
+	out := runTerminalUI(t, atm, input)
+
+	for _, want := range []string{"认证失败: PIN错误", "PIN修改成功", "余额: 1000.00"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("输出中缺少 %q:\n%s", want, out)
+		}
+	}
+	if _, err := atm.AuthenticateUser("CARD001", "4321"); err != nil {
+		t.Errorf("新PIN认证失败: %v", err)
+	}
+}
@@ -1,7 +1,21 @@
 package atm
 
+import "time"
+
+// TransactionType 交易类型
+type TransactionType string
+
+const (
+	TransactionWithdrawal     TransactionType = "withdrawal"
+	TransactionDeposit        TransactionType = "deposit"
+	TransactionReversal       TransactionType = "reversal"
+	TransactionBalanceInquiry TransactionType = "balance-inquiry"
+)
+
 type Transaction interface {
 	Execute() error
+	Type() TransactionType
+	Details() BaseTransaction
 }
 
 type BaseTransaction struct {
@@ -9,3 +23,18 @@ type BaseTransaction struct {
 	Account       *Account
 	Amount        float64
 }
+
+// Details 返回交易的基本信息
+func (t BaseTransaction) Details() BaseTransaction {
+	return t
+}
+
+// TransactionRecord 银行端记录的已入账交易
+type TransactionRecord struct {
+	TransactionID string          `json:"transactionId"`
+	Type          TransactionType `json:"type"`
+	AccountNumber string          `json:"accountNumber"`
+	Amount        float64         `json:"amount"`
+	OriginalID    string          `json:"originalId,omitempty"` // 冲正交易对应的原交易ID
+	Time          time.Time       `json:"time"`
+}
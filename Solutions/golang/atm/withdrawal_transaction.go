@@ -25,3 +25,7 @@ func (t *WithdrawalTransaction) Execute() error {
 	}
 	return t.Account.Debit(t.Amount)
 }
+
+func (t *WithdrawalTransaction) Type() TransactionType {
+	return TransactionWithdrawal
+}
@@ -0,0 +1,313 @@
+package parkinglot
+
+import (
+	"encoding/csv"
+	"io"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// UsageRecorder 订阅停车和取车事件，记录停车场的使用历史
+type UsageRecorder struct {
+	events      []Event
+	unsubscribe func()
+	mu          sync.Mutex
+}
+
+// NewUsageRecorder 创建记录器并开始记录停车场的事件
+func NewUsageRecorder(lot *ParkingLot) *UsageRecorder {
+	r := &UsageRecorder{}
+	r.unsubscribe = lot.Subscribe(r.record)
+	return r
+}
+
+func (r *UsageRecorder) record(event Event) {
+	if event.Type != VehicleParked && event.Type != VehicleLeft {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+}
+
+// Stop 停止记录
+func (r *UsageRecorder) Stop() {
+	r.unsubscribe()
+}
+
+// Events 返回已记录的停车和取车事件，按发生顺序排列
+func (r *UsageRecorder) Events() []Event {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Event(nil), r.events...)
+}
+
+// Stay 一次停车，从停入到离开，尚未离开时 Exit 为零
+type Stay struct {
+	LicensePlate string
+	VehicleType  VehicleType
+	Spot         SpotID
+	TicketID     string
+	Entry        time.Time
+	Exit         time.Time
+	Fee          float64 // 应付总额
+	Paid         float64 // 实收金额，未付款出场为0
+}
+
+// Stays 将停车和取车事件按车牌配对为停车记录，按入场时间排列
+func (r *UsageRecorder) Stays() []Stay {
+	var stays []Stay
+	open := make(map[string]int)
+	for _, event := range r.Events() {
+		switch event.Type {
+		case VehicleParked:
+			open[event.LicensePlate] = len(stays)
+			stays = append(stays, Stay{
+				LicensePlate: event.LicensePlate,
+				VehicleType:  event.VehicleType,
+				Spot:         event.Spot,
+				TicketID:     event.TicketID,
+				Entry:        event.Time,
+			})
+		case VehicleLeft:
+			i, ok := open[event.LicensePlate]
+			if !ok {
+				continue
+			}
+			delete(open, event.LicensePlate)
+			stays[i].Exit = event.Time
+			stays[i].Fee = event.Fee
+			stays[i].Paid = event.Paid
+		}
+	}
+	return stays
+}
+
+// HourlyOccupancy 某一小时内某楼层某车型的占用情况
+type HourlyOccupancy struct {
+	Hour            time.Time
+	Floor           int
+	VehicleType     VehicleType
+	AverageOccupied float64 // 这一小时内平均在场车辆数
+	PeakOccupied    int     // 这一小时内同时在场的最多车辆数
+}
+
+// HourOfDayOccupancy 一天中某个小时的平均在场车辆数
+type HourOfDayOccupancy struct {
+	Hour            int
+	AverageOccupied float64
+}
+
+// DailyRevenue 一天内出场车辆的收入，只统计出场时实际收取的款项
+type DailyRevenue struct {
+	Date    time.Time
+	Exits   int
+	Revenue float64
+}
+
+// UsageReport 一段时间内的使用统计
+type UsageReport struct {
+	From         time.Time
+	To           time.Time
+	Hourly       []HourlyOccupancy    // 按小时、楼层、车型排列，没有车辆的小时不列出
+	AverageDwell time.Duration        // 报表期间内离场车辆的平均停车时长
+	PeakHours    []HourOfDayOccupancy // 按平均在场车辆数从高到低排列
+	Revenue      []DailyRevenue       // 按出场日期统计，日期按 From 所在时区划分
+}
+
+type occupancyKey struct {
+	floor       int
+	vehicleType VehicleType
+}
+
+// Report 统计 [from, to) 期间的小时占用、平均停车时长、高峰时段和每日收入
+func (r *UsageRecorder) Report(from, to time.Time) *UsageReport {
+	report := &UsageReport{From: from, To: to}
+	stays := r.Stays()
+
+	groups := make(map[occupancyKey][]Stay)
+	for _, stay := range stays {
+		key := occupancyKey{floor: stay.Spot.Floor, vehicleType: stay.VehicleType}
+		groups[key] = append(groups[key], stay)
+	}
+	hourOfDay := make(map[int]float64)
+	hoursOfDay := make(map[int]int)
+	for hour := startOfHour(from); hour.Before(to); hour = hour.Add(time.Hour) {
+		h := hour.Hour()
+		hoursOfDay[h]++
+		for key, group := range groups {
+			occupancy := hourlyOccupancy(group, hour, to)
+			if occupancy.PeakOccupied == 0 {
+				continue
+			}
+			occupancy.Floor = key.floor
+			occupancy.VehicleType = key.vehicleType
+			report.Hourly = append(report.Hourly, occupancy)
+			hourOfDay[h] += occupancy.AverageOccupied
+		}
+	}
+	sort.Slice(report.Hourly, func(i, j int) bool {
+		a, b := report.Hourly[i], report.Hourly[j]
+		if !a.Hour.Equal(b.Hour) {
+			return a.Hour.Before(b.Hour)
+		}
+		if a.Floor != b.Floor {
+			return a.Floor < b.Floor
+		}
+		return a.VehicleType < b.VehicleType
+	})
+
+	for hour, total := range hourOfDay {
+		if total > 0 {
+			report.PeakHours = append(report.PeakHours, HourOfDayOccupancy{Hour: hour, AverageOccupied: total / float64(hoursOfDay[hour])})
+		}
+	}
+	sort.Slice(report.PeakHours, func(i, j int) bool {
+		a, b := report.PeakHours[i], report.PeakHours[j]
+		if a.AverageOccupied != b.AverageOccupied {
+			return a.AverageOccupied > b.AverageOccupied
+		}
+		return a.Hour < b.Hour
+	})
+
+	var dwell time.Duration
+	var exits int
+	revenue := make(map[time.Time]*DailyRevenue)
+	for _, stay := range stays {
+		if stay.Exit.IsZero() || stay.Exit.Before(from) || !stay.Exit.Before(to) {
+			continue
+		}
+		dwell += stay.Exit.Sub(stay.Entry)
+		exits++
+		exit := stay.Exit.In(from.Location())
+		date := time.Date(exit.Year(), exit.Month(), exit.Day(), 0, 0, 0, 0, from.Location())
+		day, ok := revenue[date]
+		if !ok {
+			day = &DailyRevenue{Date: date}
+			revenue[date] = day
+		}
+		day.Exits++
+		day.Revenue += stay.Paid
+	}
+	if exits > 0 {
+		report.AverageDwell = dwell / time.Duration(exits)
+	}
+	for _, day := range revenue {
+		report.Revenue = append(report.Revenue, *day)
+	}
+	sort.Slice(report.Revenue, func(i, j int) bool {
+		return report.Revenue[i].Date.Before(report.Revenue[j].Date)
+	})
+	return report
+}
+
+// startOfHour 返回 t 在其时区内所在的整点
+// time.Truncate 按绝对时间取整，在与UTC相差非整小时的时区会落在半点
+func startOfHour(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, t.Location())
+}
+
+// hourlyOccupancy 统计一组停车记录在 [hour, hour+1h) 内的平均和峰值在场车辆数，尚未离场的记录计算到 to
+func hourlyOccupancy(stays []Stay, hour, to time.Time) HourlyOccupancy {
+	type change struct {
+		at    time.Time
+		delta int
+	}
+	end := hour.Add(time.Hour)
+	var busy time.Duration
+	var changes []change
+	for _, stay := range stays {
+		exit := stay.Exit
+		if exit.IsZero() {
+			exit = to
+		}
+		start, stop := stay.Entry, exit
+		if start.Before(hour) {
+			start = hour
+		}
+		if stop.After(end) {
+			stop = end
+		}
+		if !stop.After(start) {
+			continue
+		}
+		busy += stop.Sub(start)
+		changes = append(changes, change{start, 1}, change{stop, -1})
+	}
+	// 同一时刻先离开后停入
+	sort.Slice(changes, func(i, j int) bool {
+		if !changes[i].at.Equal(changes[j].at) {
+			return changes[i].at.Before(changes[j].at)
+		}
+		return changes[i].delta < changes[j].delta
+	})
+	occupancy := HourlyOccupancy{Hour: hour, AverageOccupied: busy.Hours()}
+	current := 0
+	for _, c := range changes {
+		current += c.delta
+		if current > occupancy.PeakOccupied {
+			occupancy.PeakOccupied = current
+		}
+	}
+	return occupancy
+}
+
+// WriteOccupancyCSV 以CSV格式导出小时占用统计
+func (r *UsageReport) WriteOccupancyCSV(w io.Writer) error {
+	out := csv.NewWriter(w)
+	out.Write([]string{"hour", "floor", "vehicle_type", "average_occupied", "peak_occupied"})
+	for _, h := range r.Hourly {
+		out.Write([]string{
+			h.Hour.Format(time.RFC3339),
+			strconv.Itoa(h.Floor),
+			h.VehicleType.String(),
+			strconv.FormatFloat(h.AverageOccupied, 'f', 2, 64),
+			strconv.Itoa(h.PeakOccupied),
+		})
+	}
+	out.Flush()
+	return out.Error()
+}
+
+// WriteRevenueCSV 以CSV格式导出每日收入
+func (r *UsageReport) WriteRevenueCSV(w io.Writer) error {
+	out := csv.NewWriter(w)
+	out.Write([]string{"date", "exits", "revenue"})
+	for _, day := range r.Revenue {
+		out.Write([]string{
+			day.Date.Format(time.DateOnly),
+			strconv.Itoa(day.Exits),
+			strconv.FormatFloat(day.Revenue, 'f', 2, 64),
+		})
+	}
+	out.Flush()
+	return out.Error()
+}
+
+// WriteStaysCSV 以CSV格式导出每次停车的明细，尚未离场的记录出场时间为空
+func (r *UsageRecorder) WriteStaysCSV(w io.Writer) error {
+	out := csv.NewWriter(w)
+	out.Write([]string{"license_plate", "vehicle_type", "spot", "ticket_id", "entry", "exit", "dwell_minutes", "fee", "paid"})
+	for _, stay := range r.Stays() {
+		exit, dwell := "", ""
+		if !stay.Exit.IsZero() {
+			exit = stay.Exit.Format(time.RFC3339)
+			dwell = strconv.FormatFloat(stay.Exit.Sub(stay.Entry).Minutes(), 'f', 0, 64)
+		}
+		out.Write([]string{
+			stay.LicensePlate,
+			stay.VehicleType.String(),
+			stay.Spot.String(),
+			stay.TicketID,
+			stay.Entry.Format(time.RFC3339),
+			exit,
+			dwell,
+			strconv.FormatFloat(stay.Fee, 'f', 2, 64),
+			strconv.FormatFloat(stay.Paid, 'f', 2, 64),
+		})
+	}
+	out.Flush()
+	return out.Error()
+}
@@ -0,0 +1,112 @@
+package parkinglot
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 测试按小时的占用统计、平均停车时长、高峰时段、每日收入和CSV导出
+func TestUsageReport(t *testing.T) {
+	start := time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)
+	clock := start
+	lot := newTestLot(8, &clock) // 0-3为regular，4-5为motorcycle，6-7为truck
+	lot.SetPricingStrategy(NewHourlyPricing(5))
+	recorder := NewUsageRecorder(lot)
+	defer recorder.Stop()
+	entry := NewEntryGate("E1", lot)
+	exit := NewExitGate("X1", lot)
+
+	// 09:00 A1 入场，09:30 A2 入场，10:00 A1 付现金出场，10:30 M1 入场，11:00 A2 未付款出场
+	a1, _ := entry.Enter(NewCar("A1"))
+	clock = start.Add(30 * time.Minute)
+	a2, _ := entry.Enter(NewCar("A2"))
+	clock = start.Add(time.Hour)
+	exit.ExitWithPayment(a1, PayCash(10))
+	clock = start.Add(90 * time.Minute)
+	entry.Enter(NewMotorcycle("M1"))
+	clock = start.Add(2 * time.Hour)
+	exit.Exit(a2)
+
+	report := recorder.Report(start, start.Add(3*time.Hour))
+	want := []HourlyOccupancy{
+		{Hour: start, Floor: 1, VehicleType: CAR, AverageOccupied: 1.5, PeakOccupied: 2},
+		{Hour: start.Add(time.Hour), Floor: 1, VehicleType: CAR, AverageOccupied: 1, PeakOccupied: 1},
+		{Hour: start.Add(time.Hour), Floor: 1, VehicleType: MOTORCYCLE, AverageOccupied: 0.5, PeakOccupied: 1},
+		{Hour: start.Add(2 * time.Hour), Floor: 1, VehicleType: MOTORCYCLE, AverageOccupied: 1, PeakOccupied: 1},
+	}
+	if len(report.Hourly) != len(want) {
+		t.Fatalf("小时统计数量错误: %+v", report.Hourly)
+	}
+	for i, h := range report.Hourly {
+		if !h.Hour.Equal(want[i].Hour) || h.Floor != want[i].Floor || h.VehicleType != want[i].VehicleType ||
+			h.AverageOccupied != want[i].AverageOccupied || h.PeakOccupied != want[i].PeakOccupied {
+			t.Errorf("第%d条小时统计期望 %+v，实际 %+v", i+1, want[i], h)
+		}
+	}
+	if report.AverageDwell != 75*time.Minute {
+		t.Errorf("平均停车时长应为 75 分钟，实际: %v", report.AverageDwell)
+	}
+	wantPeak := []HourOfDayOccupancy{{Hour: 9, AverageOccupied: 1.5}, {Hour: 10, AverageOccupied: 1.5}, {Hour: 11, AverageOccupied: 1}}
+	if !reflect.DeepEqual(report.PeakHours, wantPeak) {
+		t.Errorf("高峰时段错误: %+v", report.PeakHours)
+	}
+	if len(report.Revenue) != 1 || report.Revenue[0].Exits != 2 || report.Revenue[0].Revenue != 5 {
+		t.Errorf("每日收入错误: %+v", report.Revenue)
+	}
+
+	var out strings.Builder
+	if err := report.WriteRevenueCSV(&out); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "date,exits,revenue\n2024-03-04,2,5.00\n" {
+		t.Errorf("收入CSV错误:\n%s", out.String())
+	}
+	out.Reset()
+	if err := recorder.WriteStaysCSV(&out); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 4 || lines[1] != "A1,CAR,L1-000,T-000001,2024-03-04T09:00:00Z,2024-03-04T10:00:00Z,60,5.00,5.00" {
+		t.Errorf("停车明细CSV错误:\n%s", out.String())
+	}
+	if !strings.HasSuffix(lines[3], ",,,0.00,0.00") {
+		t.Errorf("未离场车辆的出场时间应为空: %s", lines[3])
+	}
+	out.Reset()
+	if err := report.WriteOccupancyCSV(&out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "2024-03-04T09:00:00Z,1,CAR,1.50,2\n") {
+		t.Errorf("占用CSV错误:\n%s", out.String())
+	}
+}
+
+// 测试在与UTC相差非整小时的时区按当地整点统计
+func TestUsageReportHalfHourZone(t *testing.T) {
+	zone := time.FixedZone("IST", 5*3600+30*60)
+	start := time.Date(2024, 3, 4, 9, 0, 0, 0, zone)
+	clock := start
+	lot := newTestLot(4, &clock)
+	lot.SetPricingStrategy(NewHourlyPricing(5))
+	recorder := NewUsageRecorder(lot)
+	defer recorder.Stop()
+
+	ticket, _ := NewEntryGate("E1", lot).Enter(NewCar("A1"))
+	clock = start.Add(time.Hour)
+	if _, err := NewExitGate("X1", lot).ExitWithPayment(ticket, PayCash(5)); err != nil {
+		t.Fatal(err)
+	}
+
+	report := recorder.Report(start.Add(15*time.Minute), start.Add(2*time.Hour))
+	if len(report.Hourly) != 1 || !report.Hourly[0].Hour.Equal(start) || report.Hourly[0].AverageOccupied != 1 {
+		t.Errorf("应统计在当地 09:00 这一小时: %+v", report.Hourly)
+	}
+	if !reflect.DeepEqual(report.PeakHours, []HourOfDayOccupancy{{Hour: 9, AverageOccupied: 1}}) {
+		t.Errorf("高峰时段错误: %+v", report.PeakHours)
+	}
+	if len(report.Revenue) != 1 || report.Revenue[0].Revenue != 5 || !report.Revenue[0].Date.Equal(time.Date(2024, 3, 4, 0, 0, 0, 0, zone)) {
+		t.Errorf("每日收入错误: %+v", report.Revenue)
+	}
+}
@@ -0,0 +1,124 @@
+package parkinglot
+
+import "sort"
+
+// SpotAssignmentStrategy 车位分配策略，从各楼层的空闲车位中为车辆选择一个车位
+// 返回的车位只是候选，由停车场负责实际占用；没有合适车位时返回nil
+type SpotAssignmentStrategy interface {
+	SelectSpot(levels []*Level, vehicle Vehicle) (*Level, *ParkingSpot)
+}
+
+// ExactTypeStrategy 默认策略：按楼层顺序选择第一个与车辆类型对应的标准车位（见 SpotTypeFor）
+type ExactTypeStrategy struct{}
+
+func NewExactTypeStrategy() *ExactTypeStrategy {
+	return &ExactTypeStrategy{}
+}
+
+func (s *ExactTypeStrategy) SelectSpot(levels []*Level, vehicle Vehicle) (*Level, *ParkingSpot) {
+	for _, level := range levels {
+		if spot := level.NextFreeSpot(SpotTypeFor(vehicle.GetType())); spot != nil {
+			return level, spot
+		}
+	}
+	return nil, nil
+}
+
+// NearestToEntranceStrategy 选择离入口最近的车位：先比较与入口所在楼层的距离，再比较车位号
+type NearestToEntranceStrategy struct {
+	entranceFloor int
+}
+
+func NewNearestToEntranceStrategy(entranceFloor int) *NearestToEntranceStrategy {
+	return &NearestToEntranceStrategy{entranceFloor: entranceFloor}
+}
+
+func (s *NearestToEntranceStrategy) SelectSpot(levels []*Level, vehicle Vehicle) (*Level, *ParkingSpot) {
+	var bestLevel *Level
+	var bestSpot *ParkingSpot
+	bestDistance := 0
+	for _, level := range levels {
+		distance := level.GetFloor() - s.entranceFloor
+		if distance < 0 {
+			distance = -distance
+		}
+		for _, spotType := range CompatibleSpotTypes(vehicle) {
+			spot := level.NextFreeSpot(spotType)
+			if spot == nil {
+				continue
+			}
+			if bestSpot == nil || distance < bestDistance ||
+				(distance == bestDistance && spot.GetSpotNumber() < bestSpot.GetSpotNumber()) {
+				bestLevel, bestSpot, bestDistance = level, spot, distance
+			}
+		}
+	}
+	return bestLevel, bestSpot
+}
+
+// LowestLevelFirstStrategy 优先填满楼层号最低的楼层，同层内优先选择最小的兼容车位
+type LowestLevelFirstStrategy struct{}
+
+func NewLowestLevelFirstStrategy() *LowestLevelFirstStrategy {
+	return &LowestLevelFirstStrategy{}
+}
+
+func (s *LowestLevelFirstStrategy) SelectSpot(levels []*Level, vehicle Vehicle) (*Level, *ParkingSpot) {
+	sorted := append([]*Level(nil), levels...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].GetFloor() < sorted[j].GetFloor()
+	})
+	return firstCompatibleSpot(sorted, vehicle)
+}
+
+// SpreadLoadStrategy 将车辆分散到空闲率最高的楼层，同层内优先选择最小的兼容车位
+type SpreadLoadStrategy struct{}
+
+func NewSpreadLoadStrategy() *SpreadLoadStrategy {
+	return &SpreadLoadStrategy{}
+}
+
+func (s *SpreadLoadStrategy) SelectSpot(levels []*Level, vehicle Vehicle) (*Level, *ParkingSpot) {
+	freeRatio := make(map[*Level]float64, len(levels))
+	for _, level := range levels {
+		if level.GetTotalSpots() > 0 {
+			freeRatio[level] = float64(level.GetAvailableSpots()) / float64(level.GetTotalSpots())
+		}
+	}
+	sorted := append([]*Level(nil), levels...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return freeRatio[sorted[i]] > freeRatio[sorted[j]]
+	})
+	return firstCompatibleSpot(sorted, vehicle)
+}
+
+// BestFitStrategy 在所有楼层中选择能容纳车辆的最小车位，把大车位留给大车
+// 例如汽车车位停满后汽车才会停入卡车车位
+type BestFitStrategy struct{}
+
+func NewBestFitStrategy() *BestFitStrategy {
+	return &BestFitStrategy{}
+}
+
+func (s *BestFitStrategy) SelectSpot(levels []*Level, vehicle Vehicle) (*Level, *ParkingSpot) {
+	for _, spotType := range CompatibleSpotTypes(vehicle) {
+		for _, level := range levels {
+			if spot := level.NextFreeSpot(spotType); spot != nil {
+				return level, spot
+			}
+		}
+	}
+	return nil, nil
+}
+
+// firstCompatibleSpot 按给定的楼层顺序，在第一个有兼容空闲车位的楼层中选择最小的兼容车位
+func firstCompatibleSpot(levels []*Level, vehicle Vehicle) (*Level, *ParkingSpot) {
+	for _, level := range levels {
+		for _, spotType := range CompatibleSpotTypes(vehicle) {
+			if spot := level.NextFreeSpot(spotType); spot != nil {
+				return level, spot
+			}
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,113 @@
+package parkinglot
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// newStrategyTestLevels 创建三层，每层10个车位：0-4为CAR，5-6为MOTORCYCLE，7-9为TRUCK
+func newStrategyTestLevels() []*Level {
+	return []*Level{NewLevel(1, 10), NewLevel(2, 10), NewLevel(3, 10)}
+}
+
+// 测试各分配策略选中的楼层和车位
+func TestSpotAssignmentStrategies(t *testing.T) {
+	tests := []struct {
+		name      string
+		strategy  SpotAssignmentStrategy
+		prepare   func(levels []*Level)
+		vehicle   Vehicle
+		wantFloor int
+		wantSpot  int
+	}{
+		{"精确类型", NewExactTypeStrategy(), nil, NewMotorcycle("M1"), 1, 5},
+		{"离入口最近", NewNearestToEntranceStrategy(3), nil, NewCar("C1"), 3, 0},
+		// 摩托车可以停汽车车位，0号车位比5号摩托车车位离入口更近
+		{"离入口最近的兼容车位", NewNearestToEntranceStrategy(2), nil, NewMotorcycle("M1"), 2, 0},
+		{"优先低楼层", NewLowestLevelFirstStrategy(), func(levels []*Level) {
+			levels[0], levels[2] = levels[2], levels[0]
+		}, NewCar("C1"), 1, 0},
+		{"分散负载", NewSpreadLoadStrategy(), func(levels []*Level) {
+			levels[0].ParkVehicle(NewCar("X1"))
+			levels[1].ParkVehicle(NewCar("X2"))
+		}, NewCar("C1"), 3, 0},
+		{"最佳适配", NewBestFitStrategy(), func(levels []*Level) {
+			for i := 0; i < 2; i++ {
+				levels[0].ParkVehicle(NewMotorcycle("X"))
+			}
+		}, NewMotorcycle("M1"), 2, 5},
+		// 所有汽车车位停满后，汽车停入卡车车位
+		{"最佳适配升级车位", NewBestFitStrategy(), func(levels []*Level) {
+			for _, level := range levels {
+				for i := 0; i < 5; i++ {
+					level.ParkVehicle(NewCar("X"))
+				}
+			}
+		}, NewCar("C1"), 1, 7},
+	}
+	for _, tt := range tests {
+		levels := newStrategyTestLevels()
+		if tt.prepare != nil {
+			tt.prepare(levels)
+		}
+		level, spot := tt.strategy.SelectSpot(levels, tt.vehicle)
+		if spot == nil {
+			t.Errorf("%s: 未选中车位", tt.name)
+			continue
+		}
+		if level.GetFloor() != tt.wantFloor || spot.GetSpotNumber() != tt.wantSpot {
+			t.Errorf("%s: 期望 %d层%d号，实际 %d层%d号", tt.name, tt.wantFloor, tt.wantSpot, level.GetFloor(), spot.GetSpotNumber())
+		}
+	}
+}
+
+// 测试停车场使用分配策略停车，卡车不能使用小车位
+func TestParkingLotWithBestFit(t *testing.T) {
+	lot := NewParkingLot(1)
+	lot.AddLevel(NewLevel(1, 4))
+	lot.SetSpotAssignmentStrategy(NewBestFitStrategy())
+	gate := NewEntryGate("E1", lot)
+
+	// 0-1为CAR，2为MOTORCYCLE，3为TRUCK
+	wantSpots := []int{0, 1, 3}
+	for i, want := range wantSpots {
+		ticket, err := gate.Enter(NewCar(fmt.Sprintf("C%d", i)))
+		if err != nil {
+			t.Fatalf("第%d辆车入场失败: %v", i+1, err)
+		}
+		if ticket.SpotNumber != want {
+			t.Errorf("第%d辆车期望停入%d号车位，实际: %d", i+1, want, ticket.SpotNumber)
+		}
+	}
+	if _, err := gate.Enter(NewTruck("T1")); !errors.Is(err, ErrNoAvailableSpot) {
+		t.Errorf("期望 ErrNoAvailableSpot，实际: %v", err)
+	}
+	if ticket, err := gate.Enter(NewMotorcycle("M1")); err != nil || ticket.SpotNumber != 2 {
+		t.Errorf("摩托车入场失败: %v", err)
+	}
+}
+
+// 测试空闲车位索引：重复加入的车位只保留一份，可以移除任意位置的车位
+func TestFreeSpotIndex(t *testing.T) {
+	spots := make([]*ParkingSpot, 5)
+	for i := range spots {
+		spots[i] = NewParkingSpotOfType(i, SpotCompact)
+	}
+	free := newFreeSpots(spots)
+	free.push(spots[2])
+	free.push(spots[2])
+	if got := free[SpotCompact].Len(); got != 5 {
+		t.Fatalf("重复加入的车位不应重复索引，期望 5，实际 %d", got)
+	}
+	if !free.remove(spots[3]) || free.remove(spots[3]) {
+		t.Error("车位只能移除一次")
+	}
+	var order []int
+	for spot := free.pop(SpotCompact); spot != nil; spot = free.pop(SpotCompact) {
+		order = append(order, spot.GetSpotNumber())
+	}
+	if fmt.Sprint(order) != "[0 1 2 4]" {
+		t.Errorf("应按车位号取出剩余车位，实际 %v", order)
+	}
+}
@@ -0,0 +1,134 @@
+package parkinglot
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// spotCounts 按车位类型统计的车位数，由Level在持锁时增量维护
+type spotCounts struct {
+	total     map[SpotType]int
+	available map[SpotType]int
+	occupied  int
+}
+
+func newSpotCounts(spots []*ParkingSpot) *spotCounts {
+	c := &spotCounts{
+		total:     make(map[SpotType]int),
+		available: make(map[SpotType]int),
+	}
+	for _, spot := range spots {
+		c.total[spot.GetSpotType()]++
+		if spot.IsAvailable() {
+			c.available[spot.GetSpotType()]++
+		}
+		if spot.GetParkedVehicle() != nil {
+			c.occupied++
+		}
+	}
+	return c
+}
+
+func (c *spotCounts) park(spotType SpotType) {
+	c.available[spotType]--
+	c.occupied++
+}
+
+func (c *spotCounts) release(spotType SpotType) {
+	c.available[spotType]++
+	c.occupied--
+}
+
+func (c *spotCounts) add(spotType SpotType) {
+	c.total[spotType]++
+	c.available[spotType]++
+}
+
+func (c *spotCounts) close(spotType SpotType) {
+	c.available[spotType]--
+}
+
+func (c *spotCounts) reopen(spotType SpotType) {
+	c.available[spotType]++
+}
+
+// hold 车位被预约保留，不再计入可用
+func (c *spotCounts) hold(spotType SpotType) {
+	c.available[spotType]--
+}
+
+func (c *spotCounts) unhold(spotType SpotType) {
+	c.available[spotType]++
+}
+
+func (c *spotCounts) totalOfType(spotType SpotType) int {
+	return c.total[spotType]
+}
+
+func (c *spotCounts) totalAvailable() int {
+	n := 0
+	for _, count := range c.available {
+		n += count
+	}
+	return n
+}
+
+func (c *spotCounts) snapshot(floor int) LevelAvailability {
+	a := LevelAvailability{
+		Floor:    floor,
+		Occupied: c.occupied,
+		ByType:   make(map[string]SpotTypeAvailability, len(c.total)),
+	}
+	for spotType, total := range c.total {
+		a.Total += total
+		a.Available += c.available[spotType]
+		a.ByType[spotType.String()] = SpotTypeAvailability{Total: total, Available: c.available[spotType]}
+	}
+	return a
+}
+
+// SpotTypeAvailability 某一类型车位的总数和可用数
+type SpotTypeAvailability struct {
+	Total     int `json:"total"`
+	Available int `json:"available"`
+}
+
+// LevelAvailability 单层的可用车位，关闭维护的车位计入总数但不计入可用数
+type LevelAvailability struct {
+	Floor     int                             `json:"floor"`
+	Total     int                             `json:"total"`
+	Available int                             `json:"available"`
+	Occupied  int                             `json:"occupied"`
+	ByType    map[string]SpotTypeAvailability `json:"byType"`
+}
+
+// Availability 整个停车场的可用车位
+type Availability struct {
+	Total     int                 `json:"total"`
+	Available int                 `json:"available"`
+	Occupied  int                 `json:"occupied"`
+	Levels    []LevelAvailability `json:"levels"`
+}
+
+// GetAvailability 汇总各层的可用车位统计
+func (p *ParkingLot) GetAvailability() Availability {
+	var a Availability
+	for _, level := range p.getLevels() {
+		la := level.GetAvailability()
+		a.Total += la.Total
+		a.Available += la.Available
+		a.Occupied += la.Occupied
+		a.Levels = append(a.Levels, la)
+	}
+	return a
+}
+
+// NewAvailabilityHandler 返回提供 GET /availability 的HTTP处理器，以JSON返回当前可用车位
+func NewAvailabilityHandler(lot *ParkingLot) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /availability", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(lot.GetAvailability())
+	})
+	return mux
+}
@@ -0,0 +1,10 @@
+package parkinglot
+
+// NewBus 创建大巴，占用三个相邻的卡车车位
+func NewBus(licensePlate string) *BaseVehicle {
+	return &BaseVehicle{
+		licensePlate:  licensePlate,
+		vehicleType:   BUS,
+		spotsRequired: 3,
+	}
+}
@@ -0,0 +1,129 @@
+package parkinglot
+
+import (
+	"errors"
+	"testing"
+)
+
+// newTruckBayLot 创建一层只有卡车车位的停车场
+func newTruckBayLot(t *testing.T, spots int) *ParkingLot {
+	t.Helper()
+	level, err := NewLevelFromLayout(LevelLayout{Floor: 1, Spots: []SpotGroup{{Type: "truck", Count: spots}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lot := NewParkingLot(1)
+	lot.AddLevel(level)
+	return lot
+}
+
+// 测试大巴占用相邻车位，出场时全部释放
+func TestBusOccupiesContiguousSpots(t *testing.T) {
+	lot := newTruckBayLot(t, 6)
+	entry := NewEntryGate("E1", lot)
+	exit := NewExitGate("X1", lot)
+
+	truck, err := entry.Enter(NewTruck("T1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	bus, err := entry.Enter(NewBus("B1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bus.SpotID() != (SpotID{Floor: 1, Spot: 1}) || bus.SpotsOccupied != 3 {
+		t.Errorf("大巴应占用 L1-001 开始的3个车位，实际: %v %d", bus.SpotID(), bus.SpotsOccupied)
+	}
+	if got := lot.GetAvailability().Available; got != 2 {
+		t.Errorf("可用车位应为 2，实际: %d", got)
+	}
+	if lot.HasSpaceFor(NewBus("B2")) {
+		t.Error("剩余车位不足3个相邻车位")
+	}
+
+	// 释放 L1-000 后空闲车位不相邻，第二辆大巴仍然无法入场
+	if _, err := exit.Exit(truck); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := entry.Enter(NewBus("B2")); !errors.Is(err, ErrNoAvailableSpot) {
+		t.Errorf("期望 ErrNoAvailableSpot，实际: %v", err)
+	}
+
+	if _, err := exit.Exit(bus); err != nil {
+		t.Fatal(err)
+	}
+	if got := lot.GetAvailability().Available; got != 6 {
+		t.Errorf("大巴出场后可用车位应为 6，实际: %d", got)
+	}
+	if _, err := lot.FindVehicle("B1"); !errors.Is(err, ErrVehicleNotFound) {
+		t.Errorf("大巴出场后不应在场: %v", err)
+	}
+}
+
+// 测试维护中的车位会打断相邻车位，以及按中间车位取车时释放整辆车
+func TestMultiSpotRunRules(t *testing.T) {
+	lot := newTruckBayLot(t, 5)
+	if err := lot.CloseSpot(SpotID{Floor: 1, Spot: 1}); err != nil {
+		t.Fatal(err)
+	}
+	trailer := NewTruck("T1")
+	trailer.SetSpotsRequired(2)
+	if !lot.ParkVehicle(trailer) {
+		t.Fatal("带挂车的卡车停车失败")
+	}
+	if id, _ := lot.FindVehicle("T1"); id != (SpotID{Floor: 1, Spot: 2}) {
+		t.Errorf("应跳过维护中的车位停在 L1-002，实际: %v", id)
+	}
+	if lot.ParkVehicle(NewBus("B1")) {
+		t.Error("没有3个相邻车位时大巴不应入场")
+	}
+	if !lot.UnparkVehicleAt(SpotID{Floor: 1, Spot: 3}) {
+		t.Fatal("按车位取车失败")
+	}
+	if got := lot.GetAvailability().Available; got != 4 {
+		t.Errorf("可用车位应为 4，实际: %d", got)
+	}
+	if !lot.ParkVehicle(NewBus("B1")) {
+		t.Error("大巴应能停入 L1-002 至 L1-004")
+	}
+}
+
+// 测试恢复持久化状态时大巴仍占用全部车位
+func TestRestoreMultiSpotVehicle(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lot := newTruckBayLot(t, 4)
+	if err := lot.EnablePersistence(store); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewEntryGate("E1", lot).Enter(NewBus("B1")); err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+
+	store, err = NewFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	restored, err := RestoreParkingLot(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := restored.GetAvailability().Available; got != 1 {
+		t.Errorf("恢复后可用车位应为 1，实际: %d", got)
+	}
+	ticket, err := restored.GetTicket("T-000001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewExitGate("X1", restored).Exit(ticket); err != nil {
+		t.Fatal(err)
+	}
+	if got := restored.GetAvailability().Available; got != 4 {
+		t.Errorf("出场后可用车位应为 4，实际: %d", got)
+	}
+}
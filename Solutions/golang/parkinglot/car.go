@@ -5,4 +5,13 @@ func NewCar(licensePlate string) *BaseVehicle {
 		licensePlate: licensePlate,
 		vehicleType:  CAR,
 	}
-}
\ No newline at end of file
+}
+
+// NewElectricCar 创建电动汽车
+func NewElectricCar(licensePlate string) *BaseVehicle {
+	return &BaseVehicle{
+		licensePlate: licensePlate,
+		vehicleType:  CAR,
+		electric:     true,
+	}
+}
@@ -0,0 +1,183 @@
+package parkinglot
+
+import (
+	"math"
+	"time"
+)
+
+// Charger 充电桩的电表，根据充电开始时间计算累计输出的电量
+type Charger interface {
+	// Meter 返回从 start 开始充电、需求 requestedKWh 时，到 at 时刻累计输出的电量以及充满的时刻
+	Meter(requestedKWh float64, start, at time.Time) (kwh float64, completed time.Time)
+}
+
+// SimulatedCharger 模拟充电桩，以恒定功率充电直到满足需求电量
+type SimulatedCharger struct {
+	powerKW float64
+}
+
+func NewSimulatedCharger(powerKW float64) *SimulatedCharger {
+	return &SimulatedCharger{powerKW: powerKW}
+}
+
+func (c *SimulatedCharger) Meter(requestedKWh float64, start, at time.Time) (float64, time.Time) {
+	completed := start.Add(time.Duration(requestedKWh / c.powerKW * float64(time.Hour)))
+	if !at.Before(completed) {
+		return requestedKWh, completed
+	}
+	if !at.After(start) {
+		return 0, completed
+	}
+	return c.powerKW * at.Sub(start).Hours(), completed
+}
+
+// ChargingTariff 充电计费：按电量计费，充满后继续占用充电车位超过宽限期按小时收取占位费
+type ChargingTariff struct {
+	PricePerKWh    float64
+	IdleFeePerHour float64
+	IdleGrace      time.Duration
+}
+
+// ChargingSession 一次充电会话，出场或拔枪时结束
+type ChargingSession struct {
+	TicketID      string
+	LicensePlate  string
+	Spot          SpotID
+	RequestedKWh  float64
+	StartTime     time.Time
+	CompletedTime time.Time // 充满的时刻，未充满时为零值
+	EndTime       time.Time // 拔枪或出场的时刻，进行中为零值
+	EnergyKWh     float64
+	EnergyFee     float64
+	IdleFee       float64
+}
+
+// IsActive 充电会话尚未结束时返回true
+func (s *ChargingSession) IsActive() bool {
+	return s.EndTime.IsZero()
+}
+
+// meter 按电表读数和计费规则更新会话到 at 时刻
+func (s *ChargingSession) meter(charger Charger, tariff ChargingTariff, at time.Time) {
+	kwh, completed := charger.Meter(s.RequestedKWh, s.StartTime, at)
+	s.EnergyKWh = kwh
+	s.EnergyFee = math.Round(kwh*tariff.PricePerKWh*100) / 100
+	s.CompletedTime = time.Time{}
+	s.IdleFee = 0
+	if at.Before(completed) {
+		return
+	}
+	s.CompletedTime = completed
+	if idleStart := completed.Add(tariff.IdleGrace); at.After(idleStart) {
+		s.IdleFee = float64(startedHours(idleStart, at)) * tariff.IdleFeePerHour
+	}
+}
+
+// SetCharging 设置充电车位使用的充电桩和充电计费
+func (p *ParkingLot) SetCharging(charger Charger, tariff ChargingTariff) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.charger = charger
+	p.chargingTariff = tariff
+}
+
+func (p *ParkingLot) getCharging() (Charger, ChargingTariff) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.charger, p.chargingTariff
+}
+
+// StartCharging 为停在充电车位上的电动车开始充电，requestedKWh 为需要充入的电量
+func (p *ParkingLot) StartCharging(ticketID string, requestedKWh float64) (*ChargingSession, error) {
+	if requestedKWh <= 0 {
+		return nil, ErrInvalidChargeAmount
+	}
+	j := p.beginChange()
+	defer j.end()
+	p.ticketMu.Lock()
+	defer p.ticketMu.Unlock()
+
+	ticket, ok := p.tickets[ticketID]
+	if !ok {
+		return nil, ErrTicketNotFound
+	}
+	if !ticket.IsActive() {
+		return nil, ErrTicketAlreadyUsed
+	}
+	if ticket.exiting {
+		return nil, ErrExitInProgress
+	}
+	if ticket.spot.GetSpotType() != SpotEVCharging {
+		return nil, ErrNotChargingSpot
+	}
+	if ticket.Charging != nil {
+		return nil, ErrChargingSessionExists
+	}
+	ticket.Charging = &ChargingSession{
+		TicketID:     ticket.ID,
+		LicensePlate: ticket.LicensePlate,
+		Spot:         ticket.SpotID(),
+		RequestedKWh: requestedKWh,
+		StartTime:    p.now(),
+	}
+	if err := j.append(LogRecord{Op: OpCharging, Ticket: ticket.copy()}); err != nil {
+		ticket.Charging = nil
+		return nil, err
+	}
+	copied := *ticket.Charging
+	return &copied, nil
+}
+
+// GetChargingSession 返回充电会话截至当前的电量和费用
+func (p *ParkingLot) GetChargingSession(ticketID string) (*ChargingSession, error) {
+	charger, tariff := p.getCharging()
+	p.ticketMu.Lock()
+	defer p.ticketMu.Unlock()
+
+	ticket, ok := p.tickets[ticketID]
+	if !ok {
+		return nil, ErrTicketNotFound
+	}
+	if ticket.Charging == nil {
+		return nil, ErrNoChargingSession
+	}
+	session := *ticket.Charging
+	if session.IsActive() {
+		session.meter(charger, tariff, p.now())
+	}
+	return &session, nil
+}
+
+// StopCharging 拔枪结束充电，占位费计算到拔枪时刻
+func (p *ParkingLot) StopCharging(ticketID string) (*ChargingSession, error) {
+	charger, tariff := p.getCharging()
+	j := p.beginChange()
+	defer j.end()
+	p.ticketMu.Lock()
+	defer p.ticketMu.Unlock()
+
+	ticket, ok := p.tickets[ticketID]
+	if !ok {
+		return nil, ErrTicketNotFound
+	}
+	if ticket.Charging == nil || !ticket.Charging.IsActive() {
+		return nil, ErrNoChargingSession
+	}
+	if ticket.exiting {
+		return nil, ErrExitInProgress
+	}
+	previous := *ticket.Charging
+	ticket.Charging.finish(charger, tariff, p.now())
+	if err := j.append(LogRecord{Op: OpCharging, Ticket: ticket.copy()}); err != nil {
+		*ticket.Charging = previous
+		return nil, err
+	}
+	copied := *ticket.Charging
+	return &copied, nil
+}
+
+// finish 结束充电会话
+func (s *ChargingSession) finish(charger Charger, tariff ChargingTariff, at time.Time) {
+	s.meter(charger, tariff, at)
+	s.EndTime = at
+}
@@ -0,0 +1,98 @@
+package parkinglot
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// 测试充电会话的电量计量、占位费以及出场时合并计费
+func TestChargingSession(t *testing.T) {
+	clock := time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)
+	lot := NewParkingLot(1)
+	level, err := NewLevelFromLayout(LevelLayout{Floor: 1, Spots: []SpotGroup{
+		{Type: "ev-charging", Count: 1},
+		{Type: "regular", Count: 1},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lot.AddLevel(level)
+	lot.now = func() time.Time { return clock }
+	lot.SetPricingStrategy(NewHourlyPricing(5))
+	lot.SetSpotAssignmentStrategy(NewBestFitStrategy())
+	lot.SetCharging(NewSimulatedCharger(10), ChargingTariff{PricePerKWh: 1.5, IdleFeePerHour: 8, IdleGrace: 30 * time.Minute})
+	gate := NewEntryGate("E1", lot)
+
+	ev, err := gate.Enter(NewElectricCar("EV1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	car, err := gate.Enter(NewCar("C1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lot.StartCharging(car.ID, 10); !errors.Is(err, ErrNotChargingSpot) {
+		t.Errorf("期望 ErrNotChargingSpot，实际: %v", err)
+	}
+	if _, err := lot.StartCharging(ev.ID, 0); !errors.Is(err, ErrInvalidChargeAmount) {
+		t.Errorf("期望 ErrInvalidChargeAmount，实际: %v", err)
+	}
+
+	// 需求20kWh，10kW功率两小时充满
+	if _, err := lot.StartCharging(ev.ID, 20); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lot.StartCharging(ev.ID, 20); !errors.Is(err, ErrChargingSessionExists) {
+		t.Errorf("期望 ErrChargingSessionExists，实际: %v", err)
+	}
+
+	clock = clock.Add(90 * time.Minute)
+	session, err := lot.GetChargingSession(ev.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if session.EnergyKWh != 15 || session.EnergyFee != 22.5 || !session.CompletedTime.IsZero() || session.IdleFee != 0 {
+		t.Errorf("充电中的会话错误: %+v", session)
+	}
+
+	// 11:00充满，宽限30分钟，12:45出场时占位1小时15分钟，按2小时收费
+	clock = time.Date(2024, 3, 4, 12, 45, 0, 0, time.UTC)
+	fee, err := NewExitGate("X1", lot).Exit(ev)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ev, _ = lot.GetTicket(ev.ID)
+	if ev.ParkingFee != 20 || ev.Charging.EnergyKWh != 20 || ev.Charging.EnergyFee != 30 || ev.Charging.IdleFee != 16 {
+		t.Errorf("出场计费错误: 停车费 %.2f，会话 %+v", ev.ParkingFee, ev.Charging)
+	}
+	if fee != 66 || ev.Fee != 66 {
+		t.Errorf("总费用错误，期望：66，实际：%.2f", fee)
+	}
+	if !ev.Charging.EndTime.Equal(clock) || !ev.Charging.CompletedTime.Equal(time.Date(2024, 3, 4, 11, 0, 0, 0, time.UTC)) {
+		t.Errorf("会话时间错误: %+v", ev.Charging)
+	}
+}
+
+// 测试提前拔枪后不再计量
+func TestStopCharging(t *testing.T) {
+	start := time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)
+	charger := NewSimulatedCharger(10)
+	tariff := ChargingTariff{PricePerKWh: 2, IdleFeePerHour: 8}
+	session := &ChargingSession{RequestedKWh: 30, StartTime: start}
+
+	session.finish(charger, tariff, start.Add(time.Hour))
+	if session.EnergyKWh != 10 || session.EnergyFee != 20 || session.IdleFee != 0 || session.IsActive() {
+		t.Errorf("提前拔枪的会话错误: %+v", session)
+	}
+
+	lot := NewParkingLot(1)
+	lot.AddLevel(NewLevel(1, 4))
+	if _, err := lot.StopCharging("T-000404"); !errors.Is(err, ErrTicketNotFound) {
+		t.Errorf("期望 ErrTicketNotFound，实际: %v", err)
+	}
+	ticket, _ := NewEntryGate("E1", lot).Enter(NewCar("C1"))
+	if _, err := lot.StopCharging(ticket.ID); !errors.Is(err, ErrNoChargingSession) {
+		t.Errorf("期望 ErrNoChargingSession，实际: %v", err)
+	}
+}
@@ -0,0 +1,85 @@
+package parkinglot
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// 测试多个入口和出口并发进出时不会重复分配车位
+func TestConcurrentEntryAndExit(t *testing.T) {
+	lot := NewParkingLot(2)
+	lot.SetPricingStrategy(NewHourlyPricing(5))
+	lot.AddLevel(NewLevel(1, 40))
+	lot.AddLevel(NewLevel(2, 40))
+
+	const gates = 8
+	const rounds = 200
+	var occupied sync.Map
+	var wg sync.WaitGroup
+	errs := make(chan error, gates)
+
+	for g := 0; g < gates; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			entry := NewEntryGate(fmt.Sprintf("E%d", g), lot)
+			exit := NewExitGate(fmt.Sprintf("X%d", g), lot)
+			var held []*Ticket
+			for i := 0; i < rounds; i++ {
+				vehicle := NewCar(fmt.Sprintf("G%d-%d", g, i))
+				ticket, err := entry.Enter(vehicle)
+				if err == nil {
+					key := fmt.Sprintf("%d-%d", ticket.Floor, ticket.SpotNumber)
+					if other, loaded := occupied.LoadOrStore(key, ticket.ID); loaded {
+						errs <- fmt.Errorf("车位 %s 被重复分配给 %s 和 %s", key, other, ticket.ID)
+						return
+					}
+					held = append(held, ticket)
+				} else if !errors.Is(err, ErrNoAvailableSpot) {
+					errs <- err
+					return
+				}
+				// 每个入口最多同时占用3个车位，超过后让最早的车辆出场
+				if len(held) > 3 || (err != nil && len(held) > 0) {
+					oldest := held[0]
+					held = held[1:]
+					occupied.Delete(fmt.Sprintf("%d-%d", oldest.Floor, oldest.SpotNumber))
+					if _, err := exit.Exit(oldest); err != nil {
+						errs <- err
+						return
+					}
+				}
+			}
+			for _, ticket := range held {
+				occupied.Delete(fmt.Sprintf("%d-%d", ticket.Floor, ticket.SpotNumber))
+				if _, err := exit.Exit(ticket); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	for _, level := range lot.getLevels() {
+		if level.GetVehicleCount() != 0 {
+			t.Errorf("第%d层仍有%d辆车", level.GetFloor(), level.GetVehicleCount())
+		}
+	}
+
+	// 所有车辆出场后，每层的20个汽车车位都可以重新分配
+	for i := 0; i < 40; i++ {
+		if _, err := NewEntryGate("E", lot).Enter(NewCar(fmt.Sprintf("R%d", i))); err != nil {
+			t.Fatalf("第%d辆车入场失败: %v", i+1, err)
+		}
+	}
+	if _, err := NewEntryGate("E", lot).Enter(NewCar("R40")); !errors.Is(err, ErrNoAvailableSpot) {
+		t.Errorf("期望 ErrNoAvailableSpot，实际: %v", err)
+	}
+}
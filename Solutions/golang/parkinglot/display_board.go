@@ -0,0 +1,72 @@
+package parkinglot
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DisplayBoard 入口处的余位显示屏，订阅停车场事件，每次车辆进出后刷新显示
+type DisplayBoard struct {
+	id          string
+	lot         *ParkingLot
+	out         io.Writer
+	unsubscribe func()
+	mu          sync.Mutex
+}
+
+func NewDisplayBoard(id string, lot *ParkingLot) *DisplayBoard {
+	return &DisplayBoard{id: id, lot: lot}
+}
+
+// Attach 订阅停车场事件，每次事件后将最新余位写入 out
+func (b *DisplayBoard) Attach(out io.Writer) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.out = out
+	b.unsubscribe = b.lot.Subscribe(func(event Event) {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if b.out == nil {
+			return
+		}
+		if event.Type == LotFull {
+			fmt.Fprintf(b.out, "[%s] 车位已满\n", b.id)
+			return
+		}
+		fmt.Fprint(b.out, b.Render())
+	})
+}
+
+// Detach 取消订阅
+func (b *DisplayBoard) Detach() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.unsubscribe != nil {
+		b.unsubscribe()
+		b.unsubscribe = nil
+	}
+	b.out = nil
+}
+
+// Render 返回当前余位的文本，读取增量统计，不遍历车位
+func (b *DisplayBoard) Render() string {
+	availability := b.lot.GetAvailability()
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "[%s] 剩余车位: %d/%d\n", b.id, availability.Available, availability.Total)
+	for _, level := range availability.Levels {
+		types := make([]string, 0, len(level.ByType))
+		for name := range level.ByType {
+			types = append(types, name)
+		}
+		sort.Strings(types)
+		parts := make([]string, 0, len(types))
+		for _, name := range types {
+			parts = append(parts, fmt.Sprintf("%s %d", name, level.ByType[name].Available))
+		}
+		fmt.Fprintf(&sb, "  %d层: %d/%d (%s)\n", level.Floor, level.Available, level.Total, strings.Join(parts, ", "))
+	}
+	return sb.String()
+}
@@ -0,0 +1,48 @@
+package parkinglot
+
+import "errors"
+
+var (
+	ErrNoAvailableSpot       = errors.New("no available spot for vehicle")
+	ErrTicketNotFound        = errors.New("ticket not found")
+	ErrTicketAlreadyUsed     = errors.New("ticket has already been used to exit")
+	ErrDuplicateVehicle      = errors.New("vehicle with this license plate is already parked")
+	ErrVehicleNotFound       = errors.New("vehicle not found")
+	ErrSpotNotFound          = errors.New("spot not found")
+	ErrSpotOccupied          = errors.New("spot is occupied")
+	ErrInvalidLayout         = errors.New("invalid layout")
+	ErrSpotNotCompatible     = errors.New("selected spot cannot fit the vehicle")
+	ErrNotChargingSpot       = errors.New("vehicle is not parked at an EV charging spot")
+	ErrChargingSessionExists = errors.New("charging session already started")
+	ErrNoChargingSession     = errors.New("no active charging session")
+	ErrInvalidReservation    = errors.New("invalid reservation time window")
+	ErrNoReservationCapacity = errors.New("no spot of this type available for the requested time")
+	ErrReservationNotFound   = errors.New("reservation not found")
+	ErrReservationNotActive  = errors.New("reservation is no longer active")
+	ErrReservationMismatch   = errors.New("vehicle does not match reservation")
+	ErrReservationNotStarted = errors.New("reservation has not started yet")
+	ErrDuplicateFacility     = errors.New("facility already exists in network")
+	ErrFacilityNotFound      = errors.New("facility not found")
+	ErrInvalidChargeAmount   = errors.New("requested energy must be positive")
+	ErrLevelNotFound         = errors.New("level not found")
+	ErrPersistence           = errors.New("failed to persist parking lot state")
+	ErrPersistenceDisabled   = errors.New("persistence is not enabled")
+	ErrCorruptState          = errors.New("persisted parking lot state is corrupt")
+	ErrInvalidAmount         = errors.New("amount must be positive")
+	ErrDuplicateMember       = errors.New("member account already exists")
+	ErrPlateRegistered       = errors.New("license plate is already registered to a member")
+	ErrMemberNotFound        = errors.New("member not found")
+	ErrInsufficientBalance   = errors.New("insufficient prepaid balance")
+	ErrPassAlreadyActive     = errors.New("monthly pass already active for this month")
+	ErrPassPriceNotSet       = errors.New("monthly pass price has not been set")
+	ErrValidationNotFound    = errors.New("validation code not found")
+	ErrValidationUsed        = errors.New("validation code has already been used")
+	ErrValidationExpired     = errors.New("validation code has expired")
+	ErrTicketValidated       = errors.New("ticket has already been validated")
+	ErrInsufficientPayment   = errors.New("tendered amount is less than the fee")
+	ErrPaymentDeclined       = errors.New("payment declined")
+	ErrNotCoveredByPass      = errors.New("fee is not fully covered by a monthly pass")
+	ErrReceiptNotFound       = errors.New("receipt not found")
+	ErrExitInProgress        = errors.New("ticket is being settled at an exit gate")
+	ErrInvalidSimulation     = errors.New("invalid simulation config")
+)
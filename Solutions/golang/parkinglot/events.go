@@ -0,0 +1,197 @@
+package parkinglot
+
+import (
+	"sync"
+	"time"
+)
+
+// EventType 停车场事件类型
+type EventType int
+
+const (
+	VehicleParked EventType = iota
+	VehicleLeft
+	LevelFull
+	LotFull
+)
+
+func (t EventType) String() string {
+	switch t {
+	case VehicleParked:
+		return "VehicleParked"
+	case VehicleLeft:
+		return "VehicleLeft"
+	case LevelFull:
+		return "LevelFull"
+	case LotFull:
+		return "LotFull"
+	}
+	return "Unknown"
+}
+
+// Event 停车场事件，LevelFull 和 LotFull 事件不含车辆信息
+type Event struct {
+	Type         EventType
+	Time         time.Time
+	LicensePlate string
+	VehicleType  VehicleType
+	Floor        int
+	Spot         SpotID
+	Seq          uint64  // 事件序号，按停车场状态变化的顺序递增
+	TicketID     string  // 凭票入场或出场时的停车票号
+	Fee          float64 // 凭票出场时的应付总额
+	Paid         float64 // 付款出场时收据上实收的金额，未付款出场为0
+}
+
+// EventHandler 事件订阅者
+type EventHandler func(Event)
+
+// eventBus 同步地将事件分发给所有订阅者
+// 发布方在修改状态的锁内用 reserve 取得批次序号，释放锁后再发布，
+// 事件按批次序号的顺序分发：先到的后序批次等待前序批次发布后一起分发
+type eventBus struct {
+	handlers map[int]EventHandler
+	nextID   int
+	mu       sync.RWMutex
+
+	reserved   uint64             // 已分配的批次序号
+	next       uint64             // 下一个待分发的批次序号
+	ready      map[uint64][]Event // 已发布但尚未轮到分发的批次
+	seq        uint64             // 已分发的事件数
+	delivering bool               // 有goroutine正在分发
+	orderMu    sync.Mutex
+}
+
+func newEventBus() *eventBus {
+	return &eventBus{
+		handlers: make(map[int]EventHandler),
+		next:     1,
+		ready:    make(map[uint64][]Event),
+	}
+}
+
+func (b *eventBus) subscribe(handler EventHandler) func() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	id := b.nextID
+	b.nextID++
+	b.handlers[id] = handler
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(b.handlers, id)
+	}
+}
+
+// reserve 分配批次序号，调用方需在修改状态的锁内调用，并且之后必须调用 publish
+func (b *eventBus) reserve() uint64 {
+	b.orderMu.Lock()
+	defer b.orderMu.Unlock()
+	b.reserved++
+	return b.reserved
+}
+
+// publish 发布一个批次的事件，轮到该批次时依次分发
+// 前序批次尚未发布时立即返回，事件由发布前序批次的goroutine分发
+func (b *eventBus) publish(batch uint64, events ...Event) {
+	b.orderMu.Lock()
+	b.ready[batch] = events
+	if b.delivering {
+		b.orderMu.Unlock()
+		return
+	}
+	b.delivering = true
+	for {
+		events, ok := b.ready[b.next]
+		if !ok {
+			b.delivering = false
+			b.orderMu.Unlock()
+			return
+		}
+		delete(b.ready, b.next)
+		b.next++
+		for i := range events {
+			b.seq++
+			events[i].Seq = b.seq
+		}
+		b.orderMu.Unlock()
+		b.dispatch(events)
+		b.orderMu.Lock()
+	}
+}
+
+func (b *eventBus) dispatch(events []Event) {
+	b.mu.RLock()
+	handlers := make([]EventHandler, 0, len(b.handlers))
+	for _, handler := range b.handlers {
+		handlers = append(handlers, handler)
+	}
+	b.mu.RUnlock()
+	for _, event := range events {
+		for _, handler := range handlers {
+			handler(event)
+		}
+	}
+}
+
+// Subscribe 订阅停车场事件，返回取消订阅的函数
+// 事件在停车或取车的调用方goroutine中同步分发，订阅者不应阻塞
+func (p *ParkingLot) Subscribe(handler EventHandler) func() {
+	return p.events.subscribe(handler)
+}
+
+// publishParked 发布停车事件，车位所在楼层或整个停车场停满时一并发布
+// batch 为停车时在锁内取得的批次序号
+func (p *ParkingLot) publishParked(batch uint64, vehicle Vehicle, level *Level, spot *ParkingSpot, ticket *Ticket) {
+	now := p.now()
+	event := Event{
+		Type:         VehicleParked,
+		Time:         now,
+		LicensePlate: vehicle.GetLicensePlate(),
+		VehicleType:  vehicle.GetType(),
+		Floor:        level.GetFloor(),
+		Spot:         level.GetSpotID(spot),
+	}
+	if ticket != nil {
+		event.TicketID = ticket.ID
+	}
+	events := []Event{event}
+	if level.GetAvailableSpots() == 0 {
+		events = append(events, Event{Type: LevelFull, Time: now, Floor: level.GetFloor()})
+		if p.isFull() {
+			events = append(events, Event{Type: LotFull, Time: now})
+		}
+	}
+	p.events.publish(batch, events...)
+}
+
+func (p *ParkingLot) isFull() bool {
+	for _, l := range p.getLevels() {
+		if l.GetAvailableSpots() > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// publishLeft 发布取车事件，凭票出场时带上票号和费用，付款出场时带上实收金额
+// batch 为取车时在锁内取得的批次序号
+func (p *ParkingLot) publishLeft(batch uint64, vehicle Vehicle, level *Level, spot *ParkingSpot, ticket *Ticket, receipt *Receipt) {
+	event := Event{
+		Type:         VehicleLeft,
+		Time:         p.now(),
+		LicensePlate: vehicle.GetLicensePlate(),
+		VehicleType:  vehicle.GetType(),
+		Floor:        level.GetFloor(),
+		Spot:         level.GetSpotID(spot),
+	}
+	if ticket != nil {
+		event.TicketID = ticket.ID
+		event.Fee = ticket.Fee
+		event.Time = ticket.ExitTime
+	}
+	if receipt != nil {
+		event.Paid = receipt.Total
+	}
+	p.events.publish(batch, event)
+}
@@ -0,0 +1,163 @@
+package parkinglot
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// 测试停车和取车事件，以及楼层和停车场停满事件
+func TestParkingEvents(t *testing.T) {
+	lot := NewParkingLot(2)
+	lot.AddLevel(&Level{floor: 1, parkingSpots: []*ParkingSpot{NewParkingSpot(0, CAR)}})
+	lot.AddLevel(&Level{floor: 2, parkingSpots: []*ParkingSpot{NewParkingSpot(0, CAR)}})
+
+	var events []Event
+	unsubscribe := lot.Subscribe(func(event Event) {
+		events = append(events, event)
+	})
+
+	gate := NewEntryGate("E1", lot)
+	first, err := gate.Enter(NewCar("A1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gate.Enter(NewCar("A2")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewExitGate("X1", lot).Exit(first); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []EventType{VehicleParked, LevelFull, VehicleParked, LevelFull, LotFull, VehicleLeft}
+	if len(events) != len(want) {
+		t.Fatalf("事件数量错误，期望：%d，实际：%d (%v)", len(want), len(events), events)
+	}
+	for i, event := range events {
+		if event.Type != want[i] {
+			t.Errorf("第%d个事件期望 %v，实际 %v", i+1, want[i], event.Type)
+		}
+	}
+	if events[2].LicensePlate != "A2" || events[2].Spot != (SpotID{Floor: 2, Spot: 0}) {
+		t.Errorf("停车事件信息错误: %+v", events[2])
+	}
+	if events[5].LicensePlate != "A1" || events[5].Floor != 1 {
+		t.Errorf("取车事件信息错误: %+v", events[5])
+	}
+
+	unsubscribe()
+	lot.UnparkVehicleAt(SpotID{Floor: 2, Spot: 0})
+	if len(events) != len(want) {
+		t.Error("取消订阅后仍收到事件")
+	}
+}
+
+// 测试并发进出时事件按状态变化的顺序分发：同一车位的停车和取车交替出现，序号连续递增
+func TestEventsOrderedUnderConcurrency(t *testing.T) {
+	lot := NewParkingLot(1)
+	lot.AddLevel(NewLevel(1, 4))
+
+	var mu sync.Mutex
+	var events []Event
+	lot.Subscribe(func(event Event) {
+		mu.Lock()
+		events = append(events, event)
+		mu.Unlock()
+	})
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			entry := NewEntryGate("E", lot)
+			exit := NewExitGate("X", lot)
+			for i := 0; i < 50; i++ {
+				ticket, err := entry.Enter(NewCar(fmt.Sprintf("G%d-%d", g, i)))
+				if err != nil {
+					continue
+				}
+				exit.Exit(ticket)
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	occupant := make(map[SpotID]string)
+	for i, event := range events {
+		if event.Seq != uint64(i+1) {
+			t.Fatalf("第%d个事件序号错误: %d", i+1, event.Seq)
+		}
+		switch event.Type {
+		case VehicleParked:
+			if plate, ok := occupant[event.Spot]; ok {
+				t.Fatalf("车位 %s 上的 %s 尚未取车，%s 的停车事件已到达", event.Spot, plate, event.LicensePlate)
+			}
+			occupant[event.Spot] = event.LicensePlate
+		case VehicleLeft:
+			if occupant[event.Spot] != event.LicensePlate {
+				t.Fatalf("%s 的取车事件早于停车事件", event.LicensePlate)
+			}
+			delete(occupant, event.Spot)
+		}
+	}
+	if len(occupant) != 0 {
+		t.Errorf("所有车辆出场后仍有未配对的停车事件: %v", occupant)
+	}
+}
+
+// 测试增量维护的可用车位统计、HTTP接口和余位显示屏
+func TestAvailabilityCountersAndEndpoint(t *testing.T) {
+	lot := NewParkingLot(1)
+	lot.AddLevel(NewLevel(1, 8)) // 0-3为regular，4-5为motorcycle，6-7为truck
+
+	board := NewDisplayBoard("E1", lot)
+	var display strings.Builder
+	board.Attach(&display)
+
+	gate := NewEntryGate("E1", lot)
+	gate.Enter(NewCar("C1"))
+	gate.Enter(NewTruck("T1"))
+	if err := lot.getLevels()[0].CloseSpot(5); err != nil {
+		t.Fatal(err)
+	}
+
+	availability := lot.GetAvailability()
+	if availability.Total != 8 || availability.Available != 5 || availability.Occupied != 2 {
+		t.Errorf("可用车位统计错误: %+v", availability)
+	}
+	byType := availability.Levels[0].ByType
+	if byType["regular"].Available != 3 || byType["motorcycle"].Available != 1 || byType["truck"] != (SpotTypeAvailability{Total: 2, Available: 1}) {
+		t.Errorf("按类型统计错误: %+v", byType)
+	}
+	if !strings.Contains(display.String(), "[E1] 剩余车位: 6/8") {
+		t.Errorf("显示屏未刷新: %q", display.String())
+	}
+	if !strings.Contains(board.Render(), "1层: 5/8 (motorcycle 1, regular 3, truck 1)") {
+		t.Errorf("显示屏内容错误: %q", board.Render())
+	}
+
+	server := httptest.NewServer(NewAvailabilityHandler(lot))
+	defer server.Close()
+	resp, err := http.Get(server.URL + "/availability")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "application/json" {
+		t.Fatalf("HTTP响应错误: %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	var got Availability
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Available != 5 || len(got.Levels) != 1 || got.Levels[0].ByType["regular"].Available != 3 {
+		t.Errorf("HTTP返回的可用车位错误: %+v", got)
+	}
+
+	board.Detach()
+}
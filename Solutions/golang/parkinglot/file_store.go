@@ -0,0 +1,153 @@
+package parkinglot
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+const (
+	snapshotFileName = "snapshot.json"
+	logFileName      = "wal.jsonl"
+)
+
+// FileStore 基于文件的存储：快照保存在 snapshot.json，预写日志每行一条记录保存在 wal.jsonl
+type FileStore struct {
+	dir string
+	log *os.File
+	mu  sync.Mutex
+}
+
+// NewFileStore 在指定目录中打开或创建存储
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	log, err := os.OpenFile(filepath.Join(dir, logFileName), os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &FileStore{dir: dir, log: log}, nil
+}
+
+// Load 读取快照和日志；最后一行写到一半（如写入时断电）时丢弃该行，日志中间损坏时返回 ErrCorruptState
+func (s *FileStore) Load() (*Snapshot, []LogRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snapshot, err := s.loadSnapshot()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if _, err := s.log.Seek(0, io.SeekStart); err != nil {
+		return nil, nil, err
+	}
+	var records []LogRecord
+	var offset int64
+	reader := bufio.NewReader(s.log)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return nil, nil, err
+		}
+		if err == io.EOF {
+			if len(bytes.TrimSpace(line)) > 0 {
+				// 没有换行结尾的记录未写完，截掉以免之后追加的记录接在它后面
+				if err := s.log.Truncate(offset); err != nil {
+					return nil, nil, err
+				}
+			}
+			break
+		}
+		var record LogRecord
+		if err := json.Unmarshal(line, &record); err != nil {
+			return nil, nil, fmt.Errorf("%w: log offset %d: %v", ErrCorruptState, offset, err)
+		}
+		records = append(records, record)
+		offset += int64(len(line))
+	}
+	return snapshot, records, nil
+}
+
+func (s *FileStore) loadSnapshot() (*Snapshot, error) {
+	data, err := os.ReadFile(filepath.Join(s.dir, snapshotFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var snapshot Snapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, fmt.Errorf("%w: snapshot: %v", ErrCorruptState, err)
+	}
+	return &snapshot, nil
+}
+
+// Append 追加一条日志并同步到磁盘
+func (s *FileStore) Append(record LogRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.log.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	return s.log.Sync()
+}
+
+// SaveSnapshot 先写临时文件再重命名替换快照，然后清空日志
+// 重命名后、清空日志前崩溃时，恢复会按序号跳过快照已包含的日志
+func (s *FileStore) SaveSnapshot(snapshot *Snapshot) error {
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path := filepath.Join(s.dir, snapshotFileName)
+	tmp, err := os.CreateTemp(s.dir, snapshotFileName+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+	if dir, err := os.Open(s.dir); err == nil {
+		dir.Sync()
+		dir.Close()
+	}
+	if err := s.log.Truncate(0); err != nil {
+		return err
+	}
+	return s.log.Sync()
+}
+
+// Close 关闭日志文件
+func (s *FileStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.log.Close()
+}
@@ -0,0 +1,58 @@
+package parkinglot
+
+// EntryGate 入口闸机，为进场车辆分配车位并签发停车票
+type EntryGate struct {
+	id  string
+	lot *ParkingLot
+}
+
+func NewEntryGate(id string, lot *ParkingLot) *EntryGate {
+	return &EntryGate{id: id, lot: lot}
+}
+
+func (g *EntryGate) GetID() string {
+	return g.id
+}
+
+// Enter 车辆进场，车位已满时返回 ErrNoAvailableSpot
+func (g *EntryGate) Enter(vehicle Vehicle) (*Ticket, error) {
+	return g.lot.issueTicket(vehicle, g.id)
+}
+
+// EnterWithReservation 预约车辆入场，停入为预约保留的车位
+func (g *EntryGate) EnterWithReservation(vehicle Vehicle, reservationID string) (*Ticket, error) {
+	return g.lot.checkIn(vehicle, reservationID, g.id)
+}
+
+// ExitGate 出口闸机，凭停车票计算费用并释放车位
+type ExitGate struct {
+	id  string
+	lot *ParkingLot
+}
+
+func NewExitGate(id string, lot *ParkingLot) *ExitGate {
+	return &ExitGate{id: id, lot: lot}
+}
+
+func (g *ExitGate) GetID() string {
+	return g.id
+}
+
+// Exit 车辆出场，返回应付费用
+func (g *ExitGate) Exit(ticket *Ticket) (float64, error) {
+	closed, _, err := g.lot.closeTicket(ticket.ID, g.id, nil)
+	if err != nil {
+		return 0, err
+	}
+	return closed.Fee, nil
+}
+
+// ExitWithPayment 收款后放行车辆并返回收据，收款失败时车辆不能出场
+func (g *ExitGate) ExitWithPayment(ticket *Ticket, payment Payment) (*Receipt, error) {
+	_, receipt, err := g.lot.closeTicket(ticket.ID, g.id, &payment)
+	if err != nil {
+		return nil, err
+	}
+	copied := *receipt
+	return &copied, nil
+}
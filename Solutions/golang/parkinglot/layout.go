@@ -0,0 +1,119 @@
+package parkinglot
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// SpotGroup 布局中同一类型的一组车位
+type SpotGroup struct {
+	Type  string `json:"type" yaml:"type"`
+	Count int    `json:"count" yaml:"count"`
+}
+
+// LevelLayout 单层的车位布局
+type LevelLayout struct {
+	Floor int         `json:"floor" yaml:"floor"`
+	Spots []SpotGroup `json:"spots" yaml:"spots"`
+}
+
+// LotLayout 整个停车场的布局
+type LotLayout struct {
+	Levels []LevelLayout `json:"levels" yaml:"levels"`
+}
+
+// Validate 检查单层布局：车位类型有效、数量为正且至少有一个车位
+func (l LevelLayout) Validate() error {
+	total := 0
+	for _, group := range l.Spots {
+		if _, err := ParseSpotType(group.Type); err != nil {
+			return fmt.Errorf("%w: floor %d: %v", ErrInvalidLayout, l.Floor, err)
+		}
+		if group.Count <= 0 {
+			return fmt.Errorf("%w: floor %d: %s spot count must be positive", ErrInvalidLayout, l.Floor, group.Type)
+		}
+		total += group.Count
+	}
+	if total == 0 {
+		return fmt.Errorf("%w: floor %d has no spots", ErrInvalidLayout, l.Floor)
+	}
+	return nil
+}
+
+// Validate 检查停车场布局：至少一层且楼层号不重复
+func (l *LotLayout) Validate() error {
+	if len(l.Levels) == 0 {
+		return fmt.Errorf("%w: no levels", ErrInvalidLayout)
+	}
+	floors := make(map[int]bool)
+	for _, level := range l.Levels {
+		if floors[level.Floor] {
+			return fmt.Errorf("%w: duplicate floor %d", ErrInvalidLayout, level.Floor)
+		}
+		floors[level.Floor] = true
+		if err := level.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// BuildLevels 按布局创建所有停车层
+func (l *LotLayout) BuildLevels() ([]*Level, error) {
+	if err := l.Validate(); err != nil {
+		return nil, err
+	}
+	levels := make([]*Level, 0, len(l.Levels))
+	for _, layout := range l.Levels {
+		level, err := NewLevelFromLayout(layout)
+		if err != nil {
+			return nil, err
+		}
+		levels = append(levels, level)
+	}
+	return levels, nil
+}
+
+// ParseLayoutJSON 解析JSON格式的布局并校验
+func ParseLayoutJSON(data []byte) (*LotLayout, error) {
+	var layout LotLayout
+	if err := json.Unmarshal(data, &layout); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidLayout, err)
+	}
+	if err := layout.Validate(); err != nil {
+		return nil, err
+	}
+	return &layout, nil
+}
+
+// ParseLayoutYAML 解析YAML格式的布局并校验
+func ParseLayoutYAML(data []byte) (*LotLayout, error) {
+	var layout LotLayout
+	if err := yaml.Unmarshal(data, &layout); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidLayout, err)
+	}
+	if err := layout.Validate(); err != nil {
+		return nil, err
+	}
+	return &layout, nil
+}
+
+// LoadLayoutFile 从文件加载布局，根据扩展名（.json、.yaml、.yml）选择格式
+func LoadLayoutFile(path string) (*LotLayout, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return ParseLayoutJSON(data)
+	case ".yaml", ".yml":
+		return ParseLayoutYAML(data)
+	}
+	return nil, fmt.Errorf("%w: unsupported layout file %s", ErrInvalidLayout, path)
+}
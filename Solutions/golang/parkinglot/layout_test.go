@@ -0,0 +1,146 @@
+package parkinglot
+
+import (
+	"errors"
+	"testing"
+)
+
+// 测试从JSON和YAML文件加载布局
+func TestLoadLayoutFile(t *testing.T) {
+	for _, path := range []string{"testdata/layout.json", "testdata/layout.yaml"} {
+		layout, err := LoadLayoutFile(path)
+		if err != nil {
+			t.Fatalf("%s: 加载布局失败: %v", path, err)
+		}
+		levels, err := layout.BuildLevels()
+		if err != nil {
+			t.Fatalf("%s: 创建停车层失败: %v", path, err)
+		}
+		if len(levels) != 2 || levels[0].GetTotalSpots() != 16 || levels[1].GetTotalSpots() != 12 {
+			t.Fatalf("%s: 车位数量错误", path)
+		}
+		// 车位按布局顺序编号
+		if got := levels[0].GetSpot(2).GetSpotType(); got != SpotEVCharging {
+			t.Errorf("%s: 1层2号车位类型错误: %v", path, got)
+		}
+		if got := levels[1].GetSpot(11).GetSpotType(); got != SpotTruck {
+			t.Errorf("%s: 2层11号车位类型错误: %v", path, got)
+		}
+	}
+}
+
+// 测试布局校验
+func TestLayoutValidation(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"没有楼层", `{"levels": []}`},
+		{"未知车位类型", `{"levels": [{"floor": 1, "spots": [{"type": "helipad", "count": 1}]}]}`},
+		{"数量非正", `{"levels": [{"floor": 1, "spots": [{"type": "compact", "count": 0}]}]}`},
+		{"没有车位", `{"levels": [{"floor": 1, "spots": []}]}`},
+		{"楼层重复", `{"levels": [{"floor": 1, "spots": [{"type": "compact", "count": 1}]}, {"floor": 1, "spots": [{"type": "compact", "count": 1}]}]}`},
+		{"格式错误", `{"levels": [`},
+	}
+	for _, tt := range tests {
+		if _, err := ParseLayoutJSON([]byte(tt.data)); !errors.Is(err, ErrInvalidLayout) {
+			t.Errorf("%s: 期望 ErrInvalidLayout，实际: %v", tt.name, err)
+		}
+	}
+
+	lot := NewParkingLot(1)
+	lot.AddLevel(NewLevel(1, 4))
+	layout, _ := ParseLayoutYAML([]byte("levels:\n  - floor: 1\n    spots:\n      - type: regular\n        count: 2\n"))
+	if err := lot.ApplyLayout(layout); !errors.Is(err, ErrInvalidLayout) {
+		t.Errorf("楼层与已有楼层重复，期望 ErrInvalidLayout，实际: %v", err)
+	}
+}
+
+// 测试专用车位只对电动车和持证车辆开放
+func TestSpecialSpotTypes(t *testing.T) {
+	lot := NewParkingLot(1)
+	layout, err := LoadLayoutFile("testdata/layout.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := lot.ApplyLayout(layout); err != nil {
+		t.Fatal(err)
+	}
+	lot.SetSpotAssignmentStrategy(NewBestFitStrategy())
+	gate := NewEntryGate("E1", lot)
+
+	permitCar := NewCar("P1")
+	permitCar.SetDisabledPermit(true)
+	tests := []struct {
+		vehicle  Vehicle
+		wantType SpotType
+	}{
+		{permitCar, SpotHandicapped},
+		{NewElectricCar("EV1"), SpotEVCharging},
+		{NewCar("C1"), SpotCompact},
+		{NewMotorcycle("M1"), SpotMotorcycle},
+		{NewTruck("T1"), SpotTruck},
+	}
+	for _, tt := range tests {
+		ticket, err := gate.Enter(tt.vehicle)
+		if err != nil {
+			t.Fatalf("%s 入场失败: %v", tt.vehicle.GetLicensePlate(), err)
+		}
+		if got := ticket.spot.GetSpotType(); got != tt.wantType {
+			t.Errorf("%s 期望停入 %v 车位，实际: %v", tt.vehicle.GetLicensePlate(), tt.wantType, got)
+		}
+	}
+
+	spot := NewParkingSpotOfType(0, SpotHandicapped)
+	if spot.CanFit(NewCar("C2")) || spot.CanFit(NewElectricCar("EV2")) {
+		t.Error("无障碍车位不应允许无许可的车辆")
+	}
+	if NewParkingSpotOfType(0, SpotEVCharging).CanFit(NewCar("C2")) {
+		t.Error("充电车位不应允许燃油车")
+	}
+}
+
+// 测试运行时增加、关闭和重新开放车位
+func TestAddAndCloseSpots(t *testing.T) {
+	level, err := NewLevelFromLayout(LevelLayout{Floor: 1, Spots: []SpotGroup{{Type: "regular", Count: 2}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := level.CloseSpot(0); err != nil {
+		t.Fatalf("关闭车位失败: %v", err)
+	}
+	if level.GetAvailableSpots() != 1 {
+		t.Errorf("关闭后可用车位错误，期望：1，实际：%d", level.GetAvailableSpots())
+	}
+	if spot := level.NextFreeSpot(SpotRegular); spot == nil || spot.GetSpotNumber() != 1 {
+		t.Errorf("关闭的车位仍被分配")
+	}
+	if !level.ParkVehicle(NewCar("C1")) {
+		t.Fatal("停车失败")
+	}
+	if level.ParkVehicle(NewCar("C2")) {
+		t.Error("关闭的车位仍可停车")
+	}
+	if err := level.CloseSpot(1); !errors.Is(err, ErrSpotOccupied) {
+		t.Errorf("期望 ErrSpotOccupied，实际: %v", err)
+	}
+	if err := level.CloseSpot(9); !errors.Is(err, ErrSpotNotFound) {
+		t.Errorf("期望 ErrSpotNotFound，实际: %v", err)
+	}
+
+	if err := level.ReopenSpot(0); err != nil {
+		t.Fatalf("重新开放车位失败: %v", err)
+	}
+	if !level.ParkVehicle(NewCar("C2")) {
+		t.Error("重新开放后停车失败")
+	}
+
+	spot := level.AddSpot(SpotLarge)
+	if spot.GetSpotNumber() != 2 || level.GetTotalSpots() != 3 {
+		t.Errorf("新增车位错误: 车位号 %d，总数 %d", spot.GetSpotNumber(), level.GetTotalSpots())
+	}
+	if level.NextFreeSpot(SpotLarge) != spot {
+		t.Error("新增车位未加入空闲车位索引")
+	}
+}
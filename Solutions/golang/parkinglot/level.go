@@ -1,8 +1,16 @@
 package parkinglot
 
+import (
+	"sort"
+	"sync"
+)
+
 type Level struct {
 	floor        int
 	parkingSpots []*ParkingSpot
+	free         freeSpots   // 空闲车位索引，首次使用时建立
+	counts       *spotCounts // 按车位类型统计的车位数，随停车和取车增量维护
+	mu           sync.Mutex  // 保护 parkingSpots、free 和 counts
 }
 
 func NewLevel(floor, numSpots int) *Level {
@@ -24,53 +32,388 @@ func NewLevel(floor, numSpots int) *Level {
 		level.parkingSpots[i] = NewParkingSpot(i, spotType)
 	}
 
+	level.ensureIndex()
 	return level
 }
 
+// NewLevelFromLayout 按布局创建停车层，车位按布局中列出的顺序从0开始编号
+func NewLevelFromLayout(layout LevelLayout) (*Level, error) {
+	if err := layout.Validate(); err != nil {
+		return nil, err
+	}
+	level := &Level{floor: layout.Floor}
+	for _, group := range layout.Spots {
+		spotType, _ := ParseSpotType(group.Type)
+		for i := 0; i < group.Count; i++ {
+			level.parkingSpots = append(level.parkingSpots, NewParkingSpotOfType(len(level.parkingSpots), spotType))
+		}
+	}
+	level.ensureIndex()
+	return level, nil
+}
+
 func (l *Level) ParkVehicle(vehicle Vehicle) bool {
-	for i, spot := range l.parkingSpots {
-		if spot.IsAvailable() && spot.GetVehicleType() == vehicle.GetType() {
-			return l.parkingSpots[i].ParkVehicle(vehicle)
+	return l.parkVehicle(vehicle) != nil
+}
+
+// parkVehicle 将车辆停入本层车位号最小的匹配空闲车位，返回该车位，没有车位时返回nil
+// 从空闲车位堆中分配，复杂度 O(log n)
+func (l *Level) parkVehicle(vehicle Vehicle) *ParkingSpot {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.ensureIndex()
+
+	if n := spotsRequired(vehicle); n > 1 {
+		if run := l.claimRunLocked(l.findRunLocked(vehicle, n, nil), vehicle); run != nil {
+			return run[0]
+		}
+		return nil
+	}
+
+	for {
+		spot := l.free.pop(SpotTypeFor(vehicle.GetType()))
+		if spot == nil {
+			return nil
+		}
+		if spot.ParkVehicle(vehicle) {
+			l.counts.park(spot.GetSpotType())
+			return spot
+		}
+	}
+}
+
+// NextFreeSpot 返回本层指定类型中车位号最小的空闲车位，不占用该车位，没有时返回nil
+func (l *Level) NextFreeSpot(spotType SpotType) *ParkingSpot {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.ensureIndex()
+	return l.free.peek(spotType)
+}
+
+// claimSpot 将车辆停入分配策略选中的车位，车位已被其他入口占用时返回false
+func (l *Level) claimSpot(spot *ParkingSpot, vehicle Vehicle) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.ensureIndex()
+
+	if !spot.ParkVehicle(vehicle) {
+		return false
+	}
+	l.free.remove(spot)
+	l.counts.park(spot.GetSpotType())
+	return true
+}
+
+// holdSpot 为预约保留一个指定类型的空闲车位，没有空闲车位时返回nil
+func (l *Level) holdSpot(spotType SpotType, reservationID string) *ParkingSpot {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.ensureIndex()
+
+	for {
+		spot := l.free.pop(spotType)
+		if spot == nil {
+			return nil
+		}
+		if spot.hold(reservationID) {
+			l.counts.hold(spotType)
+			return spot
 		}
 	}
+}
+
+// releaseHold 取消预约保留，车位重新开放给其他车辆
+func (l *Level) releaseHold(spot *ParkingSpot, reservationID string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.ensureIndex()
 
-	return false
+	if spot.unhold(reservationID) {
+		l.free.push(spot)
+		l.counts.unhold(spot.GetSpotType())
+	}
+}
+
+// claimHeldSpot 预约车辆停入为其保留的车位
+func (l *Level) claimHeldSpot(spot *ParkingSpot, vehicle Vehicle, reservationID string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.ensureIndex()
+
+	if !spot.parkReserved(vehicle, reservationID) {
+		return false
+	}
+	l.counts.unhold(spot.GetSpotType())
+	l.counts.park(spot.GetSpotType())
+	return true
+}
+
+// totalOfType 返回本层指定类型的车位总数
+func (l *Level) totalOfType(spotType SpotType) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.ensureIndex()
+	return l.counts.totalOfType(spotType)
+}
+
+// releaseSpot 释放车位并放回空闲车位索引，返回原来停放的车辆
+// expected 不为nil时只释放停放着该车辆的车位
+func (l *Level) releaseSpot(spot *ParkingSpot, expected Vehicle) Vehicle {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.ensureIndex()
+
+	vehicle := spot.release(expected)
+	if vehicle != nil {
+		l.free.push(spot)
+		l.counts.release(spot.GetSpotType())
+		// 占用多个车位的车辆一并释放其余车位
+		if spotsRequired(vehicle) > 1 {
+			for _, other := range l.parkingSpots {
+				if other != spot && other.release(vehicle) != nil {
+					l.free.push(other)
+					l.counts.release(other.GetSpotType())
+				}
+			}
+		}
+	}
+	return vehicle
+}
+
+// claimRun 原子地占用本层 n 个车位号相邻、能容纳车辆的空闲车位，返回按车位号排列的车位，没有时返回nil
+func (l *Level) claimRun(vehicle Vehicle, n int) []*ParkingSpot {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.ensureIndex()
+	return l.claimRunLocked(l.findRunLocked(vehicle, n, nil), vehicle)
+}
+
+// claimRunAt 占用从指定车位开始的 n 个相邻车位，用于恢复持久化的状态
+func (l *Level) claimRunAt(first *ParkingSpot, vehicle Vehicle, n int) []*ParkingSpot {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.ensureIndex()
+	return l.claimRunLocked(l.findRunLocked(vehicle, n, first), vehicle)
+}
+
+// hasRun 判断本层是否有 n 个能容纳车辆的相邻空闲车位
+func (l *Level) hasRun(vehicle Vehicle, n int) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.findRunLocked(vehicle, n, nil) != nil
+}
+
+// findRunLocked 按车位号顺序查找 n 个相邻的可用车位，first 不为nil时只查找从该车位开始的一段，调用方需持有锁
+func (l *Level) findRunLocked(vehicle Vehicle, n int, first *ParkingSpot) []*ParkingSpot {
+	spots := append([]*ParkingSpot(nil), l.parkingSpots...)
+	sort.Slice(spots, func(i, j int) bool {
+		return spots[i].GetSpotNumber() < spots[j].GetSpotNumber()
+	})
+	var run []*ParkingSpot
+	for _, spot := range spots {
+		if first != nil && run == nil && spot != first {
+			continue
+		}
+		usable := spot.IsAvailable() && spot.CanFit(vehicle)
+		adjacent := len(run) == 0 || spot.GetSpotNumber() == run[len(run)-1].GetSpotNumber()+1
+		if !usable || !adjacent {
+			if first != nil {
+				return nil
+			}
+			run = nil
+			if !usable {
+				continue
+			}
+		}
+		run = append(run, spot)
+		if len(run) == n {
+			return run
+		}
+	}
+	return nil
+}
+
+// claimRunLocked 将车辆停入一段车位，任一车位停入失败时全部撤销，调用方需持有锁
+func (l *Level) claimRunLocked(run []*ParkingSpot, vehicle Vehicle) []*ParkingSpot {
+	for i, spot := range run {
+		if !spot.ParkVehicle(vehicle) {
+			for _, parked := range run[:i] {
+				parked.release(vehicle)
+			}
+			return nil
+		}
+	}
+	for _, spot := range run {
+		l.free.remove(spot)
+		l.counts.park(spot.GetSpotType())
+	}
+	return run
+}
+
+// ensureIndex 为直接构造的Level建立空闲车位索引和车位统计，调用方需持有锁
+func (l *Level) ensureIndex() {
+	if l.free == nil {
+		l.free = newFreeSpots(l.parkingSpots)
+		l.counts = newSpotCounts(l.parkingSpots)
+	}
 }
 
 func (l *Level) UnparkVehicle(spotNumber int) bool {
+	_, vehicle := l.unparkVehicle(spotNumber)
+	return vehicle != nil
+}
+
+// unparkVehicle 释放车位，返回该车位和原来停放的车辆
+func (l *Level) unparkVehicle(spotNumber int) (*ParkingSpot, Vehicle) {
+	l.mu.Lock()
+	var spot *ParkingSpot
 	// 如果传入的是车辆在该层的相对位置
 	if spotNumber >= 0 && spotNumber < len(l.parkingSpots) {
-		return l.parkingSpots[spotNumber].UnparkVehicle()
+		spot = l.parkingSpots[spotNumber]
+	} else {
+		// 如果传入的是绝对位置，查找对应的停车位
+		spot = l.findSpot(spotNumber)
 	}
-	
-	// 如果传入的是绝对位置，查找对应的停车位
-	for i, spot := range l.parkingSpots {
-		if spot.GetSpotNumber() == spotNumber && !spot.IsAvailable() {
-			return l.parkingSpots[i].UnparkVehicle()
-		}
+	l.mu.Unlock()
+
+	if spot == nil {
+		return nil, nil
 	}
-	
-	return false
+	return spot, l.releaseSpot(spot, nil)
 }
 
-func (l *Level) GetAvailableSpots() int {
-	count := 0
+// GetSpot 按车位号查找本层车位，不存在时返回nil
+func (l *Level) GetSpot(spotNumber int) *ParkingSpot {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.findSpot(spotNumber)
+}
+
+// findSpot 按车位号查找车位，调用方需持有锁
+func (l *Level) findSpot(spotNumber int) *ParkingSpot {
+	// 车位号通常与下标一致
+	if spotNumber >= 0 && spotNumber < len(l.parkingSpots) && l.parkingSpots[spotNumber].GetSpotNumber() == spotNumber {
+		return l.parkingSpots[spotNumber]
+	}
 	for _, spot := range l.parkingSpots {
-		if spot.IsAvailable() {
-			count++
+		if spot.GetSpotNumber() == spotNumber {
+			return spot
 		}
 	}
-	return count
+	return nil
 }
 
-func (l *Level) GetVehicleCount() int {
-	count := 0
+// AddSpot 在运行时为本层增加一个车位，车位号为当前最大车位号加一
+func (l *Level) AddSpot(spotType SpotType) *ParkingSpot {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.ensureIndex()
+
+	spot := NewParkingSpotOfType(l.nextSpotNumberLocked(), spotType)
+	l.parkingSpots = append(l.parkingSpots, spot)
+	l.free.push(spot)
+	l.counts.add(spotType)
+	return spot
+}
+
+// nextSpotNumber 返回 AddSpot 将要使用的车位号
+func (l *Level) nextSpotNumber() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.nextSpotNumberLocked()
+}
+
+func (l *Level) nextSpotNumberLocked() int {
+	number := 0
 	for _, spot := range l.parkingSpots {
-		if !spot.IsAvailable() {
-			count++
+		if spot.GetSpotNumber() >= number {
+			number = spot.GetSpotNumber() + 1
 		}
 	}
-	return count
+	return number
+}
+
+// CloseSpot 关闭车位进行维护，关闭后不再分配；有车停放时返回 ErrSpotOccupied
+func (l *Level) CloseSpot(spotNumber int) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.ensureIndex()
+
+	spot := l.findSpot(spotNumber)
+	if spot == nil {
+		return ErrSpotNotFound
+	}
+	if spot.IsClosed() {
+		return nil
+	}
+	if err := spot.setClosed(true); err != nil {
+		return err
+	}
+	l.free.remove(spot)
+	l.counts.close(spot.GetSpotType())
+	return nil
+}
+
+// ReopenSpot 重新开放维护完成的车位
+func (l *Level) ReopenSpot(spotNumber int) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.ensureIndex()
+
+	spot := l.findSpot(spotNumber)
+	if spot == nil {
+		return ErrSpotNotFound
+	}
+	if !spot.IsClosed() {
+		return nil
+	}
+	spot.setClosed(false)
+	l.free.push(spot)
+	l.counts.reopen(spot.GetSpotType())
+	return nil
+}
+
+// getSpots 返回车位列表的快照
+func (l *Level) getSpots() []*ParkingSpot {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]*ParkingSpot(nil), l.parkingSpots...)
+}
+
+// GetSpotID 返回本层车位的全局唯一编号
+func (l *Level) GetSpotID(spot *ParkingSpot) SpotID {
+	return SpotID{Floor: l.floor, Spot: spot.GetSpotNumber()}
+}
+
+// GetAvailableSpots 返回本层可用车位数，读取增量维护的统计，无需遍历车位
+func (l *Level) GetAvailableSpots() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.ensureIndex()
+	return l.counts.totalAvailable()
+}
+
+// GetVehicleCount 返回本层被占用的车位数，占用多个车位的车辆按车位数计算
+func (l *Level) GetVehicleCount() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.ensureIndex()
+	return l.counts.occupied
+}
+
+// GetAvailability 返回本层按车位类型统计的可用车位
+func (l *Level) GetAvailability() LevelAvailability {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.ensureIndex()
+	return l.counts.snapshot(l.floor)
+}
+
+// GetTotalSpots 返回本层车位总数
+func (l *Level) GetTotalSpots() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.parkingSpots)
 }
 
 func (l *Level) GetFloor() int {
@@ -78,11 +421,13 @@ func (l *Level) GetFloor() int {
 }
 
 func (l *Level) DisplayAvailability() {
-	for _, spot := range l.parkingSpots {
+	for _, spot := range l.getSpots() {
 		status := "Available"
-		if !spot.IsAvailable() {
+		if spot.IsClosed() {
+			status = "Closed"
+		} else if !spot.IsAvailable() {
 			status = "Occupied"
 		}
-		println("Level:", l.floor, "Spot:", spot.GetSpotNumber(), "Status:", status, "Type:", spot.GetVehicleType())
+		println("Level:", l.floor, "Spot:", spot.GetSpotNumber(), "Status:", status, "Type:", spot.GetSpotType().String())
 	}
 }
@@ -0,0 +1,233 @@
+package parkinglot
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// MonthlyPass 月卡，有效期内会员车辆的停车费全免，充电费用仍需支付
+type MonthlyPass struct {
+	Start time.Time
+	End   time.Time
+}
+
+// Covers 判断月卡在指定时刻是否有效
+func (p MonthlyPass) Covers(at time.Time) bool {
+	return !at.Before(p.Start) && at.Before(p.End)
+}
+
+// MemberAccount 会员账户，绑定一个或多个车牌，持有预付余额和月卡
+type MemberAccount struct {
+	ID      string
+	Name    string
+	Plates  []string
+	Balance float64
+	Passes  []MonthlyPass
+}
+
+func (a *MemberAccount) copy() *MemberAccount {
+	copied := *a
+	copied.Plates = append([]string(nil), a.Plates...)
+	copied.Passes = append([]MonthlyPass(nil), a.Passes...)
+	return &copied
+}
+
+func (a *MemberAccount) hasPass(at time.Time) bool {
+	for _, pass := range a.Passes {
+		if pass.Covers(at) {
+			return true
+		}
+	}
+	return false
+}
+
+// memberBook 会员账户及车牌到账户的索引
+type memberBook struct {
+	accounts  map[string]*MemberAccount
+	byPlate   map[string]*MemberAccount
+	passPrice float64
+	mu        sync.Mutex
+}
+
+func newMemberBook() *memberBook {
+	return &memberBook{
+		accounts: make(map[string]*MemberAccount),
+		byPlate:  make(map[string]*MemberAccount),
+	}
+}
+
+// RegisterMember 注册会员并绑定车牌，一个车牌只能绑定一个账户
+func (p *ParkingLot) RegisterMember(accountID, name string, plates ...string) (*MemberAccount, error) {
+	j := p.beginChange()
+	defer j.end()
+	book := p.members
+	book.mu.Lock()
+	defer book.mu.Unlock()
+
+	if _, ok := book.accounts[accountID]; ok {
+		return nil, ErrDuplicateMember
+	}
+	for _, plate := range plates {
+		if _, ok := book.byPlate[plate]; ok {
+			return nil, ErrPlateRegistered
+		}
+	}
+	account := &MemberAccount{ID: accountID, Name: name, Plates: append([]string(nil), plates...)}
+	if err := j.append(LogRecord{Op: OpMember, Member: account.copy()}); err != nil {
+		return nil, err
+	}
+	book.restore(account)
+	return account.copy(), nil
+}
+
+// GetMember 按账户号查询会员
+func (p *ParkingLot) GetMember(accountID string) (*MemberAccount, error) {
+	book := p.members
+	book.mu.Lock()
+	defer book.mu.Unlock()
+	account, ok := book.accounts[accountID]
+	if !ok {
+		return nil, ErrMemberNotFound
+	}
+	return account.copy(), nil
+}
+
+// FindMemberByPlate 按车牌号查询绑定的会员
+func (p *ParkingLot) FindMemberByPlate(licensePlate string) (*MemberAccount, error) {
+	book := p.members
+	book.mu.Lock()
+	defer book.mu.Unlock()
+	account, ok := book.byPlate[licensePlate]
+	if !ok {
+		return nil, ErrMemberNotFound
+	}
+	return account.copy(), nil
+}
+
+// TopUp 为会员账户充值，返回充值后的余额
+func (p *ParkingLot) TopUp(accountID string, amount float64) (float64, error) {
+	if amount <= 0 {
+		return 0, ErrInvalidAmount
+	}
+	j := p.beginChange()
+	defer j.end()
+	book := p.members
+	book.mu.Lock()
+	defer book.mu.Unlock()
+	account, ok := book.accounts[accountID]
+	if !ok {
+		return 0, ErrMemberNotFound
+	}
+	updated := account.copy()
+	updated.Balance += amount
+	if err := j.append(LogRecord{Op: OpMember, Member: updated}); err != nil {
+		return 0, err
+	}
+	account.Balance = updated.Balance
+	return account.Balance, nil
+}
+
+// SetMonthlyPassPrice 设置月卡价格，未设置价格时不能购买月卡
+func (p *ParkingLot) SetMonthlyPassPrice(price float64) error {
+	if price <= 0 {
+		return ErrInvalidAmount
+	}
+	p.members.mu.Lock()
+	defer p.members.mu.Unlock()
+	p.members.passPrice = price
+	return nil
+}
+
+// BuyMonthlyPass 用预付余额购买 month 所在自然月的月卡
+func (p *ParkingLot) BuyMonthlyPass(accountID string, month time.Time) (MonthlyPass, error) {
+	start := time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, month.Location())
+	pass := MonthlyPass{Start: start, End: start.AddDate(0, 1, 0)}
+
+	j := p.beginChange()
+	defer j.end()
+	book := p.members
+	book.mu.Lock()
+	defer book.mu.Unlock()
+	if book.passPrice <= 0 {
+		return MonthlyPass{}, ErrPassPriceNotSet
+	}
+	account, ok := book.accounts[accountID]
+	if !ok {
+		return MonthlyPass{}, ErrMemberNotFound
+	}
+	if account.hasPass(start) {
+		return MonthlyPass{}, ErrPassAlreadyActive
+	}
+	if account.Balance < book.passPrice {
+		return MonthlyPass{}, ErrInsufficientBalance
+	}
+	updated := account.copy()
+	updated.Balance -= book.passPrice
+	updated.Passes = append(updated.Passes, pass)
+	if err := j.append(LogRecord{Op: OpMember, Member: updated}); err != nil {
+		return MonthlyPass{}, err
+	}
+	book.restore(updated)
+	return pass, nil
+}
+
+// hasPass 判断车牌绑定的会员在指定时刻是否持有有效月卡
+func (b *memberBook) hasPass(licensePlate string, at time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	account, ok := b.byPlate[licensePlate]
+	return ok && account.hasPass(at)
+}
+
+// debit 从车牌绑定的会员余额中扣款，返回账户号
+func (b *memberBook) debit(licensePlate string, amount float64) (string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	account, ok := b.byPlate[licensePlate]
+	if !ok {
+		return "", ErrMemberNotFound
+	}
+	if account.Balance < amount {
+		return "", ErrInsufficientBalance
+	}
+	account.Balance -= amount
+	return account.ID, nil
+}
+
+// credit 退款到会员余额
+func (b *memberBook) credit(accountID string, amount float64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if account, ok := b.accounts[accountID]; ok {
+		account.Balance += amount
+	}
+}
+
+// restore 按账户状态新增或覆盖会员账户，调用方需持有 mu
+// 账户注册后绑定的车牌不会变化，覆盖时沿用原有的车牌索引
+func (b *memberBook) restore(state *MemberAccount) {
+	account := state.copy()
+	if existing, ok := b.accounts[account.ID]; ok {
+		*existing = *account
+		return
+	}
+	b.accounts[account.ID] = account
+	for _, plate := range account.Plates {
+		b.byPlate[plate] = account
+	}
+}
+
+// snapshot 返回按账户号排序的全部会员账户副本
+func (b *memberBook) snapshot() []*MemberAccount {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	accounts := make([]*MemberAccount, 0, len(b.accounts))
+	for _, account := range b.accounts {
+		accounts = append(accounts, account.copy())
+	}
+	sort.Slice(accounts, func(i, j int) bool {
+		return accounts[i].ID < accounts[j].ID
+	})
+	return accounts
+}
@@ -0,0 +1,143 @@
+package parkinglot
+
+import (
+	"math"
+	"sort"
+	"sync"
+)
+
+// Location 停车场的地理位置
+type Location struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+// DistanceTo 返回两地之间的球面距离（公里）
+func (l Location) DistanceTo(other Location) float64 {
+	const earthRadiusKm = 6371.0
+	lat1 := l.Latitude * math.Pi / 180
+	lat2 := other.Latitude * math.Pi / 180
+	dLat := lat2 - lat1
+	dLon := (other.Longitude - l.Longitude) * math.Pi / 180
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(a))
+}
+
+// Facility 停车网络中的一个停车场
+type Facility struct {
+	ID       string
+	Location Location
+	Lot      *ParkingLot
+}
+
+// FacilityAvailability 单个停车场的可用车位
+type FacilityAvailability struct {
+	ID           string       `json:"id"`
+	Location     Location     `json:"location"`
+	Availability Availability `json:"availability"`
+}
+
+// NetworkAvailability 整个停车网络的可用车位
+type NetworkAvailability struct {
+	Total      int                    `json:"total"`
+	Available  int                    `json:"available"`
+	Occupied   int                    `json:"occupied"`
+	Facilities []FacilityAvailability `json:"facilities"`
+}
+
+// ParkingNetwork 由多个独立停车场组成的停车网络，汇总可用车位并为车辆推荐最近的停车场
+type ParkingNetwork struct {
+	facilities map[string]*Facility
+	mu         sync.RWMutex
+}
+
+func NewParkingNetwork() *ParkingNetwork {
+	return &ParkingNetwork{facilities: make(map[string]*Facility)}
+}
+
+// AddFacility 将停车场加入网络，ID 重复时返回 ErrDuplicateFacility
+func (n *ParkingNetwork) AddFacility(id string, location Location, lot *ParkingLot) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if _, ok := n.facilities[id]; ok {
+		return ErrDuplicateFacility
+	}
+	n.facilities[id] = &Facility{ID: id, Location: location, Lot: lot}
+	return nil
+}
+
+// GetFacility 按ID查找停车场
+func (n *ParkingNetwork) GetFacility(id string) (*Facility, error) {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	facility, ok := n.facilities[id]
+	if !ok {
+		return nil, ErrFacilityNotFound
+	}
+	return facility, nil
+}
+
+// getFacilities 按ID排序返回所有停车场
+func (n *ParkingNetwork) getFacilities() []*Facility {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	facilities := make([]*Facility, 0, len(n.facilities))
+	for _, facility := range n.facilities {
+		facilities = append(facilities, facility)
+	}
+	sort.Slice(facilities, func(i, j int) bool {
+		return facilities[i].ID < facilities[j].ID
+	})
+	return facilities
+}
+
+// GetAvailability 汇总所有停车场的可用车位
+func (n *ParkingNetwork) GetAvailability() NetworkAvailability {
+	var a NetworkAvailability
+	for _, facility := range n.getFacilities() {
+		fa := FacilityAvailability{
+			ID:           facility.ID,
+			Location:     facility.Location,
+			Availability: facility.Lot.GetAvailability(),
+		}
+		a.Total += fa.Availability.Total
+		a.Available += fa.Availability.Available
+		a.Occupied += fa.Availability.Occupied
+		a.Facilities = append(a.Facilities, fa)
+	}
+	return a
+}
+
+// Redirect 返回离 from 最近、且有能容纳该车辆的空闲车位的停车场
+// 只检查车位是否存在，不占用车位；所有停车场都满时返回 ErrNoAvailableSpot
+func (n *ParkingNetwork) Redirect(vehicle Vehicle, from Location) (*Facility, error) {
+	var nearest *Facility
+	nearestDistance := 0.0
+	for _, facility := range n.getFacilities() {
+		if !facility.Lot.HasSpaceFor(vehicle) {
+			continue
+		}
+		distance := from.DistanceTo(facility.Location)
+		if nearest == nil || distance < nearestDistance {
+			nearest, nearestDistance = facility, distance
+		}
+	}
+	if nearest == nil {
+		return nil, ErrNoAvailableSpot
+	}
+	return nearest, nil
+}
+
+// HasSpaceFor 停车场按当前分配策略能为车辆分配车位时返回true
+func (p *ParkingLot) HasSpaceFor(vehicle Vehicle) bool {
+	if n := spotsRequired(vehicle); n > 1 {
+		for _, level := range p.getLevels() {
+			if level.hasRun(vehicle, n) {
+				return true
+			}
+		}
+		return false
+	}
+	_, spot := p.getStrategy().SelectSpot(p.getLevels(), vehicle)
+	return spot != nil
+}
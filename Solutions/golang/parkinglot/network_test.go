@@ -0,0 +1,60 @@
+package parkinglot
+
+import (
+	"errors"
+	"testing"
+)
+
+// 测试停车网络汇总可用车位并将车辆引导到最近的有空位的停车场
+func TestParkingNetwork(t *testing.T) {
+	network := NewParkingNetwork()
+	downtown := NewParkingLot(1)
+	downtown.AddLevel(NewLevel(1, 4)) // 0-1为regular，2为motorcycle，3为truck
+	airport := NewParkingLot(1)
+	airport.AddLevel(NewLevel(1, 8))
+
+	center := Location{Latitude: 39.9042, Longitude: 116.4074}
+	if err := network.AddFacility("downtown", Location{Latitude: 39.9100, Longitude: 116.4100}, downtown); err != nil {
+		t.Fatal(err)
+	}
+	if err := network.AddFacility("airport", Location{Latitude: 40.0799, Longitude: 116.6031}, airport); err != nil {
+		t.Fatal(err)
+	}
+	if err := network.AddFacility("airport", Location{}, airport); !errors.Is(err, ErrDuplicateFacility) {
+		t.Errorf("期望 ErrDuplicateFacility，实际: %v", err)
+	}
+
+	facility, err := network.Redirect(NewCar("C1"), center)
+	if err != nil || facility.ID != "downtown" {
+		t.Fatalf("期望引导到 downtown，实际: %v %v", facility, err)
+	}
+
+	// 市中心的汽车车位停满后引导到机场
+	NewEntryGate("E1", downtown).Enter(NewCar("C1"))
+	NewEntryGate("E1", downtown).Enter(NewCar("C2"))
+	facility, err = network.Redirect(NewCar("C3"), center)
+	if err != nil || facility.ID != "airport" {
+		t.Errorf("期望引导到 airport，实际: %v %v", facility, err)
+	}
+	// 卡车车位仍有空位
+	if facility, err := network.Redirect(NewTruck("T1"), center); err != nil || facility.ID != "downtown" {
+		t.Errorf("卡车期望引导到 downtown，实际: %v %v", facility, err)
+	}
+
+	availability := network.GetAvailability()
+	if availability.Total != 12 || availability.Available != 10 || availability.Occupied != 2 || len(availability.Facilities) != 2 {
+		t.Errorf("网络可用车位错误: %+v", availability)
+	}
+	if availability.Facilities[0].ID != "airport" || availability.Facilities[1].Availability.Available != 2 {
+		t.Errorf("停车场可用车位错误: %+v", availability.Facilities)
+	}
+
+	if _, err := network.GetFacility("mall"); !errors.Is(err, ErrFacilityNotFound) {
+		t.Errorf("期望 ErrFacilityNotFound，实际: %v", err)
+	}
+
+	empty := NewParkingNetwork()
+	if _, err := empty.Redirect(NewCar("C4"), center); !errors.Is(err, ErrNoAvailableSpot) {
+		t.Errorf("期望 ErrNoAvailableSpot，实际: %v", err)
+	}
+}
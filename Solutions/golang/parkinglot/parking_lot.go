@@ -1,47 +1,560 @@
 package parkinglot
 
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
 type ParkingLot struct {
-	levels []*Level
+	levels         []*Level
+	tickets        map[string]*Ticket
+	ticketSeq      int
+	pricing        PricingStrategy
+	strategy       SpotAssignmentStrategy
+	vehicles       *vehicleRegistry
+	events         *eventBus
+	charger        Charger
+	chargingTariff ChargingTariff
+	reservations   *reservationBook
+	members        *memberBook
+	validations    *validationBook
+	cardProcessor  CardProcessor
+	receipts       map[string]*Receipt // 按停车票号索引的出场收据
+	receiptSeq     int
+	journal        *journal // 开启持久化后记录修改的预写日志
+	now            func() time.Time
+	mu             sync.RWMutex // 保护 levels、pricing、strategy、journal、充电和收单设置
+	ticketMu       sync.Mutex   // 保护 tickets、ticketSeq、充电会话和收据
 }
 
-var instance *ParkingLot
-
+// NewParkingLot 创建一个独立的停车场，numLevels 为预计的楼层数
 func NewParkingLot(numLevels int) *ParkingLot {
-	if instance != nil {
-		return instance
+	return &ParkingLot{
+		levels:        make([]*Level, 0, numLevels),
+		tickets:       make(map[string]*Ticket),
+		pricing:       NewHourlyPricing(0),
+		strategy:      NewExactTypeStrategy(),
+		vehicles:      newVehicleRegistry(),
+		events:        newEventBus(),
+		charger:       NewSimulatedCharger(7),
+		reservations:  newReservationBook(),
+		members:       newMemberBook(),
+		validations:   newValidationBook(),
+		cardProcessor: NewSimulatedCardProcessor(),
+		receipts:      make(map[string]*Receipt),
+		now:           time.Now,
+	}
+}
+
+// AddLevel 加入停车层，开启持久化后写日志失败时不会加入，需要处理错误时请使用 ApplyLayout
+func (p *ParkingLot) AddLevel(level *Level) {
+	j := p.beginChange()
+	defer j.end()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if j.append(LogRecord{Op: OpAddLevel, Level: levelStateOf(level)}) != nil {
+		return
+	}
+	p.levels = append(p.levels, level)
+}
+
+// ApplyLayout 按布局创建停车层并加入停车场，楼层号不能与已有楼层重复
+func (p *ParkingLot) ApplyLayout(layout *LotLayout) error {
+	levels, err := layout.BuildLevels()
+	if err != nil {
+		return err
 	}
 
-	instance = &ParkingLot{
-		levels: make([]*Level, 0, numLevels),
+	j := p.beginChange()
+	defer j.end()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, level := range levels {
+		for _, existing := range p.levels {
+			if existing.GetFloor() == level.GetFloor() {
+				return fmt.Errorf("%w: floor %d already exists", ErrInvalidLayout, level.GetFloor())
+			}
+		}
+	}
+	for _, level := range levels {
+		if err := j.append(LogRecord{Op: OpAddLevel, Level: levelStateOf(level)}); err != nil {
+			return err
+		}
+		p.levels = append(p.levels, level)
 	}
+	return nil
+}
 
-	return instance
+// SetPricingStrategy 设置出场时使用的计费策略
+func (p *ParkingLot) SetPricingStrategy(pricing PricingStrategy) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.pricing = pricing
 }
 
-func (p *ParkingLot) AddLevel(level *Level) {
-	p.levels = append(p.levels, level)
+// getLevels 返回楼层列表的快照，遍历时无需持有锁
+func (p *ParkingLot) getLevels() []*Level {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return append([]*Level(nil), p.levels...)
+}
+
+func (p *ParkingLot) getPricing() PricingStrategy {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.pricing
+}
+
+// SetSpotAssignmentStrategy 设置车位分配策略
+func (p *ParkingLot) SetSpotAssignmentStrategy(strategy SpotAssignmentStrategy) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.strategy = strategy
+}
+
+func (p *ParkingLot) getStrategy() SpotAssignmentStrategy {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.strategy
 }
 
 func (p *ParkingLot) ParkVehicle(vehicle Vehicle) bool {
-	for _, level := range p.levels {
-		if level.ParkVehicle(vehicle) {
-			return true
+	j := p.beginChange()
+	level, spot, err := p.assignSpot(vehicle)
+	var batch uint64
+	if err == nil {
+		err = p.journalPark(j, vehicle, level, spot, nil)
+	}
+	if err == nil {
+		batch = p.events.reserve()
+	}
+	j.end()
+	if err != nil {
+		return false
+	}
+	p.publishParked(batch, vehicle, level, spot, nil)
+	return true
+}
+
+// assignSpot 按分配策略为车辆选择并占用车位，并登记车辆位置，由调用方写日志和发布事件
+// 同一车牌已在场时返回 ErrDuplicateVehicle
+func (p *ParkingLot) assignSpot(vehicle Vehicle) (*Level, *ParkingSpot, error) {
+	if err := p.vehicles.reserve(vehicle); err != nil {
+		return nil, nil, err
+	}
+	level, spot, err := p.selectSpot(vehicle)
+	if err != nil {
+		p.vehicles.remove(vehicle)
+		return nil, nil, err
+	}
+	p.vehicles.place(vehicle, level, spot)
+	return level, spot, nil
+}
+
+// journalPark 记录车辆停入车位，写日志失败时撤销停车和签发的停车票
+func (p *ParkingLot) journalPark(j *journal, vehicle Vehicle, level *Level, spot *ParkingSpot, ticket *Ticket) error {
+	record := LogRecord{Op: OpPark, Vehicle: vehicleStateOf(vehicle)}
+	id := level.GetSpotID(spot)
+	record.Spot = &id
+	if ticket != nil {
+		p.ticketMu.Lock()
+		record.Ticket = ticket.copy()
+		p.ticketMu.Unlock()
+	}
+	err := j.append(record)
+	if err != nil {
+		level.releaseSpot(spot, vehicle)
+		p.vehicles.remove(vehicle)
+		if ticket != nil {
+			p.ticketMu.Lock()
+			delete(p.tickets, ticket.ID)
+			p.ticketMu.Unlock()
 		}
 	}
-	return false
+	return err
+}
+
+// journalUnpark 记录按车位号取车，车辆的停车票按取车时刻结算后关闭，返回关闭的停车票副本
+// 车辆正在凭票出场或写日志失败时把车辆放回原车位
+func (p *ParkingLot) journalUnpark(j *journal, vehicle Vehicle, level *Level, spot *ParkingSpot) (*Ticket, error) {
+	p.ticketMu.Lock()
+	ticket := p.activeTicket(vehicle)
+	if ticket != nil && ticket.exiting {
+		p.ticketMu.Unlock()
+		p.reclaim(vehicle, level, spot)
+		return nil, ErrExitInProgress
+	}
+	id := level.GetSpotID(spot)
+	record := LogRecord{Op: OpUnpark, Spot: &id}
+	var closed *Ticket
+	if ticket != nil {
+		closed = ticket.copy()
+		closed.ExitTime = p.now()
+		p.settleFees(closed, closed.ExitTime)
+		record.Ticket = closed
+	}
+	err := j.append(record)
+	if err == nil && ticket != nil {
+		*ticket = *closed.copy()
+	}
+	p.ticketMu.Unlock()
+	if err != nil {
+		p.reclaim(vehicle, level, spot)
+		return nil, err
+	}
+	return closed, nil
+}
+
+// activeTicket 返回车辆未出场的停车票，调用方需持有 ticketMu
+// 只在按车位号取车时使用，逐张查找
+func (p *ParkingLot) activeTicket(vehicle Vehicle) *Ticket {
+	for _, ticket := range p.tickets {
+		if ticket.vehicle == vehicle && ticket.IsActive() {
+			return ticket
+		}
+	}
+	return nil
+}
+
+// reclaim 把取车失败的车辆放回原车位
+func (p *ParkingLot) reclaim(vehicle Vehicle, level *Level, spot *ParkingSpot) {
+	claimed := false
+	if n := spotsRequired(vehicle); n > 1 {
+		claimed = level.claimRunAt(spot, vehicle, n) != nil
+	} else {
+		claimed = level.claimSpot(spot, vehicle)
+	}
+	if claimed {
+		p.vehicles.place(vehicle, level, spot)
+	}
 }
 
+// firstSpot 返回车辆登记的车位，占用多个车位的车辆为车位号最小的车位
+func (p *ParkingLot) firstSpot(vehicle Vehicle, spot *ParkingSpot) *ParkingSpot {
+	if record, ok := p.vehicles.find(vehicle.GetLicensePlate()); ok && record.vehicle == vehicle {
+		return record.spot
+	}
+	return spot
+}
+
+// selectSpot 按分配策略选择并占用车位，选中的车位被其他入口抢先占用时重新选择
+// 需要多个车位的车辆按楼层顺序占用第一段相邻的空闲车位，返回其中车位号最小的车位
+func (p *ParkingLot) selectSpot(vehicle Vehicle) (*Level, *ParkingSpot, error) {
+	levels := p.getLevels()
+	if n := spotsRequired(vehicle); n > 1 {
+		for _, level := range levels {
+			if run := level.claimRun(vehicle, n); run != nil {
+				return level, run[0], nil
+			}
+		}
+		return nil, nil, ErrNoAvailableSpot
+	}
+
+	strategy := p.getStrategy()
+	for {
+		level, spot := strategy.SelectSpot(levels, vehicle)
+		if spot == nil {
+			return nil, nil, ErrNoAvailableSpot
+		}
+		if !spot.CanFit(vehicle) {
+			return nil, nil, ErrSpotNotCompatible
+		}
+		if level.claimSpot(spot, vehicle) {
+			return level, spot, nil
+		}
+	}
+}
+
+// UnparkVehicle 按车位号取车，依次在各楼层查找
+//
+// Deprecated: 每层的车位号都从0开始，不同楼层的车位号会冲突，请使用 UnparkVehicleAt 或凭票出场
 func (p *ParkingLot) UnparkVehicle(spotNumber int) bool {
-	for _, level := range p.levels {
-		if level.UnparkVehicle(spotNumber) {
+	j := p.beginChange()
+	for _, level := range p.getLevels() {
+		if spot, vehicle := level.unparkVehicle(spotNumber); vehicle != nil {
+			spot = p.firstSpot(vehicle, spot)
+			p.vehicles.remove(vehicle)
+			ticket, err := p.journalUnpark(j, vehicle, level, spot)
+			if err != nil {
+				j.end()
+				return false
+			}
+			batch := p.events.reserve()
+			j.end()
+			p.publishLeft(batch, vehicle, level, spot, ticket, nil)
 			return true
 		}
 	}
+	j.end()
 	return false
 }
 
-func (p *ParkingLot) DisplayAvailability() {
-	for _, level := range p.levels {
-		level.DisplayAvailability()
+// UnparkVehicleAt 释放指定编号的车位，车辆的停车票按此刻结算后关闭，不收款
+func (p *ParkingLot) UnparkVehicleAt(id SpotID) bool {
+	j := p.beginChange()
+	level, spot, err := p.findSpot(&id)
+	if err != nil {
+		j.end()
+		return false
+	}
+	vehicle := level.releaseSpot(spot, nil)
+	if vehicle == nil {
+		j.end()
+		return false
+	}
+	spot = p.firstSpot(vehicle, spot)
+	p.vehicles.remove(vehicle)
+	ticket, err := p.journalUnpark(j, vehicle, level, spot)
+	if err != nil {
+		j.end()
+		return false
 	}
-}
\ No newline at end of file
+	batch := p.events.reserve()
+	j.end()
+	p.publishLeft(batch, vehicle, level, spot, ticket, nil)
+	return true
+}
+
+// AddSpot 在指定楼层增加一个车位，返回新车位的编号
+func (p *ParkingLot) AddSpot(floor int, spotType SpotType) (SpotID, error) {
+	j := p.beginChange()
+	defer j.end()
+	level := p.findLevel(floor)
+	if level == nil {
+		return SpotID{}, ErrLevelNotFound
+	}
+	// 修改串行执行，写日志后新增的车位号与日志一致
+	id := SpotID{Floor: floor, Spot: level.nextSpotNumber()}
+	if err := j.append(LogRecord{Op: OpAddSpot, Spot: &id, SpotType: spotType.String()}); err != nil {
+		return SpotID{}, err
+	}
+	return level.GetSpotID(level.AddSpot(spotType)), nil
+}
+
+// CloseSpot 关闭指定车位进行维护
+func (p *ParkingLot) CloseSpot(id SpotID) error {
+	j := p.beginChange()
+	defer j.end()
+	level, spot, err := p.findSpot(&id)
+	if err != nil {
+		return err
+	}
+	if spot.IsClosed() {
+		return nil
+	}
+	if err := level.CloseSpot(spot.GetSpotNumber()); err != nil {
+		return err
+	}
+	if err := j.append(LogRecord{Op: OpCloseSpot, Spot: &id}); err != nil {
+		level.ReopenSpot(id.Spot)
+		return err
+	}
+	return nil
+}
+
+// ReopenSpot 重新开放维护完成的车位
+func (p *ParkingLot) ReopenSpot(id SpotID) error {
+	j := p.beginChange()
+	defer j.end()
+	level, spot, err := p.findSpot(&id)
+	if err != nil {
+		return err
+	}
+	if !spot.IsClosed() {
+		return nil
+	}
+	if err := level.ReopenSpot(spot.GetSpotNumber()); err != nil {
+		return err
+	}
+	if err := j.append(LogRecord{Op: OpReopenSpot, Spot: &id}); err != nil {
+		level.CloseSpot(id.Spot)
+		return err
+	}
+	return nil
+}
+
+// FindVehicle 按车牌号查找车辆停放的车位
+func (p *ParkingLot) FindVehicle(licensePlate string) (SpotID, error) {
+	record, ok := p.vehicles.find(licensePlate)
+	if !ok {
+		return SpotID{}, ErrVehicleNotFound
+	}
+	return record.level.GetSpotID(record.spot), nil
+}
+
+// GetTicket 根据票号查询停车票，返回副本
+func (p *ParkingLot) GetTicket(ticketID string) (*Ticket, error) {
+	p.ticketMu.Lock()
+	defer p.ticketMu.Unlock()
+	ticket, ok := p.tickets[ticketID]
+	if !ok {
+		return nil, ErrTicketNotFound
+	}
+	return ticket.copy(), nil
+}
+
+// issueTicket 为车辆分配车位并签发停车票
+// 分配前先处理预约，让到达保留时间的预约优先占用车位
+func (p *ParkingLot) issueTicket(vehicle Vehicle, gateID string) (*Ticket, error) {
+	j := p.beginChange()
+	p.ProcessReservations()
+	level, spot, err := p.assignSpot(vehicle)
+	if err != nil {
+		j.end()
+		return nil, err
+	}
+	ticket := p.newTicket(vehicle, gateID, level, spot)
+	if err := p.journalPark(j, vehicle, level, spot, ticket); err != nil {
+		j.end()
+		return nil, err
+	}
+	issued := p.ticketCopy(ticket)
+	batch := p.events.reserve()
+	j.end()
+	p.publishParked(batch, vehicle, level, spot, ticket)
+	return issued, nil
+}
+
+// ticketCopy 在 ticketMu 下复制停车票，返回给调用方的票不会被出场结算修改
+func (p *ParkingLot) ticketCopy(ticket *Ticket) *Ticket {
+	p.ticketMu.Lock()
+	defer p.ticketMu.Unlock()
+	return ticket.copy()
+}
+
+// newTicket 为已停好的车辆签发停车票
+func (p *ParkingLot) newTicket(vehicle Vehicle, gateID string, level *Level, spot *ParkingSpot) *Ticket {
+	p.ticketMu.Lock()
+	defer p.ticketMu.Unlock()
+	p.ticketSeq++
+	ticket := &Ticket{
+		ID:            fmt.Sprintf("T-%06d", p.ticketSeq),
+		LicensePlate:  vehicle.GetLicensePlate(),
+		VehicleType:   vehicle.GetType(),
+		Floor:         level.GetFloor(),
+		SpotNumber:    spot.GetSpotNumber(),
+		SpotsOccupied: spotsRequired(vehicle),
+		EntryGate:     gateID,
+		EntryTime:     p.now(),
+		vehicle:       vehicle,
+		level:         level,
+		spot:          spot,
+	}
+	p.tickets[ticket.ID] = ticket
+	return ticket
+}
+
+// closeTicket 结算停车费并释放车位，同一张票只能出场一次
+// payment 不为nil时先收款，收款失败时车辆不能出场
+// 银行卡扣款不持有任何锁：先按出场时刻结算并将停车票标记为出场中，扣款成功后再写日志完成出场
+func (p *ParkingLot) closeTicket(ticketID, gateID string, payment *Payment) (*Ticket, *Receipt, error) {
+	settled, err := p.beginExit(ticketID, gateID)
+	if err != nil {
+		return nil, nil, err
+	}
+	var authCode string
+	if payment != nil && payment.Method == PaymentCard {
+		if authCode, err = p.chargeCard(payment.CardToken, settled.Fee); err != nil {
+			p.abortExit(ticketID)
+			return nil, nil, err
+		}
+	}
+	ticket, receipt, err := p.finishExit(settled, payment, authCode)
+	if err != nil {
+		if authCode != "" {
+			p.refundCard(authCode, settled.Fee)
+		}
+		return nil, nil, err
+	}
+	return ticket, receipt, nil
+}
+
+// beginExit 按当前时刻结算停车票，返回结算后的副本并将停车票标记为出场中
+func (p *ParkingLot) beginExit(ticketID, gateID string) (*Ticket, error) {
+	p.ticketMu.Lock()
+	defer p.ticketMu.Unlock()
+	ticket, ok := p.tickets[ticketID]
+	if !ok {
+		return nil, ErrTicketNotFound
+	}
+	if !ticket.IsActive() {
+		return nil, ErrTicketAlreadyUsed
+	}
+	if ticket.exiting {
+		return nil, ErrExitInProgress
+	}
+	settled := ticket.copy()
+	settled.ExitTime = p.now()
+	settled.ExitGate = gateID
+	p.settleFees(settled, settled.ExitTime)
+	ticket.exiting = true
+	return settled, nil
+}
+
+// abortExit 扣款失败时取消出场中标记
+func (p *ParkingLot) abortExit(ticketID string) {
+	p.ticketMu.Lock()
+	defer p.ticketMu.Unlock()
+	if ticket, ok := p.tickets[ticketID]; ok {
+		ticket.exiting = false
+	}
+}
+
+// finishExit 收款、写日志并释放车位，失败时停车票恢复为未出场
+// 银行卡扣款由调用方撤销，其余付款方式在这里退回
+func (p *ParkingLot) finishExit(settled *Ticket, payment *Payment, authCode string) (*Ticket, *Receipt, error) {
+	j := p.beginChange()
+	p.ticketMu.Lock()
+	ticket := p.tickets[settled.ID]
+	previous := ticket.copy()
+	previous.exiting = false
+	*ticket = *settled.copy()
+
+	var receipt *Receipt
+	record := LogRecord{Op: OpExit, Ticket: ticket.copy()}
+	if payment != nil {
+		var err error
+		if receipt, err = p.collect(ticket, *payment, authCode); err != nil {
+			*ticket = *previous
+			p.ticketMu.Unlock()
+			j.end()
+			return nil, nil, err
+		}
+		record.Receipt = receipt
+		if receipt.Method == PaymentBalance {
+			record.Member, _ = p.GetMember(receipt.AccountID)
+		}
+	}
+	if err := j.append(record); err != nil {
+		if receipt != nil && receipt.Method == PaymentBalance {
+			p.members.credit(receipt.AccountID, receipt.Total)
+		}
+		*ticket = *previous
+		p.ticketMu.Unlock()
+		j.end()
+		return nil, nil, err
+	}
+	if receipt != nil {
+		p.receipts[ticket.ID] = receipt
+	}
+	p.ticketMu.Unlock()
+
+	// 车辆可能已按车位号被取走，只释放仍停放着本车的车位
+	released := ticket.level.releaseSpot(ticket.spot, ticket.vehicle) != nil
+	var batch uint64
+	if released {
+		p.vehicles.remove(ticket.vehicle)
+		batch = p.events.reserve()
+	}
+	j.end()
+	if released {
+		p.publishLeft(batch, ticket.vehicle, ticket.level, ticket.spot, ticket, receipt)
+	}
+	return ticket, receipt, nil
+}
+
+// DisplayAvailability 打印各层按车位类型统计的可用车位
+func (p *ParkingLot) DisplayAvailability() {
+	fmt.Print(NewDisplayBoard("ALL", p).Render())
+}
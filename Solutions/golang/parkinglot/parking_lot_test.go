@@ -11,10 +11,14 @@ func TestNewParkingLot(t *testing.T) {
 		t.Error("停车场创建失败")
 	}
 	
-	// 测试单例模式
+	// 每次创建的停车场相互独立
 	anotherParkingLot := NewParkingLot(5)
-	if parkingLot != anotherParkingLot {
-		t.Error("单例模式实现失败")
+	if parkingLot == anotherParkingLot {
+		t.Error("停车场实例不应共享")
+	}
+	parkingLot.AddLevel(NewLevel(1, 4))
+	if len(anotherParkingLot.getLevels()) != 0 {
+		t.Error("停车场之间共享了楼层")
 	}
 }
 
@@ -98,9 +102,6 @@ func TestParkAndUnparkVehicle(t *testing.T) {
 
 // 测试停车场可用性
 func TestParkingLotAvailability(t *testing.T) {
-	// 重置单例，避免之前测试的影响
-	instance = nil
-	
 	// 创建一个只有CAR类型停车位的停车场
 	parkingLot := NewParkingLot(1)
 	level := &Level{
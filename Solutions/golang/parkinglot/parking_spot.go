@@ -1,71 +1,135 @@
 package parkinglot
 
+import "sync"
+
 type ParkingSpot struct {
 	spotNumber    int
-	vehicleType   VehicleType
+	spotType      SpotType
 	parkedVehicle Vehicle
+	closed        bool
+	heldBy        string // 为预约保留车位时的预约号
+	mu            sync.Mutex
 }
 
 func NewParkingSpot(spotNumber int, vehicleType VehicleType) *ParkingSpot {
+	return NewParkingSpotOfType(spotNumber, SpotTypeFor(vehicleType))
+}
+
+// NewParkingSpotOfType 创建指定车位类型的车位
+func NewParkingSpotOfType(spotNumber int, spotType SpotType) *ParkingSpot {
 	return &ParkingSpot{
-		spotNumber:  spotNumber,
-		vehicleType: vehicleType,
+		spotNumber: spotNumber,
+		spotType:   spotType,
 	}
 }
 
+// IsAvailable 车位空闲、未关闭且未被预约保留时返回true
 func (s *ParkingSpot) IsAvailable() bool {
-	return s.parkedVehicle == nil
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.parkedVehicle == nil && !s.closed && s.heldBy == ""
 }
 
 func (s *ParkingSpot) ParkVehicle(vehicle Vehicle) bool {
-	if !s.IsAvailable() {
+	return s.parkReserved(vehicle, "")
+}
+
+// parkReserved 将车辆停入车位，被预约保留的车位只接受对应预约的车辆
+func (s *ParkingSpot) parkReserved(vehicle Vehicle, reservationID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.parkedVehicle != nil || s.closed || s.heldBy != reservationID || !canFit(s.spotType, vehicle) {
 		return false
 	}
-	
-	// 规则：
-	// 1. 摩托车可以停在任何类型的停车位
-	// 2. 汽车只能停在汽车停车位
-	// 3. 卡车只能停在卡车停车位
-	
-	vType := vehicle.GetType()
-	switch vType {
-	case MOTORCYCLE:
-		// 摩托车可以停在任何地方
-		s.parkedVehicle = vehicle
-		return true
-	case CAR:
-		// 汽车只能停在汽车或更大的车位
-		if s.vehicleType == CAR || s.vehicleType == TRUCK {
-			s.parkedVehicle = vehicle
-			return true
-		}
-	case TRUCK:
-		// 卡车只能停在卡车车位
-		if s.vehicleType == TRUCK {
-			s.parkedVehicle = vehicle
-			return true
-		}
+	s.parkedVehicle = vehicle
+	s.heldBy = ""
+	return true
+}
+
+// hold 为预约保留空闲车位
+func (s *ParkingSpot) hold(reservationID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.parkedVehicle != nil || s.closed || s.heldBy != "" {
+		return false
 	}
-	
-	return false
+	s.heldBy = reservationID
+	return true
 }
 
-func (s *ParkingSpot) UnparkVehicle() bool {
-	if s.IsAvailable() {
+// unhold 取消预约保留，车位已被预约车辆停入时返回false
+func (s *ParkingSpot) unhold(reservationID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.heldBy != reservationID {
 		return false
 	}
-	s.parkedVehicle = nil
+	s.heldBy = ""
 	return true
 }
 
+// HeldBy 返回保留该车位的预约号，未保留时为空
+func (s *ParkingSpot) HeldBy() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.heldBy
+}
+
+// CanFit 判断该车位能否容纳指定车辆，规则见 canFit
+func (s *ParkingSpot) CanFit(vehicle Vehicle) bool {
+	return canFit(s.spotType, vehicle)
+}
+
+// IsClosed 车位因维护关闭时返回true
+func (s *ParkingSpot) IsClosed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed
+}
+
+// setClosed 关闭或重新开放车位，有车停放或被预约保留时不能关闭
+func (s *ParkingSpot) setClosed(closed bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if closed && (s.parkedVehicle != nil || s.heldBy != "") {
+		return ErrSpotOccupied
+	}
+	s.closed = closed
+	return nil
+}
+
+func (s *ParkingSpot) UnparkVehicle() bool {
+	return s.release(nil) != nil
+}
+
+// release 释放车位并返回原来停放的车辆
+// expected 不为nil时，只有停放的正是该车辆才释放，避免凭旧票释放了之后停入的车辆
+func (s *ParkingSpot) release(expected Vehicle) Vehicle {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	vehicle := s.parkedVehicle
+	if vehicle == nil || (expected != nil && vehicle != expected) {
+		return nil
+	}
+	s.parkedVehicle = nil
+	return vehicle
+}
+
 func (s *ParkingSpot) GetSpotNumber() int {
 	return s.spotNumber
 }
 
+// GetVehicleType 返回车位主要面向的车辆类型
 func (s *ParkingSpot) GetVehicleType() VehicleType {
-	return s.vehicleType
+	return vehicleTypeFor(s.spotType)
+}
+
+func (s *ParkingSpot) GetSpotType() SpotType {
+	return s.spotType
 }
 
 func (s *ParkingSpot) GetParkedVehicle() Vehicle {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	return s.parkedVehicle
 }
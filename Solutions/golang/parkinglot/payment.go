@@ -0,0 +1,243 @@
+package parkinglot
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// PaymentMethod 出场付款方式
+type PaymentMethod int
+
+const (
+	PaymentCash    PaymentMethod = iota // 现金，找零
+	PaymentCard                         // 银行卡，通过 CardProcessor 扣款
+	PaymentPass                         // 月卡，只能在月卡覆盖全部费用时使用
+	PaymentBalance                      // 会员预付余额
+)
+
+func (m PaymentMethod) String() string {
+	switch m {
+	case PaymentCash:
+		return "cash"
+	case PaymentCard:
+		return "card"
+	case PaymentPass:
+		return "pass"
+	case PaymentBalance:
+		return "balance"
+	}
+	return "unknown"
+}
+
+// Payment 出场时的付款
+type Payment struct {
+	Method    PaymentMethod
+	Tendered  float64 // 现金付款时收到的金额
+	CardToken string  // 银行卡付款时的卡令牌
+}
+
+// PayCash 现金付款
+func PayCash(tendered float64) Payment {
+	return Payment{Method: PaymentCash, Tendered: tendered}
+}
+
+// PayByCard 银行卡付款
+func PayByCard(cardToken string) Payment {
+	return Payment{Method: PaymentCard, CardToken: cardToken}
+}
+
+// PayWithPass 月卡出场
+func PayWithPass() Payment {
+	return Payment{Method: PaymentPass}
+}
+
+// PayFromBalance 从会员余额扣款
+func PayFromBalance() Payment {
+	return Payment{Method: PaymentBalance}
+}
+
+// CardProcessor 银行卡收单接口
+type CardProcessor interface {
+	Charge(cardToken string, amount float64) (authCode string, err error)
+	Refund(authCode string, amount float64) error
+}
+
+// SimulatedCardProcessor 模拟收单，空卡令牌被拒绝，其余全部批准
+type SimulatedCardProcessor struct {
+	seq int
+	mu  sync.Mutex
+}
+
+func NewSimulatedCardProcessor() *SimulatedCardProcessor {
+	return &SimulatedCardProcessor{}
+}
+
+func (c *SimulatedCardProcessor) Charge(cardToken string, amount float64) (string, error) {
+	if cardToken == "" {
+		return "", ErrPaymentDeclined
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.seq++
+	return fmt.Sprintf("AUTH-%06d", c.seq), nil
+}
+
+func (c *SimulatedCardProcessor) Refund(authCode string, amount float64) error {
+	return nil
+}
+
+// Receipt 出场付款的收据
+type Receipt struct {
+	ID           string
+	TicketID     string
+	LicensePlate string
+	EntryTime    time.Time
+	ExitTime     time.Time
+	ParkingFee   float64
+	ChargingFee  float64
+	Discount     float64
+	Total        float64
+	Method       PaymentMethod
+	Tendered     float64 // 现金付款时收到的金额
+	Change       float64 // 现金找零
+	AuthCode     string  // 银行卡授权码
+	AccountID    string  // 余额或月卡付款的会员账户
+	IssuedAt     time.Time
+}
+
+// SetCardProcessor 设置银行卡收单
+func (p *ParkingLot) SetCardProcessor(processor CardProcessor) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.cardProcessor = processor
+}
+
+func (p *ParkingLot) getCardProcessor() CardProcessor {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.cardProcessor
+}
+
+// settleFees 按出场时间计算停车费、充电费用和减免
+// 有效月卡免除停车费，否则使用停车票上的验证码减免
+func (p *ParkingLot) settleFees(ticket *Ticket, at time.Time) {
+	ticket.ParkingFee = p.getPricing().CalculateFee(ticket.VehicleType, ticket.EntryTime, at)
+	ticket.CoveredByPass = p.members.hasPass(ticket.LicensePlate, at)
+	switch {
+	case ticket.CoveredByPass:
+		ticket.Discount = ticket.ParkingFee
+	case ticket.Validation != nil:
+		ticket.Discount = ticket.Validation.discount(ticket.ParkingFee)
+	default:
+		ticket.Discount = 0
+	}
+	ticket.Fee = ticket.ParkingFee - ticket.Discount
+	if ticket.Charging != nil {
+		// 出场时结束仍在进行的充电，电费和占位费计入总费用
+		if ticket.Charging.IsActive() {
+			charger, tariff := p.getCharging()
+			ticket.Charging.finish(charger, tariff, at)
+		}
+		ticket.Fee += ticket.Charging.EnergyFee + ticket.Charging.IdleFee
+	}
+}
+
+// QuoteFee 返回车辆此刻出场的应付费用，不结束停车
+func (p *ParkingLot) QuoteFee(ticketID string) (*Ticket, error) {
+	p.ticketMu.Lock()
+	defer p.ticketMu.Unlock()
+	ticket, ok := p.tickets[ticketID]
+	if !ok {
+		return nil, ErrTicketNotFound
+	}
+	if !ticket.IsActive() {
+		return nil, ErrTicketAlreadyUsed
+	}
+	quote := ticket.copy()
+	p.settleFees(quote, p.now())
+	return quote, nil
+}
+
+// chargeCard 通过收单扣款，收单可能是外部系统，调用方不能持有任何锁
+func (p *ParkingLot) chargeCard(cardToken string, amount float64) (string, error) {
+	processor := p.getCardProcessor()
+	if processor == nil {
+		return "", ErrPaymentDeclined
+	}
+	authCode, err := processor.Charge(cardToken, amount)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrPaymentDeclined, err)
+	}
+	return authCode, nil
+}
+
+// refundCard 撤销银行卡扣款，调用方不能持有任何锁
+func (p *ParkingLot) refundCard(authCode string, amount float64) {
+	if processor := p.getCardProcessor(); processor != nil {
+		processor.Refund(authCode, amount)
+	}
+}
+
+// collect 按付款方式收取停车票的应付费用并开具收据，调用方需持有 ticketMu
+// 银行卡付款已由调用方在锁外通过 chargeCard 扣款，authCode 为扣款的授权码
+func (p *ParkingLot) collect(ticket *Ticket, payment Payment, authCode string) (*Receipt, error) {
+	receipt := &Receipt{
+		TicketID:     ticket.ID,
+		LicensePlate: ticket.LicensePlate,
+		EntryTime:    ticket.EntryTime,
+		ExitTime:     ticket.ExitTime,
+		ParkingFee:   ticket.ParkingFee,
+		Discount:     ticket.Discount,
+		Total:        ticket.Fee,
+		Method:       payment.Method,
+		IssuedAt:     ticket.ExitTime,
+	}
+	if ticket.Charging != nil {
+		receipt.ChargingFee = ticket.Charging.EnergyFee + ticket.Charging.IdleFee
+	}
+
+	switch payment.Method {
+	case PaymentCash:
+		if payment.Tendered < ticket.Fee {
+			return nil, ErrInsufficientPayment
+		}
+		receipt.Tendered = payment.Tendered
+		receipt.Change = payment.Tendered - ticket.Fee
+	case PaymentCard:
+		receipt.AuthCode = authCode
+	case PaymentPass:
+		if !ticket.CoveredByPass || ticket.Fee > 0 {
+			return nil, ErrNotCoveredByPass
+		}
+		account, err := p.FindMemberByPlate(ticket.LicensePlate)
+		if err != nil {
+			return nil, err
+		}
+		receipt.AccountID = account.ID
+	case PaymentBalance:
+		accountID, err := p.members.debit(ticket.LicensePlate, ticket.Fee)
+		if err != nil {
+			return nil, err
+		}
+		receipt.AccountID = accountID
+	default:
+		return nil, fmt.Errorf("%w: unknown payment method %d", ErrPaymentDeclined, payment.Method)
+	}
+
+	p.receiptSeq++
+	receipt.ID = fmt.Sprintf("RC-%06d", p.receiptSeq)
+	return receipt, nil
+}
+
+// GetReceipt 按停车票号查询出场收据
+func (p *ParkingLot) GetReceipt(ticketID string) (*Receipt, error) {
+	p.ticketMu.Lock()
+	defer p.ticketMu.Unlock()
+	receipt, ok := p.receipts[ticketID]
+	if !ok {
+		return nil, ErrReceiptNotFound
+	}
+	copied := *receipt
+	return &copied, nil
+}
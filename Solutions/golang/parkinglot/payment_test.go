@@ -0,0 +1,246 @@
+package parkinglot
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// 测试现金、银行卡付款以及商户验证码减免
+func TestExitWithPayment(t *testing.T) {
+	clock := time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)
+	lot := newTestLot(4, &clock)
+	lot.SetPricingStrategy(NewHourlyPricing(5))
+	entry := NewEntryGate("E1", lot)
+	exit := NewExitGate("X1", lot)
+
+	cash, err := entry.Enter(NewCar("A1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	card, err := entry.Enter(NewCar("A2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock = clock.Add(3 * time.Hour)
+
+	if _, err := exit.ExitWithPayment(cash, PayCash(10)); !errors.Is(err, ErrInsufficientPayment) {
+		t.Errorf("期望 ErrInsufficientPayment，实际: %v", err)
+	}
+	if !cash.IsActive() {
+		t.Error("付款失败时车辆不应出场")
+	}
+	receipt, err := exit.ExitWithPayment(cash, PayCash(20))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if receipt.Total != 15 || receipt.Change != 5 || receipt.Method != PaymentCash {
+		t.Errorf("现金收据错误: %+v", receipt)
+	}
+	if got, err := lot.GetReceipt(cash.ID); err != nil || got.ID != receipt.ID {
+		t.Errorf("查询收据失败: %+v %v", got, err)
+	}
+
+	code, err := lot.IssueValidation("咖啡店", 50, 2, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := lot.ApplyValidation(card.ID, code.Code); err != nil {
+		t.Fatal(err)
+	}
+	if err := lot.ApplyValidation(cash.ID, code.Code); !errors.Is(err, ErrTicketAlreadyUsed) {
+		t.Errorf("期望 ErrTicketAlreadyUsed，实际: %v", err)
+	}
+	quote, err := lot.QuoteFee(card.ID)
+	if err != nil || quote.Fee != 5.5 || !card.IsActive() {
+		t.Errorf("报价错误: %+v %v", quote, err)
+	}
+	if _, err := exit.ExitWithPayment(card, PayByCard("")); !errors.Is(err, ErrPaymentDeclined) {
+		t.Errorf("期望 ErrPaymentDeclined，实际: %v", err)
+	}
+	receipt, err = exit.ExitWithPayment(card, PayByCard("tok_visa"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if receipt.ParkingFee != 15 || receipt.Discount != 9.5 || receipt.Total != 5.5 || receipt.AuthCode == "" {
+		t.Errorf("银行卡收据错误: %+v", receipt)
+	}
+}
+
+// 测试验证码只能使用一次且会过期
+func TestValidationCodeRules(t *testing.T) {
+	clock := time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)
+	lot := newTestLot(4, &clock)
+	entry := NewEntryGate("E1", lot)
+	first, _ := entry.Enter(NewCar("A1"))
+	second, _ := entry.Enter(NewCar("A2"))
+
+	code, _ := lot.IssueValidation("影院", 100, 0, clock.Add(time.Hour))
+	if err := lot.ApplyValidation(first.ID, code.Code); err != nil {
+		t.Fatal(err)
+	}
+	if err := lot.ApplyValidation(second.ID, code.Code); !errors.Is(err, ErrValidationUsed) {
+		t.Errorf("期望 ErrValidationUsed，实际: %v", err)
+	}
+	if err := lot.ApplyValidation(first.ID, "V-999999"); !errors.Is(err, ErrTicketValidated) {
+		t.Errorf("期望 ErrTicketValidated，实际: %v", err)
+	}
+	expiring, _ := lot.IssueValidation("影院", 0, 3, clock.Add(time.Hour))
+	clock = clock.Add(2 * time.Hour)
+	if err := lot.ApplyValidation(second.ID, expiring.Code); !errors.Is(err, ErrValidationExpired) {
+		t.Errorf("期望 ErrValidationExpired，实际: %v", err)
+	}
+}
+
+// 测试会员月卡免除停车费以及预付余额付款
+func TestMemberPassAndBalance(t *testing.T) {
+	clock := time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)
+	lot := newTestLot(4, &clock)
+	lot.SetPricingStrategy(NewHourlyPricing(5))
+	entry := NewEntryGate("E1", lot)
+	exit := NewExitGate("X1", lot)
+
+	if _, err := lot.RegisterMember("M1", "张三", "A1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lot.BuyMonthlyPass("M1", clock); !errors.Is(err, ErrPassPriceNotSet) {
+		t.Errorf("未设置价格时期望 ErrPassPriceNotSet，实际: %v", err)
+	}
+	if err := lot.SetMonthlyPassPrice(0); !errors.Is(err, ErrInvalidAmount) {
+		t.Errorf("期望 ErrInvalidAmount，实际: %v", err)
+	}
+	if err := lot.SetMonthlyPassPrice(100); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lot.RegisterMember("M2", "李四", "A1"); !errors.Is(err, ErrPlateRegistered) {
+		t.Errorf("期望 ErrPlateRegistered，实际: %v", err)
+	}
+	if _, err := lot.BuyMonthlyPass("M1", clock); !errors.Is(err, ErrInsufficientBalance) {
+		t.Errorf("期望 ErrInsufficientBalance，实际: %v", err)
+	}
+	if balance, err := lot.TopUp("M1", 120); err != nil || balance != 120 {
+		t.Fatalf("充值失败: %.2f %v", balance, err)
+	}
+	pass, err := lot.BuyMonthlyPass("M1", clock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !pass.Start.Equal(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)) || !pass.End.Equal(time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("月卡有效期错误: %+v", pass)
+	}
+
+	ticket, _ := entry.Enter(NewCar("A1"))
+	clock = clock.Add(2 * time.Hour)
+	receipt, err := exit.ExitWithPayment(ticket, PayWithPass())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if receipt.Total != 0 || receipt.Discount != 10 || receipt.AccountID != "M1" {
+		t.Errorf("月卡收据错误: %+v", receipt)
+	}
+
+	// 月卡过期后从余额扣款
+	clock = time.Date(2024, 4, 2, 9, 0, 0, 0, time.UTC)
+	ticket, _ = entry.Enter(NewCar("A1"))
+	clock = clock.Add(time.Hour)
+	if _, err := exit.ExitWithPayment(ticket, PayWithPass()); !errors.Is(err, ErrNotCoveredByPass) {
+		t.Errorf("期望 ErrNotCoveredByPass，实际: %v", err)
+	}
+	if _, err := exit.ExitWithPayment(ticket, PayFromBalance()); err != nil {
+		t.Fatal(err)
+	}
+	if account, _ := lot.GetMember("M1"); account.Balance != 15 {
+		t.Errorf("余额应为 15，实际: %.2f", account.Balance)
+	}
+}
+
+// reentrantCardProcessor 扣款时回调停车场，用于检查扣款期间没有持有停车场的锁
+type reentrantCardProcessor struct {
+	SimulatedCardProcessor
+	onCharge func()
+	refunds  int
+}
+
+func (c *reentrantCardProcessor) Charge(cardToken string, amount float64) (string, error) {
+	c.onCharge()
+	return c.SimulatedCardProcessor.Charge(cardToken, amount)
+}
+
+func (c *reentrantCardProcessor) Refund(authCode string, amount float64) error {
+	c.refunds++
+	return nil
+}
+
+// 测试银行卡扣款在锁外进行，扣款期间停车票不能再修改或重复出场
+func TestCardChargedOutsideLock(t *testing.T) {
+	clock := time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)
+	lot := newTestLot(4, &clock)
+	lot.SetPricingStrategy(NewHourlyPricing(5))
+	ticket, err := NewEntryGate("E1", lot).Enter(NewCar("A1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, err := lot.IssueValidation("咖啡店", 50, 0, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock = clock.Add(2 * time.Hour)
+
+	exit := NewExitGate("X1", lot)
+	processor := &reentrantCardProcessor{}
+	processor.onCharge = func() {
+		if quote, err := lot.QuoteFee(ticket.ID); err != nil || quote.Fee != 10 {
+			t.Errorf("扣款期间报价错误: %+v %v", quote, err)
+		}
+		if err := lot.ApplyValidation(ticket.ID, code.Code); !errors.Is(err, ErrExitInProgress) {
+			t.Errorf("期望 ErrExitInProgress，实际: %v", err)
+		}
+		if _, err := exit.ExitWithPayment(ticket, PayCash(10)); !errors.Is(err, ErrExitInProgress) {
+			t.Errorf("期望 ErrExitInProgress，实际: %v", err)
+		}
+	}
+	lot.SetCardProcessor(processor)
+
+	if _, err := exit.ExitWithPayment(ticket, PayByCard("")); !errors.Is(err, ErrPaymentDeclined) {
+		t.Errorf("期望 ErrPaymentDeclined，实际: %v", err)
+	}
+	// 扣款失败后停车票恢复为可修改
+	processor.onCharge = func() {}
+	if err := lot.ApplyValidation(ticket.ID, code.Code); err != nil {
+		t.Fatal(err)
+	}
+	receipt, err := exit.ExitWithPayment(ticket, PayByCard("tok_visa"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if closed, _ := lot.GetTicket(ticket.ID); receipt.Total != 5 || receipt.AuthCode == "" || closed.IsActive() {
+		t.Errorf("银行卡收据错误: %+v", receipt)
+	}
+}
+
+// 测试出场写日志失败时撤销银行卡扣款
+func TestCardRefundedWhenExitFails(t *testing.T) {
+	clock := time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)
+	lot := newTestLot(4, &clock)
+	lot.SetPricingStrategy(NewHourlyPricing(5))
+	processor := &reentrantCardProcessor{onCharge: func() {}}
+	lot.SetCardProcessor(processor)
+	ticket, err := NewEntryGate("E1", lot).Enter(NewCar("A1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := lot.EnablePersistence(failingStore{}); err != nil {
+		t.Fatal(err)
+	}
+	clock = clock.Add(time.Hour)
+
+	if _, err := NewExitGate("X1", lot).ExitWithPayment(ticket, PayByCard("tok_visa")); !errors.Is(err, ErrPersistence) {
+		t.Errorf("期望 ErrPersistence，实际: %v", err)
+	}
+	if processor.refunds != 1 || !ticket.IsActive() {
+		t.Errorf("写日志失败时应退款并保持在场: 退款 %d 次", processor.refunds)
+	}
+	if _, err := lot.QuoteFee(ticket.ID); err != nil {
+		t.Errorf("出场失败后应能再次结算: %v", err)
+	}
+}
@@ -0,0 +1,507 @@
+package parkinglot
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Store 停车场状态的持久化存储：快照加预写日志
+type Store interface {
+	// Load 返回最近的快照（没有时为nil）以及快照之后追加的日志
+	Load() (*Snapshot, []LogRecord, error)
+	// Append 追加一条日志，返回前必须已落盘
+	Append(record LogRecord) error
+	// SaveSnapshot 保存快照并丢弃快照已包含的日志
+	SaveSnapshot(snapshot *Snapshot) error
+}
+
+// 日志操作类型
+const (
+	OpAddLevel        = "add-level"
+	OpPark            = "park"
+	OpExit            = "exit"
+	OpUnpark          = "unpark"
+	OpAddSpot         = "add-spot"
+	OpCloseSpot       = "close-spot"
+	OpReopenSpot      = "reopen-spot"
+	OpCharging        = "charging"
+	OpValidate        = "validate"
+	OpMember          = "member"
+	OpIssueValidation = "issue-validation"
+)
+
+// LogRecord 预写日志中的一条记录，按操作类型填写相应字段
+type LogRecord struct {
+	Seq        int64           `json:"seq"`
+	Op         string          `json:"op"`
+	Level      *LevelState     `json:"level,omitempty"`
+	Spot       *SpotID         `json:"spot,omitempty"`
+	SpotType   string          `json:"spotType,omitempty"`
+	Vehicle    *VehicleState   `json:"vehicle,omitempty"`
+	Ticket     *Ticket         `json:"ticket,omitempty"`
+	Receipt    *Receipt        `json:"receipt,omitempty"`
+	Member     *MemberAccount  `json:"member,omitempty"`
+	Validation *ValidationCode `json:"validation,omitempty"`
+}
+
+// Snapshot 停车场某一时刻的完整状态
+type Snapshot struct {
+	Seq         int64                `json:"seq"` // 快照已包含的最后一条日志的序号
+	TicketSeq   int                  `json:"ticketSeq"`
+	Levels      []LevelState         `json:"levels"`
+	Vehicles    []ParkedVehicleState `json:"vehicles"`
+	Tickets     []*Ticket            `json:"tickets"`
+	Receipts    []*Receipt           `json:"receipts,omitempty"`
+	Members     []*MemberAccount     `json:"members,omitempty"`
+	Validations []*ValidationCode    `json:"validations,omitempty"`
+}
+
+// LevelState 停车层及其车位
+type LevelState struct {
+	Floor int         `json:"floor"`
+	Spots []SpotState `json:"spots"`
+}
+
+// SpotState 车位的编号、类型和维护状态
+type SpotState struct {
+	Number int    `json:"number"`
+	Type   string `json:"type"`
+	Closed bool   `json:"closed,omitempty"`
+}
+
+// VehicleState 恢复车辆所需的信息
+type VehicleState struct {
+	LicensePlate   string      `json:"licensePlate"`
+	Type           VehicleType `json:"type"`
+	Electric       bool        `json:"electric,omitempty"`
+	DisabledPermit bool        `json:"disabledPermit,omitempty"`
+	SpotsRequired  int         `json:"spotsRequired,omitempty"`
+}
+
+// ParkedVehicleState 在场车辆及其车位
+type ParkedVehicleState struct {
+	Spot    SpotID       `json:"spot"`
+	Vehicle VehicleState `json:"vehicle"`
+}
+
+func vehicleStateOf(vehicle Vehicle) *VehicleState {
+	return &VehicleState{
+		LicensePlate:   vehicle.GetLicensePlate(),
+		Type:           vehicle.GetType(),
+		Electric:       isElectric(vehicle),
+		DisabledPermit: hasDisabledPermit(vehicle),
+		SpotsRequired:  spotsRequired(vehicle),
+	}
+}
+
+func (s VehicleState) vehicle() Vehicle {
+	return &BaseVehicle{
+		licensePlate:   s.LicensePlate,
+		vehicleType:    s.Type,
+		electric:       s.Electric,
+		disabledPermit: s.DisabledPermit,
+		spotsRequired:  s.SpotsRequired,
+	}
+}
+
+func levelStateOf(level *Level) *LevelState {
+	state := &LevelState{Floor: level.GetFloor()}
+	for _, spot := range level.getSpots() {
+		state.Spots = append(state.Spots, SpotState{
+			Number: spot.GetSpotNumber(),
+			Type:   spot.GetSpotType().String(),
+			Closed: spot.IsClosed(),
+		})
+	}
+	return state
+}
+
+func (s LevelState) build() (*Level, error) {
+	level := &Level{floor: s.Floor}
+	for _, spotState := range s.Spots {
+		spotType, err := ParseSpotType(spotState.Type)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrCorruptState, err)
+		}
+		spot := NewParkingSpotOfType(spotState.Number, spotType)
+		spot.closed = spotState.Closed
+		level.parkingSpots = append(level.parkingSpots, spot)
+	}
+	level.ensureIndex()
+	return level, nil
+}
+
+// journal 开启持久化后，所有修改操作通过它串行化并写入预写日志，保证日志顺序与状态变化顺序一致
+type journal struct {
+	store Store
+	seq   int64
+	mu    sync.Mutex
+}
+
+// beginChange 开始一次修改，未开启持久化时返回nil，nil journal 的方法都是空操作
+func (p *ParkingLot) beginChange() *journal {
+	p.mu.RLock()
+	j := p.journal
+	p.mu.RUnlock()
+	if j != nil {
+		j.mu.Lock()
+	}
+	return j
+}
+
+func (j *journal) end() {
+	if j != nil {
+		j.mu.Unlock()
+	}
+}
+
+// append 写入一条日志，调用方需已通过 beginChange 持有锁
+func (j *journal) append(record LogRecord) error {
+	if j == nil {
+		return nil
+	}
+	record.Seq = j.seq + 1
+	if err := j.store.Append(record); err != nil {
+		return fmt.Errorf("%w: %v", ErrPersistence, err)
+	}
+	j.seq = record.Seq
+	return nil
+}
+
+// EnablePersistence 开启持久化：先将当前状态保存为快照，之后的修改都写入预写日志
+func (p *ParkingLot) EnablePersistence(store Store) error {
+	j := &journal{store: store}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if err := store.SaveSnapshot(p.snapshot(0)); err != nil {
+		return fmt.Errorf("%w: %v", ErrPersistence, err)
+	}
+	p.mu.Lock()
+	p.journal = j
+	p.mu.Unlock()
+	return nil
+}
+
+// Checkpoint 将当前状态保存为快照并清理日志，缩短恢复时需要重放的日志
+func (p *ParkingLot) Checkpoint() error {
+	j := p.beginChange()
+	if j == nil {
+		return ErrPersistenceDisabled
+	}
+	defer j.end()
+	if err := j.store.SaveSnapshot(p.snapshot(j.seq)); err != nil {
+		return fmt.Errorf("%w: %v", ErrPersistence, err)
+	}
+	return nil
+}
+
+// snapshot 生成当前状态的快照，开启持久化后调用方需持有 journal 锁
+func (p *ParkingLot) snapshot(seq int64) *Snapshot {
+	snapshot := &Snapshot{Seq: seq}
+	for _, level := range p.getLevels() {
+		snapshot.Levels = append(snapshot.Levels, *levelStateOf(level))
+	}
+
+	p.vehicles.mu.Lock()
+	for _, record := range p.vehicles.vehicles {
+		if record.spot == nil {
+			continue
+		}
+		snapshot.Vehicles = append(snapshot.Vehicles, ParkedVehicleState{
+			Spot:    record.level.GetSpotID(record.spot),
+			Vehicle: *vehicleStateOf(record.vehicle),
+		})
+	}
+	p.vehicles.mu.Unlock()
+	sort.Slice(snapshot.Vehicles, func(i, j int) bool {
+		return snapshot.Vehicles[i].Vehicle.LicensePlate < snapshot.Vehicles[j].Vehicle.LicensePlate
+	})
+
+	p.ticketMu.Lock()
+	snapshot.TicketSeq = p.ticketSeq
+	for _, ticket := range p.tickets {
+		snapshot.Tickets = append(snapshot.Tickets, ticket.copy())
+	}
+	for _, receipt := range p.receipts {
+		copied := *receipt
+		snapshot.Receipts = append(snapshot.Receipts, &copied)
+	}
+	p.ticketMu.Unlock()
+	sort.Slice(snapshot.Tickets, func(i, j int) bool {
+		return snapshot.Tickets[i].ID < snapshot.Tickets[j].ID
+	})
+	sort.Slice(snapshot.Receipts, func(i, j int) bool {
+		return snapshot.Receipts[i].ID < snapshot.Receipts[j].ID
+	})
+	snapshot.Members = p.members.snapshot()
+	snapshot.Validations = p.validations.snapshot()
+	return snapshot
+}
+
+// RestoreParkingLot 从存储中恢复停车场：加载快照后按顺序重放日志，并继续在该存储上持久化
+// 会员账户、月卡和验证码一并恢复；计费、分配策略、充电、收单和月卡价格设置不在持久化范围内，恢复后需要重新设置；预约不会恢复
+func RestoreParkingLot(store Store) (*ParkingLot, error) {
+	snapshot, records, err := store.Load()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrPersistence, err)
+	}
+	p := NewParkingLot(0)
+	j := &journal{store: store}
+	if snapshot != nil {
+		if err := p.restoreSnapshot(snapshot); err != nil {
+			return nil, err
+		}
+		j.seq = snapshot.Seq
+	}
+	for _, record := range records {
+		if record.Seq <= j.seq {
+			continue
+		}
+		if err := p.replay(record); err != nil {
+			return nil, fmt.Errorf("replay record %d (%s): %w", record.Seq, record.Op, err)
+		}
+		j.seq = record.Seq
+	}
+	p.journal = j
+	return p, nil
+}
+
+func (p *ParkingLot) restoreSnapshot(snapshot *Snapshot) error {
+	for _, state := range snapshot.Levels {
+		level, err := state.build()
+		if err != nil {
+			return err
+		}
+		p.levels = append(p.levels, level)
+	}
+	for _, parked := range snapshot.Vehicles {
+		if err := p.restorePark(parked.Spot, parked.Vehicle.vehicle()); err != nil {
+			return err
+		}
+	}
+	for _, ticket := range snapshot.Tickets {
+		if err := p.restoreTicket(ticket); err != nil {
+			return err
+		}
+	}
+	p.ticketSeq = snapshot.TicketSeq
+	for _, receipt := range snapshot.Receipts {
+		p.restoreReceipt(receipt)
+	}
+	for _, account := range snapshot.Members {
+		p.members.restore(account)
+	}
+	for _, code := range snapshot.Validations {
+		p.validations.restore(code)
+	}
+	return nil
+}
+
+// replay 将一条日志应用到状态上，不写日志也不发布事件
+func (p *ParkingLot) replay(record LogRecord) error {
+	switch record.Op {
+	case OpAddLevel:
+		if record.Level == nil {
+			return ErrCorruptState
+		}
+		level, err := record.Level.build()
+		if err != nil {
+			return err
+		}
+		p.levels = append(p.levels, level)
+	case OpPark:
+		if record.Spot == nil || record.Vehicle == nil {
+			return ErrCorruptState
+		}
+		vehicle := record.Vehicle.vehicle()
+		if err := p.restorePark(*record.Spot, vehicle); err != nil {
+			return err
+		}
+		if record.Ticket != nil {
+			return p.restoreTicket(record.Ticket)
+		}
+	case OpExit:
+		if record.Ticket == nil {
+			return ErrCorruptState
+		}
+		ticket, ok := p.tickets[record.Ticket.ID]
+		if !ok {
+			return ErrTicketNotFound
+		}
+		ticket.ExitTime = record.Ticket.ExitTime
+		ticket.ExitGate = record.Ticket.ExitGate
+		ticket.ParkingFee = record.Ticket.ParkingFee
+		ticket.Discount = record.Ticket.Discount
+		ticket.Fee = record.Ticket.Fee
+		ticket.Charging = record.Ticket.Charging
+		ticket.Validation = record.Ticket.Validation
+		ticket.CoveredByPass = record.Ticket.CoveredByPass
+		if record.Receipt != nil {
+			p.restoreReceipt(record.Receipt)
+		}
+		if record.Member != nil {
+			// 余额付款出场时记录扣款后的会员账户
+			p.members.restore(record.Member)
+		}
+		if ticket.level.releaseSpot(ticket.spot, ticket.vehicle) != nil {
+			p.vehicles.remove(ticket.vehicle)
+		}
+	case OpUnpark:
+		level, spot, err := p.findSpot(record.Spot)
+		if err != nil {
+			return err
+		}
+		if vehicle := level.releaseSpot(spot, nil); vehicle != nil {
+			p.vehicles.remove(vehicle)
+		}
+		if record.Ticket != nil {
+			// 按车位号取车时关闭的停车票
+			ticket, ok := p.tickets[record.Ticket.ID]
+			if !ok {
+				return ErrTicketNotFound
+			}
+			vehicle, level, spot := ticket.vehicle, ticket.level, ticket.spot
+			*ticket = *record.Ticket.copy()
+			ticket.vehicle, ticket.level, ticket.spot = vehicle, level, spot
+		}
+	case OpAddSpot:
+		if record.Spot == nil {
+			return ErrCorruptState
+		}
+		level := p.findLevel(record.Spot.Floor)
+		spotType, err := ParseSpotType(record.SpotType)
+		if level == nil || err != nil {
+			return ErrCorruptState
+		}
+		if spot := level.AddSpot(spotType); spot.GetSpotNumber() != record.Spot.Spot {
+			return ErrCorruptState
+		}
+	case OpCloseSpot, OpReopenSpot:
+		if record.Spot == nil {
+			return ErrCorruptState
+		}
+		level := p.findLevel(record.Spot.Floor)
+		if level == nil {
+			return ErrLevelNotFound
+		}
+		if record.Op == OpCloseSpot {
+			return level.CloseSpot(record.Spot.Spot)
+		}
+		return level.ReopenSpot(record.Spot.Spot)
+	case OpCharging:
+		if record.Ticket == nil {
+			return ErrCorruptState
+		}
+		ticket, ok := p.tickets[record.Ticket.ID]
+		if !ok {
+			return ErrTicketNotFound
+		}
+		ticket.Charging = record.Ticket.Charging
+	case OpValidate:
+		if record.Ticket == nil {
+			return ErrCorruptState
+		}
+		ticket, ok := p.tickets[record.Ticket.ID]
+		if !ok {
+			return ErrTicketNotFound
+		}
+		ticket.Validation = record.Ticket.Validation
+		if ticket.Validation != nil {
+			if code, ok := p.validations.codes[ticket.Validation.Code]; ok {
+				code.TicketID = ticket.ID
+			}
+		}
+	case OpMember:
+		if record.Member == nil {
+			return ErrCorruptState
+		}
+		p.members.restore(record.Member)
+	case OpIssueValidation:
+		if record.Validation == nil {
+			return ErrCorruptState
+		}
+		p.validations.restore(record.Validation)
+	default:
+		return fmt.Errorf("%w: unknown op %q", ErrCorruptState, record.Op)
+	}
+	return nil
+}
+
+// restorePark 将车辆恢复到指定车位，占用多个车位的车辆从该车位开始依次占用
+func (p *ParkingLot) restorePark(id SpotID, vehicle Vehicle) error {
+	level, spot, err := p.findSpot(&id)
+	if err != nil {
+		return err
+	}
+	claimed := false
+	if n := spotsRequired(vehicle); n > 1 {
+		claimed = level.claimRunAt(spot, vehicle, n) != nil
+	} else {
+		claimed = level.claimSpot(spot, vehicle)
+	}
+	if !claimed {
+		return fmt.Errorf("%w: spot %s is not free", ErrCorruptState, id)
+	}
+	if err := p.vehicles.reserve(vehicle); err != nil {
+		return err
+	}
+	p.vehicles.place(vehicle, level, spot)
+	return nil
+}
+
+// restoreTicket 恢复停车票，未出场的停车票关联到车位上的车辆
+func (p *ParkingLot) restoreTicket(state *Ticket) error {
+	ticket := state.copy()
+	level, spot, err := p.findSpot(&SpotID{Floor: ticket.Floor, Spot: ticket.SpotNumber})
+	if err != nil {
+		return err
+	}
+	ticket.level = level
+	ticket.spot = spot
+	if record, ok := p.vehicles.find(ticket.LicensePlate); ok && record.spot == spot {
+		ticket.vehicle = record.vehicle
+	} else if ticket.IsActive() {
+		return fmt.Errorf("%w: vehicle of ticket %s is not parked", ErrCorruptState, ticket.ID)
+	}
+	p.tickets[ticket.ID] = ticket
+	var seq int
+	if _, err := fmt.Sscanf(ticket.ID, "T-%d", &seq); err == nil && seq > p.ticketSeq {
+		p.ticketSeq = seq
+	}
+	return nil
+}
+
+// restoreReceipt 恢复出场收据，收据号接着已有的最大序号
+func (p *ParkingLot) restoreReceipt(state *Receipt) {
+	receipt := *state
+	p.receipts[receipt.TicketID] = &receipt
+	var seq int
+	if _, err := fmt.Sscanf(receipt.ID, "RC-%d", &seq); err == nil && seq > p.receiptSeq {
+		p.receiptSeq = seq
+	}
+}
+
+func (p *ParkingLot) findLevel(floor int) *Level {
+	for _, level := range p.getLevels() {
+		if level.GetFloor() == floor {
+			return level
+		}
+	}
+	return nil
+}
+
+func (p *ParkingLot) findSpot(id *SpotID) (*Level, *ParkingSpot, error) {
+	if id == nil {
+		return nil, nil, ErrCorruptState
+	}
+	level := p.findLevel(id.Floor)
+	if level == nil {
+		return nil, nil, ErrLevelNotFound
+	}
+	spot := level.GetSpot(id.Spot)
+	if spot == nil {
+		return nil, nil, ErrSpotNotFound
+	}
+	return level, spot, nil
+}
@@ -0,0 +1,325 @@
+package parkinglot
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// newPersistentTestLot 创建开启了文件持久化的停车场
+func newPersistentTestLot(t *testing.T, dir string, clock *time.Time) (*ParkingLot, *FileStore) {
+	t.Helper()
+	store, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lot := NewParkingLot(1)
+	lot.now = func() time.Time { return *clock }
+	if err := lot.EnablePersistence(store); err != nil {
+		t.Fatal(err)
+	}
+	lot.AddLevel(NewLevel(1, 8))
+	return lot, store
+}
+
+// restoreTestLot 模拟重启：重新打开存储并恢复停车场
+func restoreTestLot(t *testing.T, dir string, clock *time.Time) (*ParkingLot, *FileStore) {
+	t.Helper()
+	store, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lot, err := RestoreParkingLot(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lot.now = func() time.Time { return *clock }
+	return lot, store
+}
+
+// 测试重启后按快照和日志恢复在场车辆、停车票和车位状态
+func TestRestoreFromLog(t *testing.T) {
+	dir := t.TempDir()
+	clock := time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)
+	lot, store := newPersistentTestLot(t, dir, &clock)
+	entry := NewEntryGate("E1", lot)
+	exit := NewExitGate("X1", lot)
+
+	first, err := entry.Enter(NewCar("A1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := entry.Enter(NewCar("A2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := entry.Enter(NewMotorcycle("M1")); err != nil {
+		t.Fatal(err)
+	}
+	clock = clock.Add(time.Hour)
+	receipt, err := exit.ExitWithPayment(first, PayCash(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !lot.ParkVehicle(NewTruck("T1")) {
+		t.Fatal("卡车停车失败")
+	}
+	if !lot.UnparkVehicleAt(SpotID{Floor: 1, Spot: 6}) {
+		t.Fatal("按车位取车失败")
+	}
+	if err := lot.CloseSpot(SpotID{Floor: 1, Spot: 3}); err != nil {
+		t.Fatal(err)
+	}
+	added, err := lot.AddSpot(1, SpotTruck)
+	if err != nil || added != (SpotID{Floor: 1, Spot: 8}) {
+		t.Fatalf("增加车位失败: %v %v", added, err)
+	}
+	want := lot.GetAvailability()
+	store.Close()
+
+	restored, store := restoreTestLot(t, dir, &clock)
+	defer store.Close()
+	if got := restored.GetAvailability(); !reflect.DeepEqual(got, want) {
+		t.Errorf("恢复后的车位统计不一致\n期望: %+v\n实际: %+v", want, got)
+	}
+	if id, err := restored.FindVehicle("A2"); err != nil || id != second.SpotID() {
+		t.Errorf("A2 应停在 %v，实际: %v %v", second.SpotID(), id, err)
+	}
+	if id, err := restored.FindVehicle("M1"); err != nil || id != (SpotID{Floor: 1, Spot: 4}) {
+		t.Errorf("M1 应停在 L1-004，实际: %v %v", id, err)
+	}
+	for _, plate := range []string{"A1", "T1"} {
+		if _, err := restored.FindVehicle(plate); !errors.Is(err, ErrVehicleNotFound) {
+			t.Errorf("%s 已离场，实际: %v", plate, err)
+		}
+	}
+	if ticket, err := restored.GetTicket(first.ID); err != nil || ticket.IsActive() || !ticket.ExitTime.Equal(clock) {
+		t.Errorf("已出场的停车票恢复错误: %+v %v", ticket, err)
+	}
+	if got, err := restored.GetReceipt(first.ID); err != nil || *got != *receipt {
+		t.Errorf("收据恢复错误: %+v %v", got, err)
+	}
+
+	// 恢复后可以继续凭票出场，新票号接着原来的序号
+	restored.SetPricingStrategy(NewHourlyPricing(5))
+	clock = clock.Add(time.Hour)
+	if fee, err := NewExitGate("X1", restored).Exit(second); err != nil || fee != 10 {
+		t.Errorf("恢复后出场失败: %.2f %v", fee, err)
+	}
+	ticket, err := NewEntryGate("E1", restored).Enter(NewCar("A3"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ticket.ID != "T-000004" {
+		t.Errorf("票号应为 T-000004，实际: %s", ticket.ID)
+	}
+}
+
+// 测试会员余额、月卡和验证码在重启后从日志和快照恢复
+func TestRestoreMembersAndValidations(t *testing.T) {
+	dir := t.TempDir()
+	clock := time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)
+	lot, store := newPersistentTestLot(t, dir, &clock)
+	lot.SetPricingStrategy(NewHourlyPricing(5))
+	if err := lot.SetMonthlyPassPrice(100); err != nil {
+		t.Fatal(err)
+	}
+	entry := NewEntryGate("E1", lot)
+	exit := NewExitGate("X1", lot)
+
+	if _, err := lot.RegisterMember("M1", "张三", "A1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lot.RegisterMember("M2", "李四", "A2"); err != nil {
+		t.Fatal(err)
+	}
+	lot.TopUp("M1", 150)
+	lot.TopUp("M2", 30)
+	if _, err := lot.BuyMonthlyPass("M1", clock); err != nil {
+		t.Fatal(err)
+	}
+	used, _ := lot.IssueValidation("咖啡店", 50, 0, time.Time{})
+	unused, _ := lot.IssueValidation("影院", 0, 3, time.Time{})
+	ticket, err := entry.Enter(NewCar("A2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := lot.ApplyValidation(ticket.ID, used.Code); err != nil {
+		t.Fatal(err)
+	}
+	clock = clock.Add(2 * time.Hour)
+	if _, err := exit.ExitWithPayment(ticket, PayFromBalance()); err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+
+	checkMembers := func(restored *ParkingLot) {
+		t.Helper()
+		if account, err := restored.GetMember("M1"); err != nil || account.Balance != 50 || len(account.Passes) != 1 {
+			t.Errorf("M1 恢复错误: %+v %v", account, err)
+		}
+		if account, err := restored.FindMemberByPlate("A2"); err != nil || account.ID != "M2" || account.Balance != 25 {
+			t.Errorf("M2 恢复错误: %+v %v", account, err)
+		}
+	}
+
+	// 先从日志恢复，再保存快照后从快照恢复
+	restored, store := restoreTestLot(t, dir, &clock)
+	checkMembers(restored)
+	if err := restored.Checkpoint(); err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+	restored, store = restoreTestLot(t, dir, &clock)
+	defer store.Close()
+	checkMembers(restored)
+
+	if _, err := restored.BuyMonthlyPass("M1", clock); !errors.Is(err, ErrPassPriceNotSet) {
+		t.Errorf("月卡价格不恢复，期望 ErrPassPriceNotSet，实际: %v", err)
+	}
+	again, err := NewEntryGate("E1", restored).Enter(NewCar("A3"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := restored.ApplyValidation(again.ID, used.Code); !errors.Is(err, ErrValidationUsed) {
+		t.Errorf("期望 ErrValidationUsed，实际: %v", err)
+	}
+	if err := restored.ApplyValidation(again.ID, unused.Code); err != nil {
+		t.Errorf("未使用的验证码应能使用: %v", err)
+	}
+	if code, err := restored.IssueValidation("书店", 10, 0, time.Time{}); err != nil || code.Code != "V-000003" {
+		t.Errorf("验证码编号应为 V-000003，实际: %+v %v", code, err)
+	}
+}
+
+// 测试检查点清空日志，以及忽略写到一半的日志
+func TestCheckpointAndTornLog(t *testing.T) {
+	dir := t.TempDir()
+	clock := time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)
+	lot, store := newPersistentTestLot(t, dir, &clock)
+	if _, err := NewEntryGate("E1", lot).Enter(NewCar("A1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := lot.Checkpoint(); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(filepath.Join(dir, logFileName)); err != nil || info.Size() != 0 {
+		t.Errorf("检查点后日志应为空: %v %v", info, err)
+	}
+	if _, err := NewEntryGate("E1", lot).Enter(NewCar("A2")); err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+
+	// 模拟写日志时断电
+	log, err := os.OpenFile(filepath.Join(dir, logFileName), os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	log.WriteString(`{"seq":3,"op":"park","spot":{"Fl`)
+	log.Close()
+
+	restored, store := restoreTestLot(t, dir, &clock)
+	for _, plate := range []string{"A1", "A2"} {
+		if _, err := restored.FindVehicle(plate); err != nil {
+			t.Errorf("%s 应在场: %v", plate, err)
+		}
+	}
+	if !restored.ParkVehicle(NewCar("A3")) {
+		t.Fatal("恢复后停车失败")
+	}
+	store.Close()
+
+	restored, store = restoreTestLot(t, dir, &clock)
+	defer store.Close()
+	if _, err := restored.FindVehicle("A3"); err != nil {
+		t.Errorf("截掉残缺记录后追加的日志应能恢复: %v", err)
+	}
+}
+
+// 测试按车位号取车时关闭停车票，检查点和日志都能恢复
+func TestRestoreAfterUnparkBySpot(t *testing.T) {
+	dir := t.TempDir()
+	clock := time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)
+	lot, store := newPersistentTestLot(t, dir, &clock)
+	lot.SetPricingStrategy(NewHourlyPricing(5))
+	entry := NewEntryGate("E1", lot)
+	first, err := entry.Enter(NewCar("A1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := entry.Enter(NewCar("A2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clock = clock.Add(time.Hour)
+	if !lot.UnparkVehicleAt(first.SpotID()) {
+		t.Fatal("按车位号取车失败")
+	}
+	if err := lot.Checkpoint(); err != nil {
+		t.Fatal(err)
+	}
+	// 检查点之后的取车只在日志中
+	if !lot.UnparkVehicleAt(second.SpotID()) {
+		t.Fatal("按车位号取车失败")
+	}
+	store.Close()
+
+	restored, store := restoreTestLot(t, dir, &clock)
+	defer store.Close()
+	for _, ticket := range []*Ticket{first, second} {
+		closed, err := restored.GetTicket(ticket.ID)
+		if err != nil || closed.IsActive() || !closed.ExitTime.Equal(clock) || closed.Fee != 5 {
+			t.Errorf("%s: 停车票应已按取车时刻结算关闭: %+v %v", ticket.ID, closed, err)
+		}
+		if _, err := NewExitGate("X1", restored).Exit(ticket); !errors.Is(err, ErrTicketAlreadyUsed) {
+			t.Errorf("%s: 期望 ErrTicketAlreadyUsed，实际: %v", ticket.ID, err)
+		}
+	}
+	if got := restored.GetAvailability().Available; got != 8 {
+		t.Errorf("车位应全部空闲，实际可用 %d", got)
+	}
+}
+
+// failingStore 写日志总是失败的存储
+type failingStore struct{}
+
+func (failingStore) Load() (*Snapshot, []LogRecord, error) { return nil, nil, nil }
+func (failingStore) Append(LogRecord) error                { return errors.New("disk full") }
+func (failingStore) SaveSnapshot(*Snapshot) error          { return nil }
+
+// 测试写日志失败时撤销修改
+func TestPersistenceFailureRollsBack(t *testing.T) {
+	clock := time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)
+	lot := newTestLot(4, &clock)
+	ticket, err := NewEntryGate("E1", lot).Enter(NewCar("A1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := lot.EnablePersistence(failingStore{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewEntryGate("E1", lot).Enter(NewCar("A2")); !errors.Is(err, ErrPersistence) {
+		t.Errorf("期望 ErrPersistence，实际: %v", err)
+	}
+	if _, err := lot.FindVehicle("A2"); !errors.Is(err, ErrVehicleNotFound) {
+		t.Errorf("写日志失败的车辆不应在场: %v", err)
+	}
+	if _, err := NewExitGate("X1", lot).Exit(ticket); !errors.Is(err, ErrPersistence) {
+		t.Errorf("期望 ErrPersistence，实际: %v", err)
+	}
+	if !ticket.IsActive() {
+		t.Error("写日志失败时停车票不应出场")
+	}
+	if _, err := lot.FindVehicle("A1"); err != nil {
+		t.Errorf("写日志失败时车辆应仍在场: %v", err)
+	}
+	if got := lot.GetAvailability().Available; got != 3 {
+		t.Errorf("可用车位应为 3，实际: %d", got)
+	}
+}
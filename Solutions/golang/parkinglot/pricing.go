@@ -0,0 +1,155 @@
+package parkinglot
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// PricingStrategy 停车计费策略
+type PricingStrategy interface {
+	CalculateFee(vehicleType VehicleType, entry, exit time.Time) float64
+}
+
+// startedHours 返回停车时长按小时向上取整的结果，不足一小时按一小时计
+func startedHours(entry, exit time.Time) int {
+	if !exit.After(entry) {
+		return 0
+	}
+	return int(math.Ceil(exit.Sub(entry).Hours()))
+}
+
+// HourlyPricing 按小时计费，不足一小时按一小时计
+type HourlyPricing struct {
+	rate float64
+}
+
+func NewHourlyPricing(rate float64) *HourlyPricing {
+	return &HourlyPricing{rate: rate}
+}
+
+func (p *HourlyPricing) CalculateFee(vehicleType VehicleType, entry, exit time.Time) float64 {
+	return float64(startedHours(entry, exit)) * p.rate
+}
+
+// DailyCapPricing 每24小时的费用不超过封顶价
+type DailyCapPricing struct {
+	base     PricingStrategy
+	dailyCap float64
+}
+
+func NewDailyCapPricing(base PricingStrategy, dailyCap float64) *DailyCapPricing {
+	return &DailyCapPricing{base: base, dailyCap: dailyCap}
+}
+
+func (p *DailyCapPricing) CalculateFee(vehicleType VehicleType, entry, exit time.Time) float64 {
+	fee := 0.0
+	for start := entry; exit.After(start); start = start.Add(24 * time.Hour) {
+		end := start.Add(24 * time.Hour)
+		if end.After(exit) {
+			end = exit
+		}
+		fee += math.Min(p.base.CalculateFee(vehicleType, start, end), p.dailyCap)
+	}
+	return fee
+}
+
+// VehicleTypePricing 不同车辆类型使用不同的计费策略，未配置的类型使用默认策略
+type VehicleTypePricing struct {
+	strategies map[VehicleType]PricingStrategy
+	fallback   PricingStrategy
+	mu         sync.RWMutex
+}
+
+func NewVehicleTypePricing(fallback PricingStrategy) *VehicleTypePricing {
+	return &VehicleTypePricing{
+		strategies: make(map[VehicleType]PricingStrategy),
+		fallback:   fallback,
+	}
+}
+
+// SetStrategy 为指定车辆类型设置计费策略
+func (p *VehicleTypePricing) SetStrategy(vehicleType VehicleType, strategy PricingStrategy) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.strategies[vehicleType] = strategy
+}
+
+func (p *VehicleTypePricing) CalculateFee(vehicleType VehicleType, entry, exit time.Time) float64 {
+	p.mu.RLock()
+	strategy, ok := p.strategies[vehicleType]
+	p.mu.RUnlock()
+	if ok {
+		return strategy.CalculateFee(vehicleType, entry, exit)
+	}
+	return p.fallback.CalculateFee(vehicleType, entry, exit)
+}
+
+// TariffPricing 分时段计费：逐小时按该小时开始时刻所属的时段收费
+// 夜间时段优先于周末，夜间时段可以跨零点（如22点到次日7点）
+type TariffPricing struct {
+	weekdayRate float64
+	weekendRate float64
+	nightRate   float64
+	nightStart  int
+	nightEnd    int
+}
+
+func NewTariffPricing(weekdayRate, weekendRate float64) *TariffPricing {
+	return &TariffPricing{
+		weekdayRate: weekdayRate,
+		weekendRate: weekendRate,
+	}
+}
+
+// SetNightRate 设置夜间时段 [startHour, endHour) 的费率
+func (p *TariffPricing) SetNightRate(rate float64, startHour, endHour int) {
+	p.nightRate = rate
+	p.nightStart = startHour
+	p.nightEnd = endHour
+}
+
+func (p *TariffPricing) CalculateFee(vehicleType VehicleType, entry, exit time.Time) float64 {
+	fee := 0.0
+	for i := 0; i < startedHours(entry, exit); i++ {
+		fee += p.rateAt(entry.Add(time.Duration(i) * time.Hour))
+	}
+	return fee
+}
+
+func (p *TariffPricing) rateAt(t time.Time) float64 {
+	if p.isNight(t.Hour()) {
+		return p.nightRate
+	}
+	if t.Weekday() == time.Saturday || t.Weekday() == time.Sunday {
+		return p.weekendRate
+	}
+	return p.weekdayRate
+}
+
+func (p *TariffPricing) isNight(hour int) bool {
+	if p.nightStart == p.nightEnd {
+		return false
+	}
+	if p.nightStart < p.nightEnd {
+		return hour >= p.nightStart && hour < p.nightEnd
+	}
+	return hour >= p.nightStart || hour < p.nightEnd
+}
+
+// GracePeriodPricing 停车时长不超过免费时长时不收费，超过后按完整时长计费
+type GracePeriodPricing struct {
+	base  PricingStrategy
+	grace time.Duration
+}
+
+func NewGracePeriodPricing(base PricingStrategy, grace time.Duration) *GracePeriodPricing {
+	return &GracePeriodPricing{base: base, grace: grace}
+}
+
+func (p *GracePeriodPricing) CalculateFee(vehicleType VehicleType, entry, exit time.Time) float64 {
+	if exit.Sub(entry) <= p.grace {
+		return 0
+	}
+	return p.base.CalculateFee(vehicleType, entry, exit)
+}
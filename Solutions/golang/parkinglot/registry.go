@@ -0,0 +1,71 @@
+package parkinglot
+
+import (
+	"fmt"
+	"sync"
+)
+
+// SpotID 全局唯一的车位编号，由楼层号和层内车位号组成
+type SpotID struct {
+	Floor int
+	Spot  int
+}
+
+func (id SpotID) String() string {
+	return fmt.Sprintf("L%d-%03d", id.Floor, id.Spot)
+}
+
+// parkedVehicle 在场车辆的登记信息，spot 为nil表示正在分配车位
+type parkedVehicle struct {
+	vehicle Vehicle
+	level   *Level
+	spot    *ParkingSpot
+}
+
+// vehicleRegistry 车牌号到停放位置的索引，停车和取车时维护
+type vehicleRegistry struct {
+	vehicles map[string]*parkedVehicle
+	mu       sync.Mutex
+}
+
+func newVehicleRegistry() *vehicleRegistry {
+	return &vehicleRegistry{vehicles: make(map[string]*parkedVehicle)}
+}
+
+// reserve 登记即将入场的车辆，同一车牌已在场时返回 ErrDuplicateVehicle
+func (r *vehicleRegistry) reserve(vehicle Vehicle) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.vehicles[vehicle.GetLicensePlate()]; ok {
+		return ErrDuplicateVehicle
+	}
+	r.vehicles[vehicle.GetLicensePlate()] = &parkedVehicle{vehicle: vehicle}
+	return nil
+}
+
+// place 记录车辆分配到的车位
+func (r *vehicleRegistry) place(vehicle Vehicle, level *Level, spot *ParkingSpot) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.vehicles[vehicle.GetLicensePlate()] = &parkedVehicle{vehicle: vehicle, level: level, spot: spot}
+}
+
+// remove 删除车辆的登记，只删除同一辆车的记录
+func (r *vehicleRegistry) remove(vehicle Vehicle) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if record, ok := r.vehicles[vehicle.GetLicensePlate()]; ok && record.vehicle == vehicle {
+		delete(r.vehicles, vehicle.GetLicensePlate())
+	}
+}
+
+// find 查找已停好的车辆
+func (r *vehicleRegistry) find(licensePlate string) (*parkedVehicle, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	record, ok := r.vehicles[licensePlate]
+	if !ok || record.spot == nil {
+		return nil, false
+	}
+	return record, true
+}
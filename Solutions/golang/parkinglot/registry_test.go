@@ -0,0 +1,75 @@
+package parkinglot
+
+import (
+	"errors"
+	"testing"
+)
+
+// 测试按车牌查找车辆、拒绝重复车牌以及车位编号跨楼层唯一
+func TestFindVehicleAndDuplicatePlate(t *testing.T) {
+	lot := NewParkingLot(2)
+	lot.AddLevel(NewLevel(1, 4))
+	lot.AddLevel(NewLevel(2, 4))
+	gate := NewEntryGate("E1", lot)
+
+	// 每层有两个汽车车位，第三辆车停到2层0号
+	var tickets []*Ticket
+	for _, plate := range []string{"ABC121", "ABC122", "ABC123"} {
+		ticket, err := gate.Enter(NewCar(plate))
+		if err != nil {
+			t.Fatalf("%s 入场失败: %v", plate, err)
+		}
+		tickets = append(tickets, ticket)
+	}
+
+	id, err := lot.FindVehicle("ABC123")
+	if err != nil {
+		t.Fatalf("查找车辆失败: %v", err)
+	}
+	if id != (SpotID{Floor: 2, Spot: 0}) || id.String() != "L2-000" {
+		t.Errorf("车位编号错误: %v", id)
+	}
+	if id != tickets[2].SpotID() {
+		t.Errorf("停车票车位编号不一致: %v", tickets[2].SpotID())
+	}
+
+	if _, err := gate.Enter(NewCar("ABC123")); !errors.Is(err, ErrDuplicateVehicle) {
+		t.Errorf("期望 ErrDuplicateVehicle，实际: %v", err)
+	}
+	if lot.ParkVehicle(NewCar("ABC123")) {
+		t.Error("重复车牌仍然可以停车")
+	}
+	if _, err := lot.FindVehicle("XYZ999"); !errors.Is(err, ErrVehicleNotFound) {
+		t.Errorf("期望 ErrVehicleNotFound，实际: %v", err)
+	}
+
+	// 按全局车位编号取车只影响对应楼层
+	if !lot.UnparkVehicleAt(SpotID{Floor: 2, Spot: 0}) {
+		t.Fatal("按车位编号取车失败")
+	}
+	if _, err := lot.FindVehicle("ABC123"); !errors.Is(err, ErrVehicleNotFound) {
+		t.Errorf("取车后仍能查到车辆: %v", err)
+	}
+	if _, err := lot.FindVehicle("ABC121"); err != nil {
+		t.Errorf("1层车辆不应受影响: %v", err)
+	}
+
+	// 按车位编号取车时旧票已关闭，新车停入后凭旧票不能出场，也不会释放新车的车位
+	if _, err := gate.Enter(NewCar("NEW001")); err != nil {
+		t.Fatalf("入场失败: %v", err)
+	}
+	if _, err := NewExitGate("X1", lot).Exit(tickets[2]); !errors.Is(err, ErrTicketAlreadyUsed) {
+		t.Errorf("期望 ErrTicketAlreadyUsed，实际: %v", err)
+	}
+	if id, err := lot.FindVehicle("NEW001"); err != nil || id != (SpotID{Floor: 2, Spot: 0}) {
+		t.Errorf("新车的车位被错误释放: %v %v", id, err)
+	}
+
+	// 出场后同一车牌可以再次入场
+	if _, err := NewExitGate("X1", lot).Exit(tickets[0]); err != nil {
+		t.Fatalf("出场失败: %v", err)
+	}
+	if _, err := gate.Enter(NewCar("ABC121")); err != nil {
+		t.Errorf("出场后再次入场失败: %v", err)
+	}
+}
@@ -0,0 +1,329 @@
+package parkinglot
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ReservationStatus 预约状态
+type ReservationStatus int
+
+const (
+	ReservationBooked    ReservationStatus = iota // 已预约，尚未到保留时间
+	ReservationHeld                               // 已为预约保留车位
+	ReservationCheckedIn                          // 车辆已凭预约入场
+	ReservationNoShow                             // 超过宽限期未到场，保留的车位已释放
+	ReservationCancelled                          // 已取消
+)
+
+func (s ReservationStatus) String() string {
+	switch s {
+	case ReservationBooked:
+		return "booked"
+	case ReservationHeld:
+		return "held"
+	case ReservationCheckedIn:
+		return "checked-in"
+	case ReservationNoShow:
+		return "no-show"
+	case ReservationCancelled:
+		return "cancelled"
+	}
+	return "unknown"
+}
+
+// ReservationPolicy 预约规则：提前多久开始保留车位，开始时间后多久未到场视为爽约
+type ReservationPolicy struct {
+	HoldBefore  time.Duration
+	NoShowGrace time.Duration
+}
+
+// DefaultReservationPolicy 提前15分钟保留车位，开始后15分钟未到场释放
+var DefaultReservationPolicy = ReservationPolicy{
+	HoldBefore:  15 * time.Minute,
+	NoShowGrace: 15 * time.Minute,
+}
+
+// Reservation 预约：在指定楼层为车辆预订一个某类型车位的时间段
+type Reservation struct {
+	ID           string
+	LicensePlate string
+	SpotType     SpotType
+	Floor        int
+	Start        time.Time
+	End          time.Time
+	Status       ReservationStatus
+	Spot         SpotID // 保留或停入的车位，状态为 held 或 checked-in 时有效
+	TicketID     string // 入场后签发的停车票
+
+	level *Level
+	spot  *ParkingSpot
+}
+
+// blocks 预约是否仍占用车位容量
+func (r *Reservation) blocks() bool {
+	return r.Status == ReservationBooked || r.Status == ReservationHeld || r.Status == ReservationCheckedIn
+}
+
+// reservationBook 停车场的预约簿
+type reservationBook struct {
+	reservations map[string]*Reservation
+	seq          int
+	policy       ReservationPolicy
+	mu           sync.Mutex
+}
+
+func newReservationBook() *reservationBook {
+	return &reservationBook{
+		reservations: make(map[string]*Reservation),
+		policy:       DefaultReservationPolicy,
+	}
+}
+
+// SetReservationPolicy 设置预约的保留和爽约规则
+func (p *ParkingLot) SetReservationPolicy(policy ReservationPolicy) {
+	p.reservations.mu.Lock()
+	defer p.reservations.mu.Unlock()
+	p.reservations.policy = policy
+}
+
+// Reserve 为车辆预订 [start, end) 时间段内的一个指定类型车位
+// 每层同一类型的预约在任意时刻（含提前保留的时间）都不超过该类型的车位数，
+// 已到保留时间的预约还需要楼层当前有空闲车位，预订时立即保留；
+// 按楼层顺序选择第一个有余量的楼层，所有楼层都没有余量时返回 ErrNoReservationCapacity
+func (p *ParkingLot) Reserve(licensePlate string, spotType SpotType, start, end time.Time) (*Reservation, error) {
+	now := p.now()
+	if !end.After(start) || start.Before(now) {
+		return nil, ErrInvalidReservation
+	}
+	book := p.reservations
+	book.mu.Lock()
+	defer book.mu.Unlock()
+
+	for _, r := range book.reservations {
+		if r.blocks() && r.LicensePlate == licensePlate && start.Before(r.End) && r.Start.Before(end) {
+			return nil, ErrInvalidReservation
+		}
+	}
+
+	for _, level := range p.getLevels() {
+		capacity := level.totalOfType(spotType)
+		if capacity == 0 || book.maxOverlap(level.GetFloor(), spotType, start, end) >= capacity {
+			continue
+		}
+		r := &Reservation{
+			ID:           fmt.Sprintf("R-%06d", book.seq+1),
+			LicensePlate: licensePlate,
+			SpotType:     spotType,
+			Floor:        level.GetFloor(),
+			Start:        start,
+			End:          end,
+			Status:       ReservationBooked,
+			level:        level,
+		}
+		// 车位被临时停车占满时，即将开始的预约无法保证有车位
+		if !now.Before(start.Add(-book.policy.HoldBefore)) && !book.hold(r) {
+			continue
+		}
+		book.seq++
+		book.reservations[r.ID] = r
+		copied := *r
+		return &copied, nil
+	}
+	return nil, ErrNoReservationCapacity
+}
+
+// maxOverlap 返回新预约时间段内同层同类型预约的最大并发数，调用方需持有锁
+// 每个预约占用的时间从提前保留的时刻开始
+func (b *reservationBook) maxOverlap(floor int, spotType SpotType, start, end time.Time) int {
+	type point struct {
+		at    time.Time
+		delta int
+	}
+	var points []point
+	from := start.Add(-b.policy.HoldBefore)
+	for _, r := range b.reservations {
+		if !r.blocks() || r.Floor != floor || r.SpotType != spotType {
+			continue
+		}
+		rFrom := r.Start.Add(-b.policy.HoldBefore)
+		if !rFrom.Before(end) || !from.Before(r.End) {
+			continue
+		}
+		if rFrom.Before(from) {
+			rFrom = from
+		}
+		rTo := r.End
+		if rTo.After(end) {
+			rTo = end
+		}
+		points = append(points, point{rFrom, 1}, point{rTo, -1})
+	}
+	// 同一时刻先结束后开始，首尾相接的预约不算重叠
+	sort.Slice(points, func(i, j int) bool {
+		if points[i].at.Equal(points[j].at) {
+			return points[i].delta < points[j].delta
+		}
+		return points[i].at.Before(points[j].at)
+	})
+	current, max := 0, 0
+	for _, pt := range points {
+		current += pt.delta
+		if current > max {
+			max = current
+		}
+	}
+	return max
+}
+
+// GetReservation 查询预约，不推进预约状态；状态由 ProcessReservations 更新
+func (p *ParkingLot) GetReservation(reservationID string) (*Reservation, error) {
+	book := p.reservations
+	book.mu.Lock()
+	defer book.mu.Unlock()
+	r, ok := book.reservations[reservationID]
+	if !ok {
+		return nil, ErrReservationNotFound
+	}
+	copied := *r
+	return &copied, nil
+}
+
+// CancelReservation 取消尚未入场的预约并释放保留的车位
+func (p *ParkingLot) CancelReservation(reservationID string) error {
+	book := p.reservations
+	book.mu.Lock()
+	defer book.mu.Unlock()
+	r, ok := book.reservations[reservationID]
+	if !ok {
+		return ErrReservationNotFound
+	}
+	if r.Status != ReservationBooked && r.Status != ReservationHeld {
+		return ErrReservationNotActive
+	}
+	book.release(r, ReservationCancelled)
+	return nil
+}
+
+// release 结束预约并释放保留的车位，调用方需持有锁
+func (b *reservationBook) release(r *Reservation, status ReservationStatus) {
+	if r.spot != nil {
+		r.level.releaseHold(r.spot, r.ID)
+		r.spot = nil
+		r.Spot = SpotID{}
+	}
+	r.Status = status
+}
+
+// ProcessReservations 推进预约状态：到达保留时间的预约保留车位，超过宽限期未到场的预约释放车位
+// 每次入场时会自动调用，也可以由定时任务调用
+func (p *ParkingLot) ProcessReservations() {
+	now := p.now()
+	book := p.reservations
+	book.mu.Lock()
+	defer book.mu.Unlock()
+
+	// 按开始时间顺序保留车位，先开始的预约优先
+	pending := make([]*Reservation, 0)
+	for _, r := range book.reservations {
+		if r.Status == ReservationBooked || r.Status == ReservationHeld {
+			pending = append(pending, r)
+		}
+	}
+	sort.Slice(pending, func(i, j int) bool {
+		return pending[i].Start.Before(pending[j].Start)
+	})
+	for _, r := range pending {
+		if now.After(r.Start.Add(book.policy.NoShowGrace)) {
+			book.release(r, ReservationNoShow)
+			continue
+		}
+		if r.Status == ReservationBooked && !now.Before(r.Start.Add(-book.policy.HoldBefore)) {
+			book.hold(r)
+		}
+	}
+}
+
+// hold 为预约保留车位，车位被临时停车占满时保持 booked 状态，下次处理时重试
+func (b *reservationBook) hold(r *Reservation) bool {
+	spot := r.level.holdSpot(r.SpotType, r.ID)
+	if spot == nil {
+		return false
+	}
+	r.spot = spot
+	r.Spot = r.level.GetSpotID(spot)
+	r.Status = ReservationHeld
+	return true
+}
+
+// checkIn 预约车辆入场，停入为其保留的车位并签发停车票
+func (p *ParkingLot) checkIn(vehicle Vehicle, reservationID, gateID string) (*Ticket, error) {
+	j := p.beginChange()
+	p.ProcessReservations()
+	ticket, err := p.claimReservation(vehicle, reservationID, gateID)
+	if err != nil {
+		j.end()
+		return nil, err
+	}
+	if err := p.journalPark(j, vehicle, ticket.level, ticket.spot, ticket); err != nil {
+		// 车位已释放，预约恢复为 booked，下次处理时重新保留车位
+		p.reservations.mu.Lock()
+		r := p.reservations.reservations[reservationID]
+		r.Status = ReservationBooked
+		r.TicketID = ""
+		r.spot = nil
+		r.Spot = SpotID{}
+		p.reservations.mu.Unlock()
+		j.end()
+		return nil, err
+	}
+	issued := p.ticketCopy(ticket)
+	batch := p.events.reserve()
+	j.end()
+	// 在预约簿锁外发布事件，订阅者可以查询预约
+	p.publishParked(batch, vehicle, ticket.level, ticket.spot, ticket)
+	return issued, nil
+}
+
+func (p *ParkingLot) claimReservation(vehicle Vehicle, reservationID, gateID string) (*Ticket, error) {
+	now := p.now()
+	book := p.reservations
+	book.mu.Lock()
+	defer book.mu.Unlock()
+
+	r, ok := book.reservations[reservationID]
+	if !ok {
+		return nil, ErrReservationNotFound
+	}
+	if r.Status != ReservationBooked && r.Status != ReservationHeld {
+		return nil, ErrReservationNotActive
+	}
+	if r.LicensePlate != vehicle.GetLicensePlate() {
+		return nil, ErrReservationMismatch
+	}
+	if !canFit(r.SpotType, vehicle) || spotsRequired(vehicle) > 1 {
+		return nil, ErrSpotNotCompatible
+	}
+	if now.Before(r.Start.Add(-book.policy.HoldBefore)) {
+		return nil, ErrReservationNotStarted
+	}
+	if r.Status == ReservationBooked && !book.hold(r) {
+		return nil, ErrNoAvailableSpot
+	}
+
+	if err := p.vehicles.reserve(vehicle); err != nil {
+		return nil, err
+	}
+	if !r.level.claimHeldSpot(r.spot, vehicle, r.ID) {
+		p.vehicles.remove(vehicle)
+		return nil, ErrNoAvailableSpot
+	}
+	p.vehicles.place(vehicle, r.level, r.spot)
+	ticket := p.newTicket(vehicle, gateID, r.level, r.spot)
+	ticket.ReservationID = r.ID
+	r.Status = ReservationCheckedIn
+	r.TicketID = ticket.ID
+	return ticket, nil
+}
@@ -0,0 +1,193 @@
+package parkinglot
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// newReservationTestLot 创建每层只有指定数量标准车位的停车场
+func newReservationTestLot(t *testing.T, clock *time.Time, spotsPerLevel ...int) *ParkingLot {
+	lot := NewParkingLot(len(spotsPerLevel))
+	for i, n := range spotsPerLevel {
+		level, err := NewLevelFromLayout(LevelLayout{Floor: i + 1, Spots: []SpotGroup{{Type: "regular", Count: n}}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		lot.AddLevel(level)
+	}
+	lot.now = func() time.Time { return *clock }
+	return lot
+}
+
+// 测试重叠预约不超过车位数，提前保留的时间也计入占用
+func TestReservationScheduling(t *testing.T) {
+	clock := time.Date(2024, 3, 4, 8, 0, 0, 0, time.UTC)
+	lot := newReservationTestLot(t, &clock, 2)
+	at := func(hour, minute int) time.Time {
+		return time.Date(2024, 3, 4, hour, minute, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name       string
+		plate      string
+		start, end time.Time
+		wantErr    error
+	}{
+		{"第一个预约", "A", at(10, 0), at(12, 0), nil},
+		{"部分重叠", "B", at(11, 0), at(13, 0), nil},
+		{"与两个预约同时重叠", "C", at(11, 30), at(12, 30), ErrNoReservationCapacity},
+		// 12:00开始的预约从11:45开始保留车位，与A、B同时重叠
+		{"保留时间重叠", "D", at(12, 0), at(14, 0), ErrNoReservationCapacity},
+		{"首尾相接", "E", at(12, 15), at(14, 0), nil},
+		{"同一车牌时间重叠", "A", at(11, 0), at(11, 30), ErrInvalidReservation},
+		{"结束早于开始", "F", at(15, 0), at(14, 0), ErrInvalidReservation},
+		{"开始时间已过", "G", at(7, 0), at(9, 0), ErrInvalidReservation},
+	}
+	for _, tt := range tests {
+		_, err := lot.Reserve(tt.plate, SpotRegular, tt.start, tt.end)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: 期望 %v，实际 %v", tt.name, tt.wantErr, err)
+		}
+	}
+	if _, err := lot.Reserve("H", SpotTruck, at(10, 0), at(11, 0)); !errors.Is(err, ErrNoReservationCapacity) {
+		t.Errorf("没有该类型车位时期望 ErrNoReservationCapacity，实际 %v", err)
+	}
+
+	// 第一层满了之后预约到第二层
+	lot = newReservationTestLot(t, &clock, 1, 1)
+	first, _ := lot.Reserve("A", SpotRegular, at(10, 0), at(12, 0))
+	second, err := lot.Reserve("B", SpotRegular, at(10, 0), at(12, 0))
+	if err != nil || first.Floor != 1 || second.Floor != 2 {
+		t.Errorf("预约楼层错误: %v %v %v", first, second, err)
+	}
+}
+
+// 测试提前保留车位、凭预约入场以及爽约释放
+func TestReservationHoldAndCheckIn(t *testing.T) {
+	clock := time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)
+	lot := newReservationTestLot(t, &clock, 2)
+	gate := NewEntryGate("E1", lot)
+	start := time.Date(2024, 3, 4, 10, 0, 0, 0, time.UTC)
+
+	booked, err := lot.Reserve("RES1", SpotRegular, start, start.Add(2*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	noShow, err := lot.Reserve("RES2", SpotRegular, start, start.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gate.EnterWithReservation(NewCar("RES1"), booked.ID); !errors.Is(err, ErrReservationNotStarted) {
+		t.Errorf("期望 ErrReservationNotStarted，实际 %v", err)
+	}
+
+	// 9:45开始保留车位，临时停车无法再进入
+	clock = start.Add(-15 * time.Minute)
+	lot.ProcessReservations()
+	if got := lot.GetAvailability().Available; got != 0 {
+		t.Errorf("保留后可用车位错误，期望：0，实际：%d", got)
+	}
+	if _, err := gate.Enter(NewCar("WALKIN")); !errors.Is(err, ErrNoAvailableSpot) {
+		t.Errorf("期望 ErrNoAvailableSpot，实际 %v", err)
+	}
+	held, _ := lot.GetReservation(booked.ID)
+	if held.Status != ReservationHeld {
+		t.Fatalf("预约状态错误: %v", held.Status)
+	}
+
+	if _, err := gate.EnterWithReservation(NewCar("OTHER"), booked.ID); !errors.Is(err, ErrReservationMismatch) {
+		t.Errorf("期望 ErrReservationMismatch，实际 %v", err)
+	}
+	ticket, err := gate.EnterWithReservation(NewCar("RES1"), booked.ID)
+	if err != nil {
+		t.Fatalf("凭预约入场失败: %v", err)
+	}
+	if ticket.ReservationID != booked.ID || ticket.SpotID() != held.Spot {
+		t.Errorf("停车票信息错误: %+v", ticket)
+	}
+	if _, err := gate.EnterWithReservation(NewCar("RES1"), booked.ID); !errors.Is(err, ErrReservationNotActive) {
+		t.Errorf("重复入场期望 ErrReservationNotActive，实际 %v", err)
+	}
+
+	// 10:16仍未到场，保留的车位释放给临时停车
+	clock = start.Add(16 * time.Minute)
+	if _, err := gate.Enter(NewCar("WALKIN")); err != nil {
+		t.Errorf("爽约释放后临时停车失败: %v", err)
+	}
+	released, _ := lot.GetReservation(noShow.ID)
+	if released.Status != ReservationNoShow {
+		t.Errorf("预约状态错误，期望 no-show，实际 %v", released.Status)
+	}
+	if _, err := gate.EnterWithReservation(NewCar("RES2"), noShow.ID); !errors.Is(err, ErrReservationNotActive) {
+		t.Errorf("爽约后入场期望 ErrReservationNotActive，实际 %v", err)
+	}
+}
+
+// 测试取消预约释放保留的车位
+func TestCancelReservation(t *testing.T) {
+	clock := time.Date(2024, 3, 4, 9, 50, 0, 0, time.UTC)
+	lot := newReservationTestLot(t, &clock, 1)
+	start := time.Date(2024, 3, 4, 10, 0, 0, 0, time.UTC)
+
+	r, err := lot.Reserve("A", SpotRegular, start, start.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lot.ProcessReservations()
+	if lot.GetAvailability().Available != 0 {
+		t.Fatal("车位未被保留")
+	}
+	if err := lot.CancelReservation(r.ID); err != nil {
+		t.Fatal(err)
+	}
+	if lot.GetAvailability().Available != 1 {
+		t.Error("取消后车位未释放")
+	}
+	if err := lot.CancelReservation(r.ID); !errors.Is(err, ErrReservationNotActive) {
+		t.Errorf("期望 ErrReservationNotActive，实际 %v", err)
+	}
+	if err := lot.CancelReservation("R-404"); !errors.Is(err, ErrReservationNotFound) {
+		t.Errorf("期望 ErrReservationNotFound，实际 %v", err)
+	}
+	// 取消后该时间段可以重新预约
+	if _, err := lot.Reserve("B", SpotRegular, start, start.Add(time.Hour)); err != nil {
+		t.Errorf("取消后重新预约失败: %v", err)
+	}
+}
+
+// 测试即将开始的预约需要当前有空闲车位，查询预约不推进状态
+func TestReserveChecksFreeSpots(t *testing.T) {
+	clock := time.Date(2024, 3, 4, 9, 50, 0, 0, time.UTC)
+	lot := newReservationTestLot(t, &clock, 1, 1)
+	gate := NewEntryGate("E1", lot)
+	start := time.Date(2024, 3, 4, 10, 0, 0, 0, time.UTC)
+
+	if _, err := gate.Enter(NewCar("WALKIN1")); err != nil {
+		t.Fatal(err)
+	}
+	r, err := lot.Reserve("A", SpotRegular, start, start.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Floor != 2 || r.Status != ReservationHeld {
+		t.Errorf("第一层已停满，应在第二层立即保留车位: %+v", r)
+	}
+	if _, err := lot.Reserve("B", SpotRegular, start, start.Add(time.Hour)); !errors.Is(err, ErrNoReservationCapacity) {
+		t.Errorf("没有空闲车位时期望 ErrNoReservationCapacity，实际 %v", err)
+	}
+	// 尚未到保留时间的预约只按预约容量检查
+	later, err := lot.Reserve("B", SpotRegular, start.Add(2*time.Hour), start.Add(3*time.Hour))
+	if err != nil || later.Status != ReservationBooked {
+		t.Errorf("之后的预约应成功: %+v %v", later, err)
+	}
+
+	clock = start.Add(time.Hour)
+	if got, _ := lot.GetReservation(r.ID); got.Status != ReservationHeld {
+		t.Errorf("查询不应推进预约状态，实际 %v", got.Status)
+	}
+	lot.ProcessReservations()
+	if got, _ := lot.GetReservation(r.ID); got.Status != ReservationNoShow {
+		t.Errorf("处理后应为 no-show，实际 %v", got.Status)
+	}
+}
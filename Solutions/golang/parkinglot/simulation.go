@@ -0,0 +1,285 @@
+package parkinglot
+
+import (
+	"container/heap"
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// DwellDistribution 停车时长分布
+type DwellDistribution interface {
+	Sample(rng *rand.Rand) time.Duration
+}
+
+// FixedDwell 固定停车时长
+type FixedDwell time.Duration
+
+func (d FixedDwell) Sample(rng *rand.Rand) time.Duration {
+	return time.Duration(d)
+}
+
+// UniformDwell 在 [Min, Max) 内均匀分布的停车时长
+type UniformDwell struct {
+	Min time.Duration
+	Max time.Duration
+}
+
+func (d UniformDwell) Sample(rng *rand.Rand) time.Duration {
+	if d.Max <= d.Min {
+		return d.Min
+	}
+	return d.Min + time.Duration(rng.Int63n(int64(d.Max-d.Min)))
+}
+
+// ExponentialDwell 均值为 Mean 的指数分布停车时长
+type ExponentialDwell struct {
+	Mean time.Duration
+}
+
+func (d ExponentialDwell) Sample(rng *rand.Rand) time.Duration {
+	return time.Duration(rng.ExpFloat64() * float64(d.Mean))
+}
+
+// VehicleClass 到达车辆中的一类，Weight 为该类车辆在到达车辆中的相对比例
+type VehicleClass struct {
+	Name       string
+	Weight     float64
+	NewVehicle func(licensePlate string) Vehicle
+	Dwell      DwellDistribution
+}
+
+// SimulationConfig 仿真参数，车辆按泊松过程到达
+type SimulationConfig struct {
+	Start       time.Time
+	Duration    time.Duration
+	ArrivalRate float64 // 平均每小时到达的车辆数
+	Mix         []VehicleClass
+	Seed        int64
+}
+
+// ClassResult 某类车辆的仿真结果
+type ClassResult struct {
+	Arrivals      int
+	Rejected      int
+	RejectionRate float64
+}
+
+// SimulationResult 仿真结果
+type SimulationResult struct {
+	Arrivals      int
+	Rejected      int
+	RejectionRate float64
+	Departures    int
+	Revenue       float64
+	Utilisation   float64            // 按时间加权的车位占用率
+	ByType        map[string]float64 // 各类型车位按时间加权的占用率
+	PeakOccupied  int                // 同时占用的最多车位数
+	ByClass       map[string]*ClassResult
+}
+
+type simEventKind int
+
+const (
+	simArrival simEventKind = iota
+	simDeparture
+)
+
+// simEvent 仿真事件，同一时刻的事件按加入顺序处理
+type simEvent struct {
+	at     time.Time
+	seq    int
+	kind   simEventKind
+	ticket *Ticket
+}
+
+type simQueue []*simEvent
+
+func (q simQueue) Len() int { return len(q) }
+func (q simQueue) Less(i, j int) bool {
+	if !q[i].at.Equal(q[j].at) {
+		return q[i].at.Before(q[j].at)
+	}
+	return q[i].seq < q[j].seq
+}
+func (q simQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+func (q *simQueue) Push(x any)   { *q = append(*q, x.(*simEvent)) }
+func (q *simQueue) Pop() any {
+	old := *q
+	event := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return event
+}
+
+// Simulator 离散事件仿真器，用模拟时钟驱动停车场的入场和出场
+// 停车场的布局、分配策略和计费策略由调用方设置，用相同的种子在不同的停车场上运行即可比较方案
+type Simulator struct {
+	lot    *ParkingLot
+	config SimulationConfig
+	rng    *rand.Rand
+	clock  time.Time
+	queue  simQueue
+	seq    int
+	entry  *EntryGate
+	exit   *ExitGate
+}
+
+// NewSimulator 创建仿真器，Run 期间停车场使用模拟时钟，停车场应为仿真专用
+func NewSimulator(lot *ParkingLot, config SimulationConfig) (*Simulator, error) {
+	if config.ArrivalRate <= 0 || config.Duration <= 0 || len(config.Mix) == 0 {
+		return nil, ErrInvalidSimulation
+	}
+	for _, class := range config.Mix {
+		if class.Weight <= 0 || class.NewVehicle == nil || class.Dwell == nil {
+			return nil, fmt.Errorf("%w: vehicle class %q", ErrInvalidSimulation, class.Name)
+		}
+	}
+	s := &Simulator{
+		lot:    lot,
+		config: config,
+		rng:    rand.New(rand.NewSource(config.Seed)),
+		clock:  config.Start,
+		entry:  NewEntryGate("SIM-IN", lot),
+		exit:   NewExitGate("SIM-OUT", lot),
+	}
+	return s, nil
+}
+
+func (s *Simulator) schedule(at time.Time, kind simEventKind, ticket *Ticket) {
+	s.seq++
+	heap.Push(&s.queue, &simEvent{at: at, seq: s.seq, kind: kind, ticket: ticket})
+}
+
+// nextArrival 按泊松过程生成下一次到达的时间间隔
+func (s *Simulator) nextArrival() time.Duration {
+	return time.Duration(s.rng.ExpFloat64() / s.config.ArrivalRate * float64(time.Hour))
+}
+
+// pickClass 按权重随机选择到达车辆的类别
+func (s *Simulator) pickClass() VehicleClass {
+	total := 0.0
+	for _, class := range s.config.Mix {
+		total += class.Weight
+	}
+	r := s.rng.Float64() * total
+	for _, class := range s.config.Mix {
+		if r < class.Weight {
+			return class
+		}
+		r -= class.Weight
+	}
+	return s.config.Mix[len(s.config.Mix)-1]
+}
+
+// Run 运行仿真直到结束时间，结束时仍在场的车辆不出场
+// 运行期间停车场使用模拟时钟，结束后恢复原来的时钟
+func (s *Simulator) Run() *SimulationResult {
+	previous := s.lot.now
+	s.lot.now = func() time.Time { return s.clock }
+	defer func() { s.lot.now = previous }()
+
+	result := &SimulationResult{
+		ByType:  make(map[string]float64),
+		ByClass: make(map[string]*ClassResult),
+	}
+	for _, class := range s.config.Mix {
+		result.ByClass[class.Name] = &ClassResult{}
+	}
+	end := s.config.Start.Add(s.config.Duration)
+	tracker := newUtilisationTracker(s.lot.GetAvailability(), s.clock)
+
+	s.schedule(s.clock.Add(s.nextArrival()), simArrival, nil)
+	for s.queue.Len() > 0 {
+		event := heap.Pop(&s.queue).(*simEvent)
+		if !event.at.Before(end) {
+			break
+		}
+		s.clock = event.at
+
+		switch event.kind {
+		case simArrival:
+			class := s.pickClass()
+			stats := result.ByClass[class.Name]
+			result.Arrivals++
+			stats.Arrivals++
+			// 被拒绝的车辆也抽取停车时长，使相同种子在不同停车场上产生相同的到达序列
+			dwell := class.Dwell.Sample(s.rng)
+			vehicle := class.NewVehicle(fmt.Sprintf("SIM-%06d", result.Arrivals))
+			ticket, err := s.entry.Enter(vehicle)
+			if err != nil {
+				result.Rejected++
+				stats.Rejected++
+			} else {
+				s.schedule(s.clock.Add(dwell), simDeparture, ticket)
+			}
+			s.schedule(s.clock.Add(s.nextArrival()), simArrival, nil)
+		case simDeparture:
+			if fee, err := s.exit.Exit(event.ticket); err == nil {
+				result.Departures++
+				result.Revenue += fee
+			}
+		}
+		tracker.observe(s.lot.GetAvailability(), s.clock)
+	}
+	tracker.observe(s.lot.GetAvailability(), end)
+
+	if result.Arrivals > 0 {
+		result.RejectionRate = float64(result.Rejected) / float64(result.Arrivals)
+	}
+	for _, stats := range result.ByClass {
+		if stats.Arrivals > 0 {
+			stats.RejectionRate = float64(stats.Rejected) / float64(stats.Arrivals)
+		}
+	}
+	result.Utilisation, result.ByType = tracker.utilisation(s.config.Duration)
+	result.PeakOccupied = tracker.peak
+	return result
+}
+
+// utilisationTracker 对占用车位数按时间积分
+type utilisationTracker struct {
+	last         time.Time
+	current      Availability
+	occupiedTime float64 // 占用车位数乘以时长（小时）
+	typeTime     map[string]float64
+	peak         int
+}
+
+func newUtilisationTracker(initial Availability, at time.Time) *utilisationTracker {
+	return &utilisationTracker{last: at, current: initial, typeTime: make(map[string]float64), peak: initial.Occupied}
+}
+
+// observe 累计上一状态持续的时间，并记录新的状态
+func (t *utilisationTracker) observe(a Availability, at time.Time) {
+	elapsed := at.Sub(t.last).Hours()
+	t.occupiedTime += float64(t.current.Occupied) * elapsed
+	for _, level := range t.current.Levels {
+		for name, counts := range level.ByType {
+			t.typeTime[name] += float64(counts.Total-counts.Available) * elapsed
+		}
+	}
+	t.last = at
+	t.current = a
+	if a.Occupied > t.peak {
+		t.peak = a.Occupied
+	}
+}
+
+func (t *utilisationTracker) utilisation(duration time.Duration) (float64, map[string]float64) {
+	byType := make(map[string]float64)
+	totals := make(map[string]int)
+	for _, level := range t.current.Levels {
+		for name, counts := range level.ByType {
+			totals[name] += counts.Total
+		}
+	}
+	for name, total := range totals {
+		if total > 0 {
+			byType[name] = t.typeTime[name] / duration.Hours() / float64(total)
+		}
+	}
+	if t.current.Total == 0 {
+		return 0, byType
+	}
+	return t.occupiedTime / duration.Hours() / float64(t.current.Total), byType
+}
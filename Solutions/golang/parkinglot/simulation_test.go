@@ -0,0 +1,103 @@
+package parkinglot
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// newSimulationLot 创建2个标准车位和4个卡车车位的仿真用停车场
+func newSimulationLot(t *testing.T, strategy SpotAssignmentStrategy) *ParkingLot {
+	t.Helper()
+	lot := NewParkingLot(1)
+	err := lot.ApplyLayout(&LotLayout{Levels: []LevelLayout{{Floor: 1, Spots: []SpotGroup{
+		{Type: "regular", Count: 2},
+		{Type: "truck", Count: 4},
+	}}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lot.SetSpotAssignmentStrategy(strategy)
+	lot.SetPricingStrategy(NewHourlyPricing(5))
+	return lot
+}
+
+func carSimulationConfig() SimulationConfig {
+	return SimulationConfig{
+		Start:       time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC),
+		Duration:    100 * time.Hour,
+		ArrivalRate: 2,
+		Seed:        42,
+		Mix: []VehicleClass{
+			{Name: "car", Weight: 1, NewVehicle: func(plate string) Vehicle { return NewCar(plate) }, Dwell: ExponentialDwell{Mean: 2 * time.Hour}},
+		},
+	}
+}
+
+// 测试用相同的种子比较不同的分配策略：允许汽车停入卡车车位时拒绝率更低、占用率更高
+func TestSimulationComparesStrategies(t *testing.T) {
+	run := func(strategy SpotAssignmentStrategy) *SimulationResult {
+		sim, err := NewSimulator(newSimulationLot(t, strategy), carSimulationConfig())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return sim.Run()
+	}
+
+	exact := run(NewExactTypeStrategy())
+	bestFit := run(NewBestFitStrategy())
+	if exact.Arrivals != bestFit.Arrivals {
+		t.Errorf("相同种子的到达数应相同: %d vs %d", exact.Arrivals, bestFit.Arrivals)
+	}
+	// 平均每小时2辆，100小时约200辆
+	if exact.Arrivals < 150 || exact.Arrivals > 250 {
+		t.Errorf("到达数偏离泊松过程的期望: %d", exact.Arrivals)
+	}
+	if exact.Rejected+exact.Departures > exact.Arrivals || exact.Rejected == 0 {
+		t.Errorf("仿真计数错误: %+v", exact)
+	}
+	if bestFit.RejectionRate >= exact.RejectionRate {
+		t.Errorf("最佳适配策略的拒绝率应更低: %.3f vs %.3f", bestFit.RejectionRate, exact.RejectionRate)
+	}
+	if exact.ByType["truck"] != 0 || bestFit.ByType["truck"] == 0 {
+		t.Errorf("卡车车位占用率错误: %v vs %v", exact.ByType, bestFit.ByType)
+	}
+	if exact.Utilisation <= 0 || exact.Utilisation > 2.0/6 || bestFit.Utilisation <= exact.Utilisation {
+		t.Errorf("占用率错误: %.3f vs %.3f", exact.Utilisation, bestFit.Utilisation)
+	}
+	if exact.PeakOccupied != 2 || exact.ByClass["car"].Rejected != exact.Rejected {
+		t.Errorf("峰值或分类统计错误: %+v", exact)
+	}
+	if again := run(NewExactTypeStrategy()); !reflect.DeepEqual(again, exact) {
+		t.Error("相同种子的仿真结果应可重现")
+	}
+}
+
+// 测试固定停车时长下的占用率，仿真结束后恢复停车场的时钟
+func TestSimulationFixedDwell(t *testing.T) {
+	config := carSimulationConfig()
+	config.Mix[0].Dwell = FixedDwell(0)
+	lot := newSimulationLot(t, NewExactTypeStrategy())
+	clock := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	lot.now = func() time.Time { return clock }
+	sim, err := NewSimulator(lot, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !lot.now().Equal(clock) {
+		t.Error("创建仿真器不应替换停车场的时钟")
+	}
+	result := sim.Run()
+	if result.Rejected != 0 || result.Utilisation != 0 || result.Departures != result.Arrivals {
+		t.Errorf("停车时长为0时不应有拒绝和占用: %+v", result)
+	}
+	if !lot.now().Equal(clock) {
+		t.Errorf("仿真结束后应恢复原来的时钟，实际: %v", lot.now())
+	}
+
+	config.Mix = append(config.Mix, VehicleClass{Name: "bad", Weight: 1})
+	if _, err := NewSimulator(NewParkingLot(1), config); !errors.Is(err, ErrInvalidSimulation) {
+		t.Errorf("期望 ErrInvalidSimulation，实际: %v", err)
+	}
+}
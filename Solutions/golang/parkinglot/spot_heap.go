@@ -0,0 +1,111 @@
+package parkinglot
+
+import "container/heap"
+
+// spotHeap 按车位号排序的空闲车位最小堆，分配时取车位号最小的空闲车位
+// index 记录车位在堆中的位置，移除任意车位为 O(log n)，已在堆中的车位不会重复加入
+type spotHeap struct {
+	spots []*ParkingSpot
+	index map[*ParkingSpot]int
+}
+
+func newSpotHeap() *spotHeap {
+	return &spotHeap{index: make(map[*ParkingSpot]int)}
+}
+
+func (h *spotHeap) Len() int { return len(h.spots) }
+func (h *spotHeap) Less(i, j int) bool {
+	return h.spots[i].GetSpotNumber() < h.spots[j].GetSpotNumber()
+}
+
+func (h *spotHeap) Swap(i, j int) {
+	h.spots[i], h.spots[j] = h.spots[j], h.spots[i]
+	h.index[h.spots[i]] = i
+	h.index[h.spots[j]] = j
+}
+
+func (h *spotHeap) Push(x any) {
+	spot := x.(*ParkingSpot)
+	h.index[spot] = len(h.spots)
+	h.spots = append(h.spots, spot)
+}
+
+func (h *spotHeap) Pop() any {
+	n := len(h.spots)
+	spot := h.spots[n-1]
+	h.spots[n-1] = nil
+	h.spots = h.spots[:n-1]
+	delete(h.index, spot)
+	return spot
+}
+
+// freeSpots 按车位类型分组的空闲车位索引
+type freeSpots map[SpotType]*spotHeap
+
+func newFreeSpots(spots []*ParkingSpot) freeSpots {
+	free := make(freeSpots)
+	for _, spot := range spots {
+		if spot.IsAvailable() {
+			free.push(spot)
+		}
+	}
+	return free
+}
+
+// push 将空闲车位加入索引，已在索引中的车位不重复加入
+func (f freeSpots) push(spot *ParkingSpot) {
+	h, ok := f[spot.GetSpotType()]
+	if !ok {
+		h = newSpotHeap()
+		f[spot.GetSpotType()] = h
+	}
+	if _, ok := h.index[spot]; ok {
+		return
+	}
+	heap.Push(h, spot)
+}
+
+// peek 返回指定类型中车位号最小的空闲车位但不取出
+func (f freeSpots) peek(spotType SpotType) *ParkingSpot {
+	h, ok := f[spotType]
+	if !ok {
+		return nil
+	}
+	for h.Len() > 0 {
+		if spot := h.spots[0]; spot.IsAvailable() {
+			return spot
+		}
+		heap.Pop(h)
+	}
+	return nil
+}
+
+// pop 取出指定类型中车位号最小的空闲车位
+// 绕过Level直接停入的车位已不再空闲，取出时丢弃
+func (f freeSpots) pop(spotType SpotType) *ParkingSpot {
+	h, ok := f[spotType]
+	if !ok {
+		return nil
+	}
+	for h.Len() > 0 {
+		spot := heap.Pop(h).(*ParkingSpot)
+		if spot.IsAvailable() {
+			return spot
+		}
+	}
+	return nil
+}
+
+// remove 从索引中移除指定车位
+func (f freeSpots) remove(spot *ParkingSpot) bool {
+	h, ok := f[spot.GetSpotType()]
+	if !ok {
+		return false
+	}
+	i, ok := h.index[spot]
+	if !ok {
+		return false
+	}
+	heap.Remove(h, i)
+	return true
+}
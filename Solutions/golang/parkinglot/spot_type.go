@@ -0,0 +1,104 @@
+package parkinglot
+
+import "fmt"
+
+// SpotType 车位类型
+type SpotType int
+
+const (
+	SpotMotorcycle  SpotType = iota // 摩托车车位
+	SpotCompact                     // 小型车位
+	SpotRegular                     // 标准汽车车位
+	SpotLarge                       // 大型车位，可停SUV等大型汽车
+	SpotTruck                       // 卡车车位
+	SpotHandicapped                 // 无障碍车位，需持有残疾人停车许可
+	SpotEVCharging                  // 充电车位，仅限电动车
+)
+
+var spotTypeNames = map[SpotType]string{
+	SpotMotorcycle:  "motorcycle",
+	SpotCompact:     "compact",
+	SpotRegular:     "regular",
+	SpotLarge:       "large",
+	SpotTruck:       "truck",
+	SpotHandicapped: "handicapped",
+	SpotEVCharging:  "ev-charging",
+}
+
+func (t SpotType) String() string {
+	if name, ok := spotTypeNames[t]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// ParseSpotType 根据名称解析车位类型，兼容 car 作为 regular 的别名
+func ParseSpotType(name string) (SpotType, error) {
+	if name == "car" {
+		return SpotRegular, nil
+	}
+	for spotType, n := range spotTypeNames {
+		if n == name {
+			return spotType, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown spot type %q", name)
+}
+
+// SpotTypeFor 返回与车辆类型对应的标准车位类型
+func SpotTypeFor(vehicleType VehicleType) SpotType {
+	switch vehicleType {
+	case MOTORCYCLE:
+		return SpotMotorcycle
+	case TRUCK, BUS:
+		return SpotTruck
+	}
+	return SpotRegular
+}
+
+// vehicleTypeFor 返回车位主要面向的车辆类型
+func vehicleTypeFor(spotType SpotType) VehicleType {
+	switch spotType {
+	case SpotMotorcycle:
+		return MOTORCYCLE
+	case SpotTruck:
+		return TRUCK
+	}
+	return CAR
+}
+
+// canFit 判断车位类型能否容纳车辆
+// 规则：
+// 1. 摩托车可以停在任何普通车位
+// 2. 汽车可以停在小型、标准、大型和卡车车位
+// 3. 卡车和大巴只能停在卡车车位，大巴需要多个相邻的卡车车位
+// 4. 无障碍车位和充电车位分别只对持证车辆和电动车开放，卡车和大巴除外
+func canFit(spotType SpotType, vehicle Vehicle) bool {
+	vehicleType := vehicle.GetType()
+	switch spotType {
+	case SpotMotorcycle:
+		return vehicleType == MOTORCYCLE
+	case SpotCompact, SpotRegular, SpotLarge:
+		return vehicleType == MOTORCYCLE || vehicleType == CAR
+	case SpotTruck:
+		return true
+	case SpotHandicapped:
+		return vehicleType != TRUCK && vehicleType != BUS && hasDisabledPermit(vehicle)
+	case SpotEVCharging:
+		return vehicleType != TRUCK && vehicleType != BUS && isElectric(vehicle)
+	}
+	return false
+}
+
+// CompatibleSpotTypes 返回能容纳车辆的车位类型
+// 车辆有资格使用的专用车位排在最前，其余按从小到大的顺序排列
+func CompatibleSpotTypes(vehicle Vehicle) []SpotType {
+	order := []SpotType{SpotHandicapped, SpotEVCharging, SpotMotorcycle, SpotCompact, SpotRegular, SpotLarge, SpotTruck}
+	var types []SpotType
+	for _, spotType := range order {
+		if canFit(spotType, vehicle) {
+			types = append(types, spotType)
+		}
+	}
+	return types
+}
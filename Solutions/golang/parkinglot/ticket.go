@@ -0,0 +1,61 @@
+package parkinglot
+
+import "time"
+
+// Ticket 停车票，入场时签发，出场时凭票结算
+type Ticket struct {
+	ID            string
+	LicensePlate  string
+	VehicleType   VehicleType
+	Floor         int
+	SpotNumber    int // 占用多个车位时为车位号最小的车位
+	SpotsOccupied int // 占用的相邻车位数
+	EntryGate     string
+	EntryTime     time.Time
+	ExitGate      string
+	ExitTime      time.Time
+	ParkingFee    float64          // 按计费策略计算的停车费
+	Discount      float64          // 月卡或验证码对停车费的减免
+	Fee           float64          // 应付总额：停车费减去减免，加上充电的电费和占位费
+	Charging      *ChargingSession // 充电会话，未充电时为nil
+	ReservationID string           // 凭预约入场时的预约号
+	Validation    *ValidationCode  // 使用的商户验证码
+	CoveredByPass bool             // 停车费由月卡免除
+
+	vehicle Vehicle
+	level   *Level
+	spot    *ParkingSpot
+	exiting bool // 出场扣款进行中，期间不能修改充电和验证码
+}
+
+// SpotID 返回停车票对应车位的全局唯一编号
+func (t *Ticket) SpotID() SpotID {
+	return SpotID{Floor: t.Floor, Spot: t.SpotNumber}
+}
+
+// IsActive 车辆尚未出场时返回true
+func (t *Ticket) IsActive() bool {
+	return t.ExitTime.IsZero()
+}
+
+// Duration 返回停车时长，未出场时计算到 now
+func (t *Ticket) Duration(now time.Time) time.Duration {
+	if !t.IsActive() {
+		return t.ExitTime.Sub(t.EntryTime)
+	}
+	return now.Sub(t.EntryTime)
+}
+
+// copy 返回停车票的副本，充电会话和验证码也一并复制
+func (t *Ticket) copy() *Ticket {
+	copied := *t
+	if t.Charging != nil {
+		session := *t.Charging
+		copied.Charging = &session
+	}
+	if t.Validation != nil {
+		validation := *t.Validation
+		copied.Validation = &validation
+	}
+	return &copied
+}
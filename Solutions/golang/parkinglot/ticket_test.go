@@ -0,0 +1,109 @@
+package parkinglot
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// newTestLot 创建一个使用可控时钟的新停车场
+func newTestLot(numSpots int, clock *time.Time) *ParkingLot {
+	lot := NewParkingLot(1)
+	lot.AddLevel(NewLevel(1, numSpots))
+	lot.now = func() time.Time { return *clock }
+	return lot
+}
+
+// 测试入场签发停车票、出场计费并释放车位
+func TestEntryAndExitGates(t *testing.T) {
+	clock := time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)
+	lot := newTestLot(4, &clock)
+	lot.SetPricingStrategy(NewHourlyPricing(5))
+	entry := NewEntryGate("E1", lot)
+	exit := NewExitGate("X1", lot)
+
+	ticket, err := entry.Enter(NewCar("京A12345"))
+	if err != nil {
+		t.Fatalf("入场失败: %v", err)
+	}
+	if ticket.LicensePlate != "京A12345" || ticket.Floor != 1 || ticket.SpotNumber != 0 || ticket.EntryGate != "E1" {
+		t.Errorf("停车票信息错误: %+v", ticket)
+	}
+	if !ticket.EntryTime.Equal(clock) {
+		t.Errorf("入场时间错误: %v", ticket.EntryTime)
+	}
+
+	// 两辆汽车占满汽车车位
+	if _, err := entry.Enter(NewCar("京A00002")); err != nil {
+		t.Fatalf("入场失败: %v", err)
+	}
+	if _, err := entry.Enter(NewCar("京A00003")); !errors.Is(err, ErrNoAvailableSpot) {
+		t.Errorf("期望 ErrNoAvailableSpot，实际: %v", err)
+	}
+
+	clock = clock.Add(2*time.Hour + 10*time.Minute)
+	fee, err := exit.Exit(ticket)
+	if err != nil {
+		t.Fatalf("出场失败: %v", err)
+	}
+	if fee != 15 {
+		t.Errorf("停车费错误，期望：15，实际：%.2f", fee)
+	}
+	if !ticket.IsActive() || ticket.ExitGate != "" {
+		t.Errorf("入场时返回的停车票是副本，不应被出场结算修改: %+v", ticket)
+	}
+	if closed, _ := lot.GetTicket(ticket.ID); closed.IsActive() || closed.ExitGate != "X1" {
+		t.Errorf("停车票未关闭: %+v", closed)
+	}
+	if _, err := exit.Exit(ticket); !errors.Is(err, ErrTicketAlreadyUsed) {
+		t.Errorf("期望 ErrTicketAlreadyUsed，实际: %v", err)
+	}
+	if _, err := exit.Exit(&Ticket{ID: "T-999999"}); !errors.Is(err, ErrTicketNotFound) {
+		t.Errorf("期望 ErrTicketNotFound，实际: %v", err)
+	}
+
+	// 车位释放后可以再次入场
+	if _, err := entry.Enter(NewCar("京A00003")); err != nil {
+		t.Errorf("车位释放后入场失败: %v", err)
+	}
+}
+
+// 测试各种计费策略
+func TestPricingStrategies(t *testing.T) {
+	monday := time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)
+	saturday := time.Date(2024, 3, 9, 9, 0, 0, 0, time.UTC)
+
+	tariff := NewTariffPricing(4, 6)
+	tariff.SetNightRate(1, 22, 7)
+
+	byType := NewVehicleTypePricing(NewHourlyPricing(5))
+	byType.SetStrategy(TRUCK, NewHourlyPricing(10))
+
+	tests := []struct {
+		name        string
+		pricing     PricingStrategy
+		vehicleType VehicleType
+		entry       time.Time
+		duration    time.Duration
+		want        float64
+	}{
+		{"不足一小时按一小时计", NewHourlyPricing(5), CAR, monday, 61 * time.Minute, 10},
+		{"零时长不收费", NewHourlyPricing(5), CAR, monday, 0, 0},
+		{"单日封顶", NewDailyCapPricing(NewHourlyPricing(5), 30), CAR, monday, 10 * time.Hour, 30},
+		{"跨日分别封顶", NewDailyCapPricing(NewHourlyPricing(5), 30), CAR, monday, 26 * time.Hour, 40},
+		{"按车型计费", byType, TRUCK, monday, 2 * time.Hour, 20},
+		{"未配置车型使用默认", byType, MOTORCYCLE, monday, 2 * time.Hour, 10},
+		{"工作日白天", tariff, CAR, monday, 3 * time.Hour, 12},
+		{"周末白天", tariff, CAR, saturday, 3 * time.Hour, 18},
+		// 21点到次日0点：21点按工作日，22、23点按夜间
+		{"跨入夜间", tariff, CAR, monday.Add(12 * time.Hour), 3 * time.Hour, 6},
+		{"免费时长内", NewGracePeriodPricing(NewHourlyPricing(5), 15*time.Minute), CAR, monday, 15 * time.Minute, 0},
+		{"超过免费时长", NewGracePeriodPricing(NewHourlyPricing(5), 15*time.Minute), CAR, monday, 16 * time.Minute, 5},
+	}
+	for _, tt := range tests {
+		got := tt.pricing.CalculateFee(tt.vehicleType, tt.entry, tt.entry.Add(tt.duration))
+		if got != tt.want {
+			t.Errorf("%s: 期望 %.2f，实际 %.2f", tt.name, tt.want, got)
+		}
+	}
+}
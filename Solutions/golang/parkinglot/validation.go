@@ -0,0 +1,131 @@
+package parkinglot
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ValidationCode 商户验证码，顾客消费后由商户发放，出场时按比例和固定金额减免停车费
+// 每个验证码只能使用一次，只减免停车费，不减免充电费用
+type ValidationCode struct {
+	Code      string
+	Merchant  string
+	Percent   float64   // 按停车费的百分比减免
+	Amount    float64   // 固定减免金额
+	ExpiresAt time.Time // 为零时不过期
+	TicketID  string    // 使用该验证码的停车票
+}
+
+// discount 计算对停车费的减免金额，不超过停车费
+func (v *ValidationCode) discount(parkingFee float64) float64 {
+	return math.Min(parkingFee, parkingFee*v.Percent/100+v.Amount)
+}
+
+// validationBook 已发放的验证码
+type validationBook struct {
+	codes map[string]*ValidationCode
+	seq   int
+	mu    sync.Mutex
+}
+
+func newValidationBook() *validationBook {
+	return &validationBook{codes: make(map[string]*ValidationCode)}
+}
+
+// IssueValidation 为商户发放验证码
+func (p *ParkingLot) IssueValidation(merchant string, percent, amount float64, expiresAt time.Time) (*ValidationCode, error) {
+	if percent < 0 || percent > 100 || amount < 0 || (percent == 0 && amount == 0) {
+		return nil, ErrInvalidAmount
+	}
+	j := p.beginChange()
+	defer j.end()
+	book := p.validations
+	book.mu.Lock()
+	defer book.mu.Unlock()
+	code := &ValidationCode{
+		Code:      fmt.Sprintf("V-%06d", book.seq+1),
+		Merchant:  merchant,
+		Percent:   percent,
+		Amount:    amount,
+		ExpiresAt: expiresAt,
+	}
+	if err := j.append(LogRecord{Op: OpIssueValidation, Validation: code}); err != nil {
+		return nil, err
+	}
+	book.restore(code)
+	copied := *code
+	return &copied, nil
+}
+
+// ApplyValidation 将验证码用于未出场的停车票，每张票只能使用一个验证码
+func (p *ParkingLot) ApplyValidation(ticketID, code string) error {
+	j := p.beginChange()
+	defer j.end()
+	p.ticketMu.Lock()
+	defer p.ticketMu.Unlock()
+
+	ticket, ok := p.tickets[ticketID]
+	if !ok {
+		return ErrTicketNotFound
+	}
+	if !ticket.IsActive() {
+		return ErrTicketAlreadyUsed
+	}
+	if ticket.exiting {
+		return ErrExitInProgress
+	}
+	if ticket.Validation != nil {
+		return ErrTicketValidated
+	}
+
+	book := p.validations
+	book.mu.Lock()
+	defer book.mu.Unlock()
+	validation, ok := book.codes[code]
+	if !ok {
+		return ErrValidationNotFound
+	}
+	if validation.TicketID != "" {
+		return ErrValidationUsed
+	}
+	if !validation.ExpiresAt.IsZero() && !p.now().Before(validation.ExpiresAt) {
+		return ErrValidationExpired
+	}
+	validation.TicketID = ticket.ID
+	copied := *validation
+	ticket.Validation = &copied
+	if err := j.append(LogRecord{Op: OpValidate, Ticket: ticket.copy()}); err != nil {
+		validation.TicketID = ""
+		ticket.Validation = nil
+		return err
+	}
+	return nil
+}
+
+// restore 按验证码状态新增或覆盖验证码，编号序号接着已有的最大序号，调用方需持有 mu
+func (b *validationBook) restore(state *ValidationCode) {
+	code := *state
+	b.codes[code.Code] = &code
+	var seq int
+	if _, err := fmt.Sscanf(code.Code, "V-%d", &seq); err == nil && seq > b.seq {
+		b.seq = seq
+	}
+}
+
+// snapshot 返回按编号排序的全部验证码副本
+func (b *validationBook) snapshot() []*ValidationCode {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	codes := make([]*ValidationCode, 0, len(b.codes))
+	for _, code := range b.codes {
+		copied := *code
+		codes = append(codes, &copied)
+	}
+	sort.Slice(codes, func(i, j int) bool {
+		return codes[i].Code < codes[j].Code
+	})
+	return codes
+}
@@ -7,6 +7,7 @@ const (
 	CAR VehicleType = iota
 	MOTORCYCLE
 	TRUCK
+	BUS
 )
 
 // Vehicle 接口定义车辆的基本行为
@@ -15,10 +16,28 @@ type Vehicle interface {
 	GetType() VehicleType
 }
 
+// ElectricVehicle 电动车，可以使用充电车位
+type ElectricVehicle interface {
+	IsElectric() bool
+}
+
+// DisabledPermitHolder 持有残疾人停车许可的车辆，可以使用无障碍车位
+type DisabledPermitHolder interface {
+	HasDisabledPermit() bool
+}
+
+// MultiSpotVehicle 需要占用多个相邻车位的车辆，如大巴和带挂车的卡车
+type MultiSpotVehicle interface {
+	SpotsRequired() int
+}
+
 // BaseVehicle 提供Vehicle接口的基本实现
 type BaseVehicle struct {
-	licensePlate string
-	vehicleType  VehicleType
+	licensePlate   string
+	vehicleType    VehicleType
+	electric       bool
+	disabledPermit bool
+	spotsRequired  int // 需要的相邻车位数，0表示1个
 }
 
 func (v *BaseVehicle) GetLicensePlate() string {
@@ -27,4 +46,62 @@ func (v *BaseVehicle) GetLicensePlate() string {
 
 func (v *BaseVehicle) GetType() VehicleType {
 	return v.vehicleType
-}
\ No newline at end of file
+}
+
+func (v *BaseVehicle) IsElectric() bool {
+	return v.electric
+}
+
+func (v *BaseVehicle) HasDisabledPermit() bool {
+	return v.disabledPermit
+}
+
+// SetDisabledPermit 设置车辆是否持有残疾人停车许可
+func (v *BaseVehicle) SetDisabledPermit(permit bool) {
+	v.disabledPermit = permit
+}
+
+// SpotsRequired 返回车辆需要占用的相邻车位数
+func (v *BaseVehicle) SpotsRequired() int {
+	if v.spotsRequired < 1 {
+		return 1
+	}
+	return v.spotsRequired
+}
+
+// SetSpotsRequired 设置车辆需要占用的相邻车位数，如卡车加挂车后需要两个车位
+func (v *BaseVehicle) SetSpotsRequired(spots int) {
+	v.spotsRequired = spots
+}
+
+func isElectric(vehicle Vehicle) bool {
+	ev, ok := vehicle.(ElectricVehicle)
+	return ok && ev.IsElectric()
+}
+
+func hasDisabledPermit(vehicle Vehicle) bool {
+	holder, ok := vehicle.(DisabledPermitHolder)
+	return ok && holder.HasDisabledPermit()
+}
+
+func (t VehicleType) String() string {
+	switch t {
+	case CAR:
+		return "CAR"
+	case MOTORCYCLE:
+		return "MOTORCYCLE"
+	case TRUCK:
+		return "TRUCK"
+	case BUS:
+		return "BUS"
+	}
+	return "UNKNOWN"
+}
+
+// spotsRequired 返回车辆需要占用的相邻车位数
+func spotsRequired(vehicle Vehicle) int {
+	if v, ok := vehicle.(MultiSpotVehicle); ok && v.SpotsRequired() > 1 {
+		return v.SpotsRequired()
+	}
+	return 1
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DefaultLowFloat 硬币余额低于该金额时只收准确金额
+const DefaultLowFloat = 5 * Yuan
+
+var (
+	allCoins = []Coin{Dollar, HalfDollar, Quarter, Dime, Nickel, Penny}
+	allNotes = []Note{Hundred, Fifty, Twenty, Ten, Five, One}
+)
+
+// Change 一组具体的硬币和纸币，用于找零和退款
+type Change struct {
+	Coins map[Coin]int
+	Notes map[Note]int
+}
+
+// NewChange 创建空的找零
+func NewChange() Change {
+	return Change{Coins: make(map[Coin]int), Notes: make(map[Note]int)}
+}
+
+// Total 返回总金额
+func (c Change) Total() Money {
+	var total Money
+	for coin, n := range c.Coins {
+		total += coin.Value() * Money(n)
+	}
+	for note, n := range c.Notes {
+		total += note.Value() * Money(n)
+	}
+	return total
+}
+
+// IsEmpty 没有任何硬币和纸币时返回true
+func (c Change) IsEmpty() bool {
+	return c.Total() == 0
+}
+
+func (c Change) addCoin(coin Coin, n int) {
+	c.Coins[coin] += n
+}
+
+func (c Change) addNote(note Note, n int) {
+	c.Notes[note] += n
+}
+
+// addChange 合并另一组硬币和纸币
+func (c Change) addChange(other Change) {
+	for coin, n := range other.Coins {
+		c.Coins[coin] += n
+	}
+	for note, n := range other.Notes {
+		c.Notes[note] += n
+	}
+}
+
+func (c Change) String() string {
+	var parts []string
+	for _, note := range allNotes {
+		if n := c.Notes[note]; n > 0 {
+			parts = append(parts, fmt.Sprintf("%s纸币x%d", note.Value(), n))
+		}
+	}
+	for _, coin := range allCoins {
+		if n := c.Coins[coin]; n > 0 {
+			parts = append(parts, fmt.Sprintf("%s硬币x%d", coin.Value(), n))
+		}
+	}
+	if len(parts) == 0 {
+		return "无"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// CashBox 钱箱，按面额记录硬币和纸币的数量
+// 找零只使用硬币，纸币进入纸币箱后不再找出
+type CashBox struct {
+	coins map[Coin]int
+	notes map[Note]int
+	mu    sync.Mutex
+}
+
+// NewCashBox 创建空钱箱
+func NewCashBox() *CashBox {
+	return &CashBox{
+		coins: make(map[Coin]int),
+		notes: make(map[Note]int),
+	}
+}
+
+// AddCoins 补充找零用的硬币
+func (b *CashBox) AddCoins(coin Coin, count int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.coins[coin] += count
+}
+
+// Deposit 将硬币和纸币存入钱箱
+func (b *CashBox) Deposit(c Change) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for coin, n := range c.Coins {
+		b.coins[coin] += n
+	}
+	for note, n := range c.Notes {
+		b.notes[note] += n
+	}
+}
+
+// Withdraw 从钱箱取出硬币，数量不足时不取出并返回错误
+func (b *CashBox) Withdraw(c Change) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for coin, n := range c.Coins {
+		if b.coins[coin] < n {
+			return fmt.Errorf("钱箱中 %s硬币不足", coin.Value())
+		}
+	}
+	for coin, n := range c.Coins {
+		b.coins[coin] -= n
+	}
+	return nil
+}
+
+// Exchange 存入 in 并取出找零 out，硬币不足时不做任何改动并返回错误
+func (b *CashBox) Exchange(in, out Change) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for coin, n := range out.Coins {
+		if b.coins[coin]+in.Coins[coin] < n {
+			return fmt.Errorf("钱箱中 %s硬币不足", coin.Value())
+		}
+	}
+	for coin, n := range in.Coins {
+		b.coins[coin] += n
+	}
+	for note, n := range in.Notes {
+		b.notes[note] += n
+	}
+	for coin, n := range out.Coins {
+		b.coins[coin] -= n
+	}
+	return nil
+}
+
+// CoinCount 返回某种硬币的数量
+func (b *CashBox) CoinCount(coin Coin) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.coins[coin]
+}
+
+// NoteCount 返回某种纸币的数量
+func (b *CashBox) NoteCount(note Note) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.notes[note]
+}
+
+// CoinTotal 返回可用于找零的硬币总额
+func (b *CashBox) CoinTotal() Money {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var total Money
+	for coin, n := range b.coins {
+		total += coin.Value() * Money(n)
+	}
+	return total
+}
+
+// MakeChange 计算找零方案：用钱箱中的硬币加上 extra 中的硬币凑出 amount，
+// 在硬币数量有限的前提下使用最少的硬币；凑不出时返回false，不会取出硬币
+func (b *CashBox) MakeChange(amount Money, extra Change) (Change, bool) {
+	b.mu.Lock()
+	available := make(map[Coin]int, len(b.coins))
+	for coin, n := range b.coins {
+		available[coin] = n
+	}
+	b.mu.Unlock()
+	for coin, n := range extra.Coins {
+		available[coin] += n
+	}
+	return makeChange(int(amount), available)
+}
+
+// makeChange 有限硬币找零：按二进制拆分把每种硬币转为0/1背包物品，动态规划求最少硬币数
+func makeChange(cents int, available map[Coin]int) (Change, bool) {
+	change := NewChange()
+	if cents == 0 {
+		return change, true
+	}
+	if cents < 0 {
+		return change, false
+	}
+
+	type item struct {
+		coin  Coin
+		count int
+	}
+	var items []item
+	coins := make([]Coin, 0, len(available))
+	for coin := range available {
+		coins = append(coins, coin)
+	}
+	sort.Slice(coins, func(i, j int) bool { return coins[i] > coins[j] })
+	for _, coin := range coins {
+		remaining := available[coin]
+		for k := 1; remaining > 0; k *= 2 {
+			n := min(k, remaining)
+			items = append(items, item{coin: coin, count: n})
+			remaining -= n
+		}
+	}
+
+	const unreachable = math.MaxInt32
+	best := make([]int, cents+1) // best[a] 凑出a分所需的最少硬币数
+	used := make([][]bool, len(items))
+	for a := 1; a <= cents; a++ {
+		best[a] = unreachable
+	}
+	for i, it := range items {
+		used[i] = make([]bool, cents+1)
+		value := int(it.coin) * it.count
+		for a := cents; a >= value; a-- {
+			if best[a-value] != unreachable && best[a-value]+it.count < best[a] {
+				best[a] = best[a-value] + it.count
+				used[i][a] = true
+			}
+		}
+	}
+	if best[cents] == unreachable {
+		return change, false
+	}
+
+	// 倒序回溯每个物品是否被选中
+	for a, i := cents, len(items)-1; i >= 0 && a > 0; i-- {
+		if used[i][a] {
+			change.addCoin(items[i].coin, items[i].count)
+			a -= int(items[i].coin) * items[i].count
+		}
+	}
+	return change, true
+}
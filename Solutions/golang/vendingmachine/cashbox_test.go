@@ -0,0 +1,157 @@
+package main
+
+import "testing"
+
+// newTestMachine 创建一台空钱箱的售货机，放入一件价格为 price 的产品并选中
+func newTestMachine(t *testing.T, price Money) *VendingMachine {
+	t.Helper()
+	vm := NewVendingMachine("VM-TEST")
+	if err := vm.AssignSlot("A1", NewProduct("SKU-TEST", "测试", price), 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := vm.Restock("A1", 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := vm.SelectProduct("A1"); err != nil {
+		t.Fatal(err)
+	}
+	return vm
+}
+
+// 测试有限硬币找零：使用最少硬币，贪心凑不出时仍能找到方案
+func TestMakeChange(t *testing.T) {
+	tests := []struct {
+		name      string
+		available map[Coin]int
+		extra     []Coin
+		amount    Money
+		want      map[Coin]int
+		ok        bool
+	}{
+		{"无需找零", map[Coin]int{Quarter: 1}, nil, 0, map[Coin]int{}, true},
+		{"最少硬币", map[Coin]int{HalfDollar: 2, Quarter: 4, Dime: 10}, nil, 75 * Fen, map[Coin]int{HalfDollar: 1, Quarter: 1}, true},
+		{"贪心失败", map[Coin]int{Quarter: 1, Dime: 3}, nil, 3 * Jiao, map[Coin]int{Dime: 3}, true},
+		{"硬币数量有限", map[Coin]int{HalfDollar: 1, Quarter: 2}, nil, 1 * Yuan, map[Coin]int{HalfDollar: 1, Quarter: 2}, true},
+		{"使用投入的硬币", map[Coin]int{}, []Coin{Dollar, Dollar}, 1 * Yuan, map[Coin]int{Dollar: 1}, true},
+		{"凑不出", map[Coin]int{Quarter: 2}, nil, 3 * Jiao, nil, false},
+	}
+	for _, tt := range tests {
+		box := NewCashBox()
+		for coin, n := range tt.available {
+			box.AddCoins(coin, n)
+		}
+		extra := NewChange()
+		for _, coin := range tt.extra {
+			extra.addCoin(coin, 1)
+		}
+		change, ok := box.MakeChange(tt.amount, extra)
+		if ok != tt.ok {
+			t.Errorf("%s: 期望 %v，实际 %v", tt.name, tt.ok, ok)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		for _, coin := range allCoins {
+			if change.Coins[coin] != tt.want[coin] {
+				t.Errorf("%s: 找零 %s，期望 %v", tt.name, change, tt.want)
+				break
+			}
+		}
+	}
+}
+
+// 测试找零从钱箱取出硬币，投入的钱进入钱箱
+func TestChangeIsPaidFromCashBox(t *testing.T) {
+	vm := newTestMachine(t, 1*Yuan+5*Jiao)
+	vm.LoadCoins(Quarter, 30)
+
+	vm.InsertCoin(Dollar)
+	if err := vm.InsertCoin(Dollar); err != nil {
+		t.Fatal(err)
+	}
+	if err := vm.DispenseProduct(); err != nil {
+		t.Fatal(err)
+	}
+	change := vm.ReturnChange()
+	if change.Total() != 5*Jiao || change.Coins[Quarter] != 2 {
+		t.Errorf("找零错误: %s", change)
+	}
+	box := vm.GetCashBox()
+	if box.CoinCount(Quarter) != 28 || box.CoinCount(Dollar) != 2 || vm.GetCollectedMoney() != 1*Yuan+5*Jiao {
+		t.Errorf("钱箱错误: 25分 %d 枚，1元 %d 枚，收入 %s", box.CoinCount(Quarter), box.CoinCount(Dollar), vm.GetCollectedMoney())
+	}
+}
+
+// 测试钱箱凑不出找零时拒收造成多付的硬币，改投准确金额后成交
+func TestRefuseSaleWhenChangeImpossible(t *testing.T) {
+	vm := newTestMachine(t, 1*Yuan+1*Jiao)
+	vm.LoadCoins(Quarter, 30)
+
+	vm.InsertCoin(Dollar)
+	if err := vm.InsertCoin(Dollar); err == nil {
+		t.Fatal("无法找零0.90元时应拒收")
+	}
+	if err := vm.DispenseProduct(); err == nil {
+		t.Fatal("拒收后金额不足，不应发放")
+	}
+	if err := vm.InsertCoin(Dime); err != nil {
+		t.Fatal(err)
+	}
+	if err := vm.DispenseProduct(); err != nil {
+		t.Fatal(err)
+	}
+	if change := vm.ReturnChange(); !change.IsEmpty() {
+		t.Errorf("准确金额不应找零，实际: %s", change)
+	}
+	if vm.GetCashBox().CoinCount(Dollar) != 1 {
+		t.Errorf("被拒收的硬币不应进入钱箱")
+	}
+}
+
+// 测试硬币余额低于下限时只收准确金额
+func TestExactChangeOnly(t *testing.T) {
+	vm := newTestMachine(t, 1*Yuan+5*Jiao)
+	if !vm.ExactChangeOnly() {
+		t.Fatal("空钱箱应只收准确金额")
+	}
+
+	vm.InsertCoin(Dollar)
+	if err := vm.InsertCoin(Dollar); err == nil {
+		t.Fatal("只收准确金额时应拒收多付的硬币")
+	}
+	if err := vm.InsertCoin(HalfDollar); err != nil {
+		t.Fatal(err)
+	}
+	if err := vm.DispenseProduct(); err != nil {
+		t.Fatal(err)
+	}
+	if change := vm.ReturnChange(); !change.IsEmpty() {
+		t.Errorf("准确金额不应找零，实际: %s", change)
+	}
+
+	vm.LoadCoins(Quarter, 12)
+	if !vm.ExactChangeOnly() {
+		t.Error("硬币余额 4.50 元低于默认下限 5 元")
+	}
+	vm.SetLowFloat(4 * Yuan)
+	if vm.ExactChangeOnly() {
+		t.Error("降低下限后应恢复找零")
+	}
+}
+
+// 测试取消交易原样退还投入的硬币和纸币
+func TestCancelReturnsInsertedMoney(t *testing.T) {
+	vm := newTestMachine(t, 8*Yuan)
+	vm.LoadCoins(Dollar, 10)
+
+	vm.InsertCoin(Quarter)
+	vm.InsertNote(Five)
+	refund := vm.ReturnChange()
+	if refund.Total() != 5*Yuan+25*Fen || refund.Coins[Quarter] != 1 || refund.Notes[Five] != 1 {
+		t.Errorf("退款错误: %s", refund)
+	}
+	if vm.GetCashBox().NoteCount(Five) != 0 || vm.GetCollectedMoney() != 0 {
+		t.Error("取消的交易不应进入钱箱")
+	}
+}
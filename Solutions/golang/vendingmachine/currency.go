@@ -1,5 +1,26 @@
 package main
 
+import "fmt"
+
+// Money 以分为单位的整数金额，避免浮点误差
+type Money int64
+
+const (
+	Fen  Money = 1   // 1分
+	Jiao Money = 10  // 1角
+	Yuan Money = 100 // 1元
+)
+
+// String 格式化为 "3.50元"
+func (m Money) String() string {
+	sign := ""
+	if m < 0 {
+		sign = "-"
+		m = -m
+	}
+	return fmt.Sprintf("%s%d.%02d元", sign, m/Yuan, m%Yuan)
+}
+
 // Coin 代表硬币面额
 type Coin int
 
@@ -12,9 +33,9 @@ const (
 	Dollar     Coin = 100 // 100分 (1美元)
 )
 
-// Value 返回硬币的价值（以元为单位）
-func (c Coin) Value() float64 {
-	return float64(c) / 100.0
+// Value 返回硬币的金额
+func (c Coin) Value() Money {
+	return Money(c)
 }
 
 // Note 代表纸币面额
@@ -29,7 +50,7 @@ const (
 	Hundred Note = 10000 // 100元
 )
 
-// Value 返回纸币的价值（以元为单位）
-func (n Note) Value() float64 {
-	return float64(n) / 100.0
+// Value 返回纸币的金额
+func (n Note) Value() Money {
+	return Money(n)
 }
@@ -0,0 +1,347 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultRestockThreshold 货道库存低于该数量时需要补货
+const DefaultRestockThreshold = 3
+
+// DefaultRecordRetention 车队默认保留的销售和故障记录条数
+const DefaultRecordRetention = 10000
+
+// Sale 一笔销售记录
+type Sale struct {
+	MachineID  string
+	Time       time.Time
+	SlotCode   string
+	SKU        string
+	Price      Money
+	Cashless   Money // 其中非现金支付的金额
+	Uncaptured Money // 扣款失败、授权保留待对账的金额，不计入 Price
+}
+
+// StockLevel 某台售货机一个货道的库存
+type StockLevel struct {
+	MachineID string
+	SlotCode  string
+	SKU       string
+	Name      string
+	Quantity  int
+	Capacity  int
+}
+
+// RestockItem 一个需要补货的货道
+type RestockItem struct {
+	SlotCode string
+	SKU      string
+	Name     string
+	Quantity int // 补满所需数量
+}
+
+// RestockStop 补货路线上的一台售货机
+type RestockStop struct {
+	MachineID string
+	Items     []RestockItem
+}
+
+// RestockRoute 补货路线，空货道多的售货机排在前面，PickList 为出发前按SKU备货的数量
+// 路线完成前，其中的货道不会再被加入新的路线
+type RestockRoute struct {
+	ID          string
+	CreatedAt   time.Time
+	CompletedAt time.Time // 为零表示路线尚未完成
+	Stops       []RestockStop
+	PickList    map[string]int
+}
+
+// fleetMachine 车队中的售货机
+type fleetMachine struct {
+	vm *VendingMachine
+}
+
+// Fleet 车队管理服务，从多台售货机收集销售、库存和故障，并在库存不足时生成补货路线
+// 收集时取走售货机上的记录，车队只保留最近的 retention 条，销售额汇总不受影响
+type Fleet struct {
+	machines   map[string]*fleetMachine
+	sales      []Sale
+	faults     []Fault
+	salesBase  int // sales[0] 的序号，之前的记录已被丢弃
+	faultsBase int
+	retention  int
+	totals     map[string]Money // 各售货机累计销售额
+	stock      []StockLevel
+	threshold  int
+	thresholds map[string]int // 按 "机器编号/货道编号" 单独设置的阈值
+	routes     []*RestockRoute
+	covered    map[string]*RestockRoute // "机器编号/货道编号" 到包含该货道的未完成路线
+	routeSeq   int
+	mu         sync.Mutex
+}
+
+// NewFleet 创建车队管理服务
+func NewFleet() *Fleet {
+	return &Fleet{
+		machines:   make(map[string]*fleetMachine),
+		retention:  DefaultRecordRetention,
+		totals:     make(map[string]Money),
+		threshold:  DefaultRestockThreshold,
+		thresholds: make(map[string]int),
+		covered:    make(map[string]*RestockRoute),
+	}
+}
+
+// AddMachine 将售货机加入车队，编号不能重复
+func (f *Fleet) AddMachine(vm *VendingMachine) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, exists := f.machines[vm.ID()]; exists {
+		return fmt.Errorf("售货机 %s 已在车队中", vm.ID())
+	}
+	f.machines[vm.ID()] = &fleetMachine{vm: vm}
+	return nil
+}
+
+// SetRestockThreshold 设置默认补货阈值
+func (f *Fleet) SetRestockThreshold(threshold int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.threshold = threshold
+}
+
+// SetSlotThreshold 为某台售货机的货道单独设置补货阈值
+func (f *Fleet) SetSlotThreshold(machineID, slotCode string, threshold int) error {
+	code, err := normalizeSlotCode(slotCode)
+	if err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.machines[machineID]; !ok {
+		return fmt.Errorf("售货机 %s 不在车队中", machineID)
+	}
+	f.thresholds[machineID+"/"+code] = threshold
+	return nil
+}
+
+// SetRecordRetention 设置保留的销售和故障记录条数，超出时丢弃最早的记录
+func (f *Fleet) SetRecordRetention(n int) error {
+	if n <= 0 {
+		return fmt.Errorf("保留条数必须大于0")
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.retention = n
+	f.trim()
+	return nil
+}
+
+// trim 丢弃超出保留条数的最早记录，调用方需持有锁
+func (f *Fleet) trim() {
+	if extra := len(f.sales) - f.retention; extra > 0 {
+		f.sales = append([]Sale(nil), f.sales[extra:]...)
+		f.salesBase += extra
+	}
+	if extra := len(f.faults) - f.retention; extra > 0 {
+		f.faults = append([]Fault(nil), f.faults[extra:]...)
+		f.faultsBase += extra
+	}
+}
+
+func (f *Fleet) thresholdFor(machineID, slotCode string) int {
+	if threshold, ok := f.thresholds[machineID+"/"+slotCode]; ok {
+		return threshold
+	}
+	return f.threshold
+}
+
+// Collect 取走各售货机新的销售和故障记录，并收集当前库存
+// 有不在未完成路线中的货道低于阈值时，为这些货道生成补货路线并返回，否则返回nil
+func (f *Fleet) Collect() *RestockRoute {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	ids := make([]string, 0, len(f.machines))
+	for id := range f.machines {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	f.stock = f.stock[:0]
+	var stops []RestockStop
+	empty := make(map[string]int)
+	for _, id := range ids {
+		m := f.machines[id]
+		sales, faults := m.vm.takeRecords()
+		for _, sale := range sales {
+			f.totals[sale.MachineID] += sale.Price
+		}
+		f.sales = append(f.sales, sales...)
+		f.faults = append(f.faults, faults...)
+
+		stop := RestockStop{MachineID: id}
+		for _, slot := range m.vm.GetInventory().GetAllSlots() {
+			if slot.Product == nil {
+				continue
+			}
+			f.stock = append(f.stock, StockLevel{
+				MachineID: id,
+				SlotCode:  slot.Code,
+				SKU:       slot.Product.SKU,
+				Name:      slot.Product.Name,
+				Quantity:  slot.Quantity,
+				Capacity:  slot.Capacity,
+			})
+			if slot.Quantity >= f.thresholdFor(id, slot.Code) || f.covered[id+"/"+slot.Code] != nil {
+				continue
+			}
+			stop.Items = append(stop.Items, RestockItem{
+				SlotCode: slot.Code,
+				SKU:      slot.Product.SKU,
+				Name:     slot.Product.Name,
+				Quantity: slot.Capacity - slot.Quantity,
+			})
+			if slot.Quantity == 0 {
+				empty[id]++
+			}
+		}
+		if len(stop.Items) > 0 {
+			stops = append(stops, stop)
+		}
+	}
+	f.trim()
+	if len(stops) == 0 {
+		return nil
+	}
+
+	// 空货道多的售货机先补，相同时按补货件数
+	sort.SliceStable(stops, func(i, j int) bool {
+		a, b := stops[i], stops[j]
+		if empty[a.MachineID] != empty[b.MachineID] {
+			return empty[a.MachineID] > empty[b.MachineID]
+		}
+		return restockQuantity(a) > restockQuantity(b)
+	})
+	f.routeSeq++
+	route := &RestockRoute{
+		ID:        fmt.Sprintf("ROUTE-%06d", f.routeSeq),
+		CreatedAt: time.Now(),
+		Stops:     stops,
+		PickList:  make(map[string]int),
+	}
+	for _, stop := range stops {
+		for _, item := range stop.Items {
+			route.PickList[item.SKU] += item.Quantity
+			f.covered[stop.MachineID+"/"+item.SlotCode] = route
+		}
+	}
+	f.routes = append(f.routes, route)
+	copied := *route
+	return &copied
+}
+
+// CompleteRoute 补货员完成路线后关闭路线，之后仍低于阈值的货道会在下次收集时生成新路线
+func (f *Fleet) CompleteRoute(routeID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, route := range f.routes {
+		if route.ID != routeID {
+			continue
+		}
+		if !route.CompletedAt.IsZero() {
+			return fmt.Errorf("补货路线 %s 已完成", routeID)
+		}
+		route.CompletedAt = time.Now()
+		for key, r := range f.covered {
+			if r == route {
+				delete(f.covered, key)
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("补货路线 %s 不存在", routeID)
+}
+
+func restockQuantity(stop RestockStop) int {
+	n := 0
+	for _, item := range stop.Items {
+		n += item.Quantity
+	}
+	return n
+}
+
+// GetSales 获取保留的销售记录
+func (f *Fleet) GetSales() []Sale {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Sale(nil), f.sales...)
+}
+
+// SalesSince 返回序号 n 起收集的销售记录（序号从0开始），以及下一次调用使用的序号
+// 已被丢弃的记录不再返回
+func (f *Fleet) SalesSince(n int) ([]Sale, int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	next := f.salesBase + len(f.sales)
+	if n < f.salesBase {
+		n = f.salesBase
+	}
+	if n >= next {
+		return nil, next
+	}
+	return append([]Sale(nil), f.sales[n-f.salesBase:]...), next
+}
+
+// GetFaults 获取保留的故障记录
+func (f *Fleet) GetFaults() []Fault {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Fault(nil), f.faults...)
+}
+
+// FaultsSince 返回序号 n 起收集的故障记录，以及下一次调用使用的序号
+func (f *Fleet) FaultsSince(n int) ([]Fault, int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	next := f.faultsBase + len(f.faults)
+	if n < f.faultsBase {
+		n = f.faultsBase
+	}
+	if n >= next {
+		return nil, next
+	}
+	return append([]Fault(nil), f.faults[n-f.faultsBase:]...), next
+}
+
+// GetStockLevels 获取最近一次收集的库存
+func (f *Fleet) GetStockLevels() []StockLevel {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]StockLevel(nil), f.stock...)
+}
+
+// GetRoutes 获取已生成的补货路线的副本
+func (f *Fleet) GetRoutes() []*RestockRoute {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	routes := make([]*RestockRoute, len(f.routes))
+	for i, route := range f.routes {
+		copied := *route
+		routes[i] = &copied
+	}
+	return routes
+}
+
+// SalesByMachine 按售货机汇总累计销售额，包括已丢弃的记录
+func (f *Fleet) SalesByMachine() map[string]Money {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	totals := make(map[string]Money, len(f.totals))
+	for id, total := range f.totals {
+		totals[id] = total
+	}
+	return totals
+}
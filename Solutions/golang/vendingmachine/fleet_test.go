@@ -0,0 +1,181 @@
+package main
+
+import "testing"
+
+// buy 用准确金额的1元硬币购买货道中的产品
+func buy(t *testing.T, vm *VendingMachine, slotCode string, yuan int) {
+	t.Helper()
+	if err := vm.SelectProduct(slotCode); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < yuan; i++ {
+		if err := vm.InsertCoin(Dollar); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := vm.DispenseProduct(); err != nil {
+		t.Fatal(err)
+	}
+	vm.ReturnChange()
+}
+
+// 测试车队收集各售货机的销售、故障和库存，并按阈值生成补货路线
+func TestFleetCollect(t *testing.T) {
+	water := NewProduct("SKU-WATER", "矿泉水", 2*Yuan)
+	cola := NewProduct("SKU-COLA", "可乐", 3*Yuan)
+
+	station := NewVendingMachine("VM-STATION")
+	station.AssignSlot("A1", water, 0)
+	station.AssignSlot("A2", cola, 0)
+	station.Restock("A1", 4)
+	station.Restock("A2", 3)
+	office := NewVendingMachine("VM-OFFICE")
+	office.AssignSlot("A1", water, 0)
+	office.Restock("A1", 10)
+
+	fleet := NewFleet()
+	for _, vm := range []*VendingMachine{station, office} {
+		if err := fleet.AddMachine(vm); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := fleet.AddMachine(NewVendingMachine("VM-OFFICE")); err == nil {
+		t.Error("重复的售货机编号应当被拒绝")
+	}
+	if fleet.Collect() != nil {
+		t.Error("库存充足时不应生成补货路线")
+	}
+
+	for i := 0; i < 3; i++ {
+		buy(t, station, "A2", 3)
+	}
+	buy(t, station, "A1", 2)
+	buy(t, station, "A1", 2)
+	buy(t, office, "A1", 2)
+	driver := NewSimulatedDriver()
+	driver.InjectJam("A1", 2)
+	office.SetHardware(driver, driver)
+	office.SelectProduct("A1")
+	office.InsertCoin(Dollar)
+	office.InsertCoin(Dollar)
+	office.DispenseProduct()
+	office.ReturnChange()
+	if err := fleet.SetSlotThreshold("VM-OFFICE", "a1", 10); err != nil {
+		t.Fatal(err)
+	}
+	if err := fleet.SetSlotThreshold("VM-OFFICE", "A01", 10); err == nil {
+		t.Error("非规范的货道编号应被拒绝")
+	}
+
+	route := fleet.Collect()
+	if route == nil || len(route.Stops) != 2 {
+		t.Fatalf("补货路线错误: %+v", route)
+	}
+	// station 的 A2 已空，排在前面
+	first := route.Stops[0]
+	if first.MachineID != "VM-STATION" || len(first.Items) != 2 || first.Items[1].SlotCode != "A2" || first.Items[1].Quantity != 10 {
+		t.Errorf("第一站错误: %+v", first)
+	}
+	if route.PickList["SKU-WATER"] != 8+1 || route.PickList["SKU-COLA"] != 10 {
+		t.Errorf("备货清单错误: %v", route.PickList)
+	}
+
+	totals := fleet.SalesByMachine()
+	if len(fleet.GetSales()) != 6 || totals["VM-STATION"] != 13*Yuan || totals["VM-OFFICE"] != 2*Yuan {
+		t.Errorf("销售汇总错误: %v", totals)
+	}
+	if faults := fleet.GetFaults(); len(faults) != 1 || faults[0].MachineID != "VM-OFFICE" {
+		t.Errorf("故障记录错误: %+v", faults)
+	}
+	if len(fleet.GetStockLevels()) != 3 {
+		t.Errorf("库存记录错误: %+v", fleet.GetStockLevels())
+	}
+
+	// 再次收集不会重复记录，也不会为已在路线中的货道再生成路线
+	if again := fleet.Collect(); again != nil {
+		t.Errorf("已在未完成路线中的货道不应再生成路线: %+v", again)
+	}
+	if len(fleet.GetSales()) != 6 || len(fleet.GetFaults()) != 1 || len(fleet.GetRoutes()) != 1 {
+		t.Error("重复收集了记录")
+	}
+	if len(station.GetSales()) != 0 || len(office.GetFaults()) != 0 {
+		t.Error("收集后售货机上不应保留记录")
+	}
+}
+
+// 测试补货路线完成后，仍低于阈值的货道在下次收集时生成新路线
+func TestFleetRouteLifecycle(t *testing.T) {
+	water := NewProduct("SKU-WATER", "矿泉水", 2*Yuan)
+	vm := NewVendingMachine("VM-1")
+	vm.AssignSlot("A1", water, 0)
+	vm.AssignSlot("A2", water, 0)
+	vm.Restock("A1", 1)
+	vm.Restock("A2", 5)
+
+	fleet := NewFleet()
+	if err := fleet.AddMachine(vm); err != nil {
+		t.Fatal(err)
+	}
+	route := fleet.Collect()
+	if route == nil || route.ID == "" || len(route.Stops[0].Items) != 1 {
+		t.Fatalf("补货路线错误: %+v", route)
+	}
+
+	// A2 新跌破阈值，只为它生成路线
+	for i := 0; i < 3; i++ {
+		buy(t, vm, "A2", 2)
+	}
+	second := fleet.Collect()
+	if second == nil || len(second.Stops[0].Items) != 1 || second.Stops[0].Items[0].SlotCode != "A2" {
+		t.Fatalf("新路线应只包含 A2: %+v", second)
+	}
+
+	if err := fleet.CompleteRoute(route.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := fleet.CompleteRoute(route.ID); err == nil {
+		t.Error("已完成的路线不能再次完成")
+	}
+	if err := fleet.CompleteRoute("ROUTE-999999"); err == nil {
+		t.Error("不存在的路线应当报错")
+	}
+	// 补货员没有补 A1，路线完成后重新生成
+	third := fleet.Collect()
+	if third == nil || len(third.Stops[0].Items) != 1 || third.Stops[0].Items[0].SlotCode != "A1" {
+		t.Fatalf("完成后仍缺货的 A1 应生成新路线: %+v", third)
+	}
+	if routes := fleet.GetRoutes(); len(routes) != 3 || routes[0].CompletedAt.IsZero() || !routes[1].CompletedAt.IsZero() {
+		t.Errorf("路线状态错误: %+v", routes)
+	}
+}
+
+// 测试车队只保留最近的记录，销售额汇总和增量读取不受影响
+func TestFleetRecordRetention(t *testing.T) {
+	water := NewProduct("SKU-WATER", "矿泉水", 2*Yuan)
+	vm := NewVendingMachine("VM-1")
+	vm.AssignSlot("A1", water, 0)
+	vm.Restock("A1", 10)
+
+	fleet := NewFleet()
+	fleet.AddMachine(vm)
+	if err := fleet.SetRecordRetention(0); err == nil {
+		t.Error("保留条数为0应当被拒绝")
+	}
+	fleet.SetRecordRetention(2)
+	for i := 0; i < 3; i++ {
+		buy(t, vm, "A1", 2)
+	}
+	fleet.Collect()
+	sales, next := fleet.SalesSince(0)
+	if len(fleet.GetSales()) != 2 || len(sales) != 2 || next != 3 || fleet.SalesByMachine()["VM-1"] != 6*Yuan {
+		t.Errorf("保留记录错误: %d 条，下一序号 %d，汇总 %v", len(sales), next, fleet.SalesByMachine())
+	}
+	buy(t, vm, "A1", 2)
+	fleet.Collect()
+	if sales, next = fleet.SalesSince(next); len(sales) != 1 || next != 4 {
+		t.Errorf("增量读取错误: %d 条，下一序号 %d", len(sales), next)
+	}
+	if sales, _ = fleet.SalesSince(next); len(sales) != 0 {
+		t.Errorf("没有新记录时应返回空: %+v", sales)
+	}
+}
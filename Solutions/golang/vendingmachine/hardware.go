@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DispenseAttempts 每次出货的最多尝试次数，失败重试一次后退款
+	DispenseAttempts = 2
+	// MaxConsecutiveFaults 连续出货故障达到该次数后暂停服务
+	MaxConsecutiveFaults = 3
+)
+
+// Motor 货道电机，转动一圈推出一件产品
+type Motor interface {
+	// Rotate 转动货道电机，卡住时返回错误
+	Rotate(slotCode string) error
+}
+
+// DropSensor 出货口的掉落传感器
+type DropSensor interface {
+	// ProductDropped 上次转动电机后是否检测到产品落下
+	ProductDropped() bool
+}
+
+// Fault 一次故障：出货失败，或撤销非现金支付授权失败等需要人工处理的问题
+type Fault struct {
+	MachineID string
+	Time      time.Time
+	SlotCode  string
+	Reason    string
+}
+
+// SimulatedDriver 模拟的电机和掉落传感器，可以按货道注入故障
+type SimulatedDriver struct {
+	jams    map[string]int // 货道剩余的电机卡住次数
+	misses  map[string]int // 货道剩余的未检测到掉落次数
+	dropped bool
+	mu      sync.Mutex
+}
+
+// NewSimulatedDriver 创建不会出故障的模拟驱动
+func NewSimulatedDriver() *SimulatedDriver {
+	return &SimulatedDriver{
+		jams:   make(map[string]int),
+		misses: make(map[string]int),
+	}
+}
+
+// InjectJam 让货道接下来 times 次转动时电机卡住
+func (d *SimulatedDriver) InjectJam(slotCode string, times int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.jams[strings.ToUpper(slotCode)] += times
+}
+
+// InjectMissedDrop 让货道接下来 times 次转动后检测不到产品落下
+func (d *SimulatedDriver) InjectMissedDrop(slotCode string, times int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.misses[strings.ToUpper(slotCode)] += times
+}
+
+// Rotate 转动货道电机
+func (d *SimulatedDriver) Rotate(slotCode string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	code := strings.ToUpper(slotCode)
+	d.dropped = false
+	if d.jams[code] > 0 {
+		d.jams[code]--
+		return fmt.Errorf("货道 %s 电机卡住", code)
+	}
+	if d.misses[code] > 0 {
+		d.misses[code]--
+		return nil
+	}
+	d.dropped = true
+	return nil
+}
+
+// ProductDropped 上次转动后是否检测到产品落下
+func (d *SimulatedDriver) ProductDropped() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.dropped
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// newFaultyMachine 创建使用模拟驱动的售货机，A1 货道有 stock 件 1元 的产品
+func newFaultyMachine(t *testing.T, stock int) (*VendingMachine, *SimulatedDriver) {
+	t.Helper()
+	vm := NewVendingMachine("VM-TEST")
+	driver := NewSimulatedDriver()
+	vm.SetHardware(driver, driver)
+	if err := vm.AssignSlot("A1", NewProduct("SKU-TEST", "测试", 1*Yuan), 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := vm.Restock("A1", stock); err != nil {
+		t.Fatal(err)
+	}
+	return vm, driver
+}
+
+// buyWithDollar 选择 A1 并投入 1元 硬币后出货
+func buyWithDollar(t *testing.T, vm *VendingMachine) error {
+	t.Helper()
+	if err := vm.SelectProduct("A1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := vm.InsertCoin(Dollar); err != nil {
+		t.Fatal(err)
+	}
+	return vm.DispenseProduct()
+}
+
+// 测试出货失败重试一次，重试仍失败时记录故障并退款
+func TestDispenseRetryThenRefund(t *testing.T) {
+	tests := []struct {
+		name   string
+		inject func(d *SimulatedDriver)
+		ok     bool
+		faults int // 成交时的故障数：未检测到掉落后重试成功，库存可能与实际不符
+	}{
+		{"电机卡住一次", func(d *SimulatedDriver) { d.InjectJam("A1", 1) }, true, 0},
+		{"掉落未检测到一次", func(d *SimulatedDriver) { d.InjectMissedDrop("A1", 1) }, true, 1},
+		{"电机连续卡住", func(d *SimulatedDriver) { d.InjectJam("A1", DispenseAttempts) }, false, 0},
+		{"掉落连续未检测到", func(d *SimulatedDriver) { d.InjectMissedDrop("A1", DispenseAttempts) }, false, 0},
+	}
+	for _, tt := range tests {
+		vm, driver := newFaultyMachine(t, 1)
+		tt.inject(driver)
+		err := buyWithDollar(t, vm)
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: 期望成功 %v，实际: %v", tt.name, tt.ok, err)
+			continue
+		}
+		change := vm.ReturnChange()
+		quantity := vm.GetInventory().GetQuantity("SKU-TEST")
+		if tt.ok {
+			if !change.IsEmpty() || quantity != 0 || vm.GetCollectedMoney() != 1*Yuan {
+				t.Errorf("%s: 重试成功后应正常成交，找零 %s，库存 %d", tt.name, change, quantity)
+			}
+			faults := vm.GetFaults()
+			if len(faults) != tt.faults || vm.IsOutOfService() {
+				t.Errorf("%s: 期望 %d 条故障，实际 %+v", tt.name, tt.faults, faults)
+			} else if tt.faults > 0 && !strings.Contains(faults[0].Reason, "核对库存") {
+				t.Errorf("%s: 应记录需要核对库存的故障: %+v", tt.name, faults)
+			}
+			continue
+		}
+		if change.Coins[Dollar] != 1 || quantity != 1 || vm.GetCollectedMoney() != 0 || len(vm.GetSales()) != 0 {
+			t.Errorf("%s: 应退还投入的硬币且不扣库存，退款 %s，库存 %d", tt.name, change, quantity)
+		}
+		if faults := vm.GetFaults(); len(faults) != 1 || faults[0].SlotCode != "A1" {
+			t.Errorf("%s: 应记录一次故障: %+v", tt.name, faults)
+		}
+		if vm.GetCashBox().CoinCount(Dollar) != 0 {
+			t.Errorf("%s: 退款的硬币不应进入钱箱", tt.name)
+		}
+	}
+}
+
+// 测试连续出货故障达到上限后暂停服务，成功出货会清零连续故障计数
+func TestOutOfServiceAfterConsecutiveFaults(t *testing.T) {
+	vm, driver := newFaultyMachine(t, 5)
+
+	for i := 1; i < MaxConsecutiveFaults; i++ {
+		driver.InjectJam("A1", DispenseAttempts)
+		if err := buyWithDollar(t, vm); err == nil {
+			t.Fatal("出货应失败")
+		}
+		vm.ReturnChange()
+	}
+	if err := buyWithDollar(t, vm); err != nil {
+		t.Fatal(err)
+	}
+	vm.ReturnChange()
+
+	for i := 1; i <= MaxConsecutiveFaults; i++ {
+		if vm.IsOutOfService() {
+			t.Fatalf("第%d次故障前不应暂停服务", i)
+		}
+		driver.InjectMissedDrop("A1", DispenseAttempts)
+		if err := buyWithDollar(t, vm); err == nil {
+			t.Fatal("出货应失败")
+		}
+	}
+	if !vm.IsOutOfService() {
+		t.Fatalf("连续 %d 次故障后应暂停服务", MaxConsecutiveFaults)
+	}
+	// 退款只属于出货失败的那笔交易，之前交易未取走的退款不会转给下一位顾客
+	if refund := vm.ReturnChange(); refund.Coins[Dollar] != 1 {
+		t.Errorf("暂停服务后仍应退还最后一笔交易的货款，实际: %s", refund)
+	}
+	if refund := vm.ReturnChange(); !refund.IsEmpty() {
+		t.Errorf("退款只能取走一次，实际: %s", refund)
+	}
+	if err := vm.SelectProduct("A1"); err == nil {
+		t.Error("暂停服务时应拒绝交易")
+	}
+	if got := len(vm.GetFaults()); got != 2*MaxConsecutiveFaults-1 {
+		t.Errorf("故障记录应为 %d 条，实际: %d", 2*MaxConsecutiveFaults-1, got)
+	}
+}
+
+// 测试出货失败的退款不会在下一笔交易中退给下一位顾客
+func TestRefundNotCarriedOver(t *testing.T) {
+	vm, driver := newFaultyMachine(t, 2)
+	driver.InjectJam("A1", DispenseAttempts)
+	if err := buyWithDollar(t, vm); err == nil {
+		t.Fatal("出货应失败")
+	}
+	// 顾客没有取走退款，下一位顾客开始新交易
+	if err := buyWithDollar(t, vm); err != nil {
+		t.Fatal(err)
+	}
+	if change := vm.ReturnChange(); !change.IsEmpty() {
+		t.Errorf("上一笔交易的退款不应退给下一位顾客，实际: %s", change)
+	}
+}
+
+// 测试进入和退出维护模式
+func TestMaintenance(t *testing.T) {
+	vm, driver := newFaultyMachine(t, 5)
+
+	if err := vm.ExitMaintenance(); err == nil {
+		t.Error("不在维护模式时不能退出维护")
+	}
+	vm.SelectProduct("A1")
+	if err := vm.EnterMaintenance(); err == nil {
+		t.Error("交易进行中不能进入维护模式")
+	}
+	vm.ReturnChange()
+	if err := vm.EnterMaintenance(); err != nil {
+		t.Fatal(err)
+	}
+	if err := vm.SelectProduct("A1"); err == nil {
+		t.Error("维护中应拒绝交易")
+	}
+	if err := vm.ExitMaintenance(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 暂停服务后进入维护，退出维护时清零连续故障计数
+	for i := 0; i < MaxConsecutiveFaults; i++ {
+		driver.InjectJam("A1", DispenseAttempts)
+		buyWithDollar(t, vm)
+		vm.ReturnChange()
+	}
+	if !vm.IsOutOfService() {
+		t.Fatal("应暂停服务")
+	}
+	if err := vm.EnterMaintenance(); err != nil {
+		t.Fatal(err)
+	}
+	if vm.IsOutOfService() {
+		t.Error("进入维护后不再是暂停服务状态")
+	}
+	if err := vm.ExitMaintenance(); err != nil {
+		t.Fatal(err)
+	}
+	driver.InjectJam("A1", DispenseAttempts)
+	buyWithDollar(t, vm)
+	vm.ReturnChange()
+	if vm.IsOutOfService() {
+		t.Error("退出维护后连续故障计数应清零")
+	}
+	if err := buyWithDollar(t, vm); err != nil {
+		t.Errorf("退出维护后应恢复服务: %v", err)
+	}
+}
+
+// 测试混合支付出货失败时退还现金并撤销非现金授权
+func TestRefundVoidsCashlessAuthorization(t *testing.T) {
+	vm, driver := newFaultyMachine(t, 1)
+	gateway := NewFakeGateway()
+	gateway.AddAccount("CARD-1", 5*Yuan)
+	vm.SetPaymentProvider(gateway)
+	vm.SetSlotPrice("A1", 2*Yuan)
+
+	vm.SelectProduct("A1")
+	vm.InsertCoin(Dollar)
+	if err := vm.PayCashless("CARD-1"); err != nil {
+		t.Fatal(err)
+	}
+	driver.InjectMissedDrop("A1", DispenseAttempts)
+	if err := vm.DispenseProduct(); err == nil {
+		t.Fatal("出货应失败")
+	}
+	if auth, _ := gateway.GetAuthorization("AUTH-000001"); auth.Status != Voided || gateway.GetBalance("CARD-1") != 5*Yuan {
+		t.Errorf("出货失败应撤销授权: %+v，余额 %s", auth, gateway.GetBalance("CARD-1"))
+	}
+	if refund := vm.ReturnChange(); refund.Total() != 1*Yuan || refund.Coins[Dollar] != 1 {
+		t.Errorf("应退还投入的 1元，实际: %s", refund)
+	}
+	if vm.GetCollectedMoney() != 0 || vm.GetCashlessSales() != 0 {
+		t.Error("出货失败不应计入收入")
+	}
+}
+
+// 测试钱箱在交易期间被取走硬币时重新凑找零，凑不出时退还投入的钱并记录故障
+func TestChangeShortfallAfterDispense(t *testing.T) {
+	vm := newTestMachine(t, 1*Yuan+5*Jiao)
+	vm.SetLowFloat(0)
+	vm.LoadCoins(Quarter, 10)
+	vm.LoadCoins(Dime, 30)
+	vm.InsertCoin(Dollar)
+	vm.InsertCoin(Dollar)
+	if err := vm.DispenseProduct(); err != nil {
+		t.Fatal(err)
+	}
+	// 原定用两枚 2角5分 找零，全部被取走后改用 1角
+	if err := vm.GetCashBox().Withdraw(Change{Coins: map[Coin]int{Quarter: 10}}); err != nil {
+		t.Fatal(err)
+	}
+	if change := vm.ReturnChange(); change.Total() != 5*Jiao || change.Coins[Dime] != 5 {
+		t.Errorf("应改用 1角 找零，实际: %s", change)
+	}
+	if vm.GetCollectedMoney() != 1*Yuan+5*Jiao || len(vm.GetFaults()) != 0 {
+		t.Errorf("重新凑出找零时应正常成交: 收入 %s，故障 %+v", vm.GetCollectedMoney(), vm.GetFaults())
+	}
+
+	vm.Restock("A1", 1)
+	vm.SelectProduct("A1")
+	vm.InsertCoin(Dollar)
+	vm.InsertNote(One)
+	if err := vm.DispenseProduct(); err != nil {
+		t.Fatal(err)
+	}
+	vm.GetCashBox().Withdraw(Change{Coins: map[Coin]int{Dime: 25}})
+	refund := vm.ReturnChange()
+	if refund.Coins[Dollar] != 1 || refund.Notes[One] != 1 {
+		t.Errorf("凑不出找零时应退还投入的钱，实际: %s", refund)
+	}
+	faults := vm.GetFaults()
+	if len(faults) != 1 || faults[0].SlotCode != "A1" || !strings.Contains(faults[0].Reason, "无法找零") {
+		t.Errorf("应记录找零故障: %+v", faults)
+	}
+	sales := vm.GetSales()
+	if vm.GetCollectedMoney() != 1*Yuan+5*Jiao || len(sales) != 2 || sales[1].Price != 0 {
+		t.Errorf("退还的现金不应计入收入: 收入 %s，销售 %+v", vm.GetCollectedMoney(), sales)
+	}
+	if vm.GetCashBox().NoteCount(One) != 0 || vm.IsOutOfService() {
+		t.Error("退还的纸币不应进入钱箱，找零故障不应暂停服务")
+	}
+}
@@ -2,66 +2,231 @@ package main
 
 import (
 	"fmt"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 )
 
-// Inventory 管理售货机中的产品库存
+const (
+	SlotRows            = "ABCDEF" // 货道行号
+	SlotColumns         = 8        // 每行的货道数
+	DefaultSlotCapacity = 10       // 货道默认容量
+)
+
+// Slot 货道，按 A1..F8 编号，每个货道放一种产品并单独定价
+type Slot struct {
+	Code     string
+	Product  *Product
+	Price    Money
+	Capacity int
+	Quantity int
+}
+
+// normalizeSlotCode 校验并规范化货道编号，如 "a1" 规范为 "A1"
+// 列号按规范格式重新生成，"A01"、"A+1" 这类写法视为无效
+func normalizeSlotCode(code string) (string, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if len(code) < 2 || !strings.ContainsRune(SlotRows, rune(code[0])) {
+		return "", fmt.Errorf("无效的货道编号: %s", code)
+	}
+	column, err := strconv.Atoi(code[1:])
+	if err != nil || column < 1 || column > SlotColumns {
+		return "", fmt.Errorf("无效的货道编号: %s", code)
+	}
+	canonical := fmt.Sprintf("%c%d", code[0], column)
+	if canonical != code {
+		return "", fmt.Errorf("无效的货道编号: %s", code)
+	}
+	return canonical, nil
+}
+
+// Inventory 管理售货机中各货道的产品库存
 // 使用 sync.RWMutex 确保线程安全
 type Inventory struct {
-	products map[*Product]int
+	slots    map[string]*Slot
+	products map[string]*Product // 按SKU索引的产品目录
 	mu       sync.RWMutex
 }
 
-// NewInventory 创建一个新的库存管理器
+// NewInventory 创建一个新的库存管理器，包含 A1..F8 共48个空货道
 func NewInventory() *Inventory {
-	return &Inventory{
-		products: make(map[*Product]int),
+	inv := &Inventory{
+		slots:    make(map[string]*Slot),
+		products: make(map[string]*Product),
+	}
+	for _, row := range SlotRows {
+		for column := 1; column <= SlotColumns; column++ {
+			code := fmt.Sprintf("%c%d", row, column)
+			inv.slots[code] = &Slot{Code: code, Capacity: DefaultSlotCapacity}
+		}
+	}
+	return inv
+}
+
+// slot 按编号查找货道，调用方需持有锁
+func (inv *Inventory) slot(code string) (*Slot, error) {
+	code, err := normalizeSlotCode(code)
+	if err != nil {
+		return nil, err
+	}
+	slot, ok := inv.slots[code]
+	if !ok {
+		return nil, fmt.Errorf("货道 %s 不存在", code)
+	}
+	return slot, nil
+}
+
+// AssignProduct 将产品放入货道，price 不大于0时使用产品的建议零售价
+// 货道中还有其他产品时不能更换；同一SKU的产品共用目录中的同一个产品
+func (inv *Inventory) AssignProduct(code string, product *Product, price Money) error {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+
+	slot, err := inv.slot(code)
+	if err != nil {
+		return err
+	}
+	if existing, ok := inv.products[product.SKU]; ok {
+		product = existing
+	} else {
+		inv.products[product.SKU] = product
+	}
+	if slot.Product != nil && slot.Product != product && slot.Quantity > 0 {
+		return fmt.Errorf("货道 %s 中还有 %s，请先清空", slot.Code, slot.Product.Name)
+	}
+	if price <= 0 {
+		price = product.Price
+	}
+	slot.Product = product
+	slot.Price = price
+	return nil
+}
+
+// SetPrice 设置货道的售价
+func (inv *Inventory) SetPrice(code string, price Money) error {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+
+	slot, err := inv.slot(code)
+	if err != nil {
+		return err
 	}
+	if price <= 0 {
+		return fmt.Errorf("价格必须大于0")
+	}
+	slot.Price = price
+	return nil
 }
 
-// AddProduct 添加产品到库存
-func (inv *Inventory) AddProduct(product *Product, quantity int) {
+// SetCapacity 设置货道容量，不能小于当前库存
+func (inv *Inventory) SetCapacity(code string, capacity int) error {
 	inv.mu.Lock()
 	defer inv.mu.Unlock()
-	inv.products[product] += quantity
+
+	slot, err := inv.slot(code)
+	if err != nil {
+		return err
+	}
+	if capacity < slot.Quantity {
+		return fmt.Errorf("货道 %s 当前库存 %d 超过容量 %d", slot.Code, slot.Quantity, capacity)
+	}
+	slot.Capacity = capacity
+	return nil
 }
 
-// RemoveProduct 从库存中移除一个产品
-func (inv *Inventory) RemoveProduct(product *Product) error {
+// Restock 补货，补货后数量不能超过货道容量
+func (inv *Inventory) Restock(code string, quantity int) error {
 	inv.mu.Lock()
 	defer inv.mu.Unlock()
 
-	if qty, exists := inv.products[product]; !exists || qty <= 0 {
-		return fmt.Errorf("产品 %s 已售罄", product.Name)
+	slot, err := inv.slot(code)
+	if err != nil {
+		return err
+	}
+	if slot.Product == nil {
+		return fmt.Errorf("货道 %s 尚未分配产品", slot.Code)
+	}
+	if quantity <= 0 {
+		return fmt.Errorf("补货数量必须大于0")
+	}
+	if slot.Quantity+quantity > slot.Capacity {
+		return fmt.Errorf("货道 %s 容量不足，最多还能补 %d 件", slot.Code, slot.Capacity-slot.Quantity)
 	}
+	slot.Quantity += quantity
+	return nil
+}
+
+// RemoveProduct 从货道中移除一件产品
+func (inv *Inventory) RemoveProduct(code string) error {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
 
-	inv.products[product]--
+	slot, err := inv.slot(code)
+	if err != nil {
+		return err
+	}
+	if slot.Product == nil || slot.Quantity <= 0 {
+		return fmt.Errorf("货道 %s 已售罄", slot.Code)
+	}
+	slot.Quantity--
 	return nil
 }
 
-// GetQuantity 获取产品的库存数量
-func (inv *Inventory) GetQuantity(product *Product) int {
+// GetSlot 获取货道信息的副本
+func (inv *Inventory) GetSlot(code string) (Slot, error) {
+	inv.mu.RLock()
+	defer inv.mu.RUnlock()
+
+	slot, err := inv.slot(code)
+	if err != nil {
+		return Slot{}, err
+	}
+	return *slot, nil
+}
+
+// IsAvailable 检查货道是否有货
+func (inv *Inventory) IsAvailable(code string) bool {
+	slot, err := inv.GetSlot(code)
+	return err == nil && slot.Product != nil && slot.Quantity > 0
+}
+
+// GetQuantity 获取某个SKU在所有货道中的库存总数
+func (inv *Inventory) GetQuantity(sku string) int {
 	inv.mu.RLock()
 	defer inv.mu.RUnlock()
-	return inv.products[product]
+
+	total := 0
+	for _, slot := range inv.slots {
+		if slot.Product != nil && slot.Product.SKU == sku {
+			total += slot.Quantity
+		}
+	}
+	return total
 }
 
-// IsAvailable 检查产品是否有库存
-func (inv *Inventory) IsAvailable(product *Product) bool {
+// GetProduct 按SKU查找产品
+func (inv *Inventory) GetProduct(sku string) (*Product, bool) {
 	inv.mu.RLock()
 	defer inv.mu.RUnlock()
-	return inv.products[product] > 0
+	product, ok := inv.products[sku]
+	return product, ok
 }
 
-// GetAllProducts 获取所有产品及其数量
-func (inv *Inventory) GetAllProducts() map[*Product]int {
+// GetAllSlots 获取已分配产品的货道，按编号排列
+func (inv *Inventory) GetAllSlots() []Slot {
 	inv.mu.RLock()
 	defer inv.mu.RUnlock()
 
 	// 返回副本以避免并发问题
-	result := make(map[*Product]int)
-	for p, q := range inv.products {
-		result[p] = q
+	var result []Slot
+	for _, slot := range inv.slots {
+		if slot.Product != nil {
+			result = append(result, *slot)
+		}
 	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Code < result[j].Code
+	})
 	return result
 }
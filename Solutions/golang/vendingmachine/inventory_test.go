@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+// 测试货道编号的校验和规范化
+func TestSlotCodes(t *testing.T) {
+	inv := NewInventory()
+	tests := []struct {
+		code string
+		want string // 为空表示编号无效
+	}{
+		{"A1", "A1"},
+		{"f8", "F8"},
+		{" c3 ", "C3"},
+		{"G1", ""},
+		{"A9", ""},
+		{"A0", ""},
+		{"A01", ""},
+		{"A+1", ""},
+		{"A", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		slot, err := inv.GetSlot(tt.code)
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("%q: 期望无效，实际得到货道 %s", tt.code, slot.Code)
+			}
+			if inv.IsAvailable(tt.code) {
+				t.Errorf("%q: 无效编号不应有货", tt.code)
+			}
+			continue
+		}
+		if err != nil || slot.Code != tt.want || slot.Capacity != DefaultSlotCapacity {
+			t.Errorf("%q: 期望货道 %s，实际 %+v %v", tt.code, tt.want, slot, err)
+		}
+	}
+
+	count := 0
+	for _, row := range SlotRows {
+		for column := 1; column <= SlotColumns; column++ {
+			if _, err := inv.GetSlot(fmt.Sprintf("%c%d", row, column)); err == nil {
+				count++
+			}
+		}
+	}
+	if count != 48 {
+		t.Errorf("应有48个货道，实际: %d", count)
+	}
+}
+
+// 测试同一SKU的产品共用目录中的同一个产品，有库存时不能更换产品
+func TestAssignProductDeduplicatesSKU(t *testing.T) {
+	inv := NewInventory()
+	cola := NewProduct("SKU-COLA", "可乐", 3*Yuan)
+	if err := inv.AssignProduct("A1", cola, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := inv.AssignProduct("A2", NewProduct("SKU-COLA", "可口可乐", 4*Yuan), 0); err != nil {
+		t.Fatal(err)
+	}
+	a2, _ := inv.GetSlot("A2")
+	if a2.Product != cola || a2.Price != 3*Yuan {
+		t.Errorf("同一SKU应使用目录中的产品: %+v", a2)
+	}
+	if product, ok := inv.GetProduct("SKU-COLA"); !ok || product != cola {
+		t.Errorf("目录中的产品错误: %+v", product)
+	}
+
+	inv.Restock("A1", 2)
+	inv.Restock("A2", 3)
+	if got := inv.GetQuantity("SKU-COLA"); got != 5 {
+		t.Errorf("SKU库存应为 5，实际: %d", got)
+	}
+	water := NewProduct("SKU-WATER", "矿泉水", 2*Yuan)
+	if err := inv.AssignProduct("A1", water, 0); err == nil {
+		t.Error("货道有库存时不应更换产品")
+	}
+	if err := inv.AssignProduct("A1", NewProduct("SKU-COLA", "可乐", 3*Yuan), 0); err != nil {
+		t.Errorf("重新放入同一SKU应成功: %v", err)
+	}
+	if err := inv.AssignProduct("B1", water, 0); err != nil {
+		t.Fatal(err)
+	}
+	if slots := inv.GetAllSlots(); len(slots) != 3 || slots[0].Code != "A1" || slots[2].Code != "B1" {
+		t.Errorf("已分配的货道错误: %+v", slots)
+	}
+}
+
+// 测试补货和设置容量时检查货道容量
+func TestRestockAndCapacity(t *testing.T) {
+	inv := NewInventory()
+	if err := inv.Restock("A1", 1); err == nil {
+		t.Error("未分配产品的货道不应补货")
+	}
+	inv.AssignProduct("A1", NewProduct("SKU-CHIPS", "薯片", 5*Yuan), 0)
+
+	steps := []struct {
+		name     string
+		run      func() error
+		ok       bool
+		quantity int
+		capacity int
+	}{
+		{"补货", func() error { return inv.Restock("A1", 6) }, true, 6, 10},
+		{"数量必须大于0", func() error { return inv.Restock("A1", 0) }, false, 6, 10},
+		{"超过容量", func() error { return inv.Restock("A1", 5) }, false, 6, 10},
+		{"补满", func() error { return inv.Restock("a1", 4) }, true, 10, 10},
+		{"容量小于库存", func() error { return inv.SetCapacity("A1", 8) }, false, 10, 10},
+		{"售出", func() error { return inv.RemoveProduct("A1") }, true, 9, 10},
+		{"缩小容量", func() error { return inv.SetCapacity("A1", 9) }, true, 9, 9},
+		{"容量已满", func() error { return inv.Restock("A1", 1) }, false, 9, 9},
+		{"扩大容量", func() error { return inv.SetCapacity("A1", 12) }, true, 9, 12},
+		{"扩容后补货", func() error { return inv.Restock("A1", 3) }, true, 12, 12},
+	}
+	for _, step := range steps {
+		err := step.run()
+		if (err == nil) != step.ok {
+			t.Errorf("%s: 期望成功 %v，实际: %v", step.name, step.ok, err)
+		}
+		slot, _ := inv.GetSlot("A1")
+		if slot.Quantity != step.quantity || slot.Capacity != step.capacity {
+			t.Errorf("%s: 期望库存 %d/%d，实际 %d/%d", step.name, step.quantity, step.capacity, slot.Quantity, slot.Capacity)
+		}
+	}
+}
+
+// 测试同一产品在不同货道可以单独定价
+func TestPerSlotPricing(t *testing.T) {
+	inv := NewInventory()
+	juice := NewProduct("SKU-JUICE", "果汁", 4*Yuan)
+	inv.AssignProduct("A1", juice, 0)
+	inv.AssignProduct("F8", juice, 4*Yuan+5*Jiao)
+
+	tests := []struct {
+		code  string
+		price Money
+	}{
+		{"A1", 4 * Yuan},
+		{"F8", 4*Yuan + 5*Jiao},
+	}
+	for _, tt := range tests {
+		if slot, _ := inv.GetSlot(tt.code); slot.Price != tt.price {
+			t.Errorf("%s: 售价应为 %s，实际: %s", tt.code, tt.price, slot.Price)
+		}
+	}
+	if err := inv.SetPrice("A1", 3*Yuan+5*Jiao); err != nil {
+		t.Fatal(err)
+	}
+	if err := inv.SetPrice("A1", 0); err == nil {
+		t.Error("价格为0时应拒绝")
+	}
+	if err := inv.SetPrice("G1", 3*Yuan); err == nil {
+		t.Error("无效货道应拒绝定价")
+	}
+	a1, _ := inv.GetSlot("A1")
+	f8, _ := inv.GetSlot("F8")
+	if a1.Price != 3*Yuan+5*Jiao || f8.Price != 4*Yuan+5*Jiao || juice.Price != 4*Yuan {
+		t.Errorf("单独定价不应影响其他货道和产品: A1 %s，F8 %s，产品 %s", a1.Price, f8.Price, juice.Price)
+	}
+}
@@ -3,34 +3,54 @@ package main
 import (
 	"fmt"
 	"sync"
+	"time"
 )
 
 // VendingMachineDemo 演示自动售货机的使用
 func main() {
-	fmt.Println("===== 自动售货机演示 =====\n")
+	fmt.Println("===== 自动售货机演示 =====")
+	fmt.Println()
 
-	// 获取售货机单例
-	vm := GetVendingMachine()
+	// 创建售货机
+	vm := NewVendingMachine("VM-001")
 
 	// 创建产品
-	cola := NewProduct("可乐", 3.50)
-	chips := NewProduct("薯片", 5.00)
-	water := NewProduct("矿泉水", 2.00)
-	chocolate := NewProduct("巧克力", 8.00)
+	cola := NewProduct("SKU-COLA-330", "可乐", 3*Yuan+5*Jiao)
+	chips := NewProduct("SKU-CHIPS-70", "薯片", 5*Yuan)
+	water := NewProduct("SKU-WATER-550", "矿泉水", 2*Yuan)
+	chocolate := NewProduct("SKU-CHOC-45", "巧克力", 8*Yuan)
+
+	// 将产品放入货道，售价按货道设置，0表示使用建议零售价
+	vm.AssignSlot("A1", cola, 0)
+	vm.AssignSlot("A2", chips, 0)
+	vm.AssignSlot("B1", water, 0)
+	vm.AssignSlot("C1", chocolate, 0)
+	vm.AssignSlot("C2", cola, 4*Yuan) // 同一产品在冷藏货道售价更高
+	vm.SetSlotCapacity("C1", 4)
 
 	// 补充产品库存
 	fmt.Println("--- 补充产品库存 ---")
-	vm.AddProduct(cola, 5)
-	vm.AddProduct(chips, 3)
-	vm.AddProduct(water, 10)
-	vm.AddProduct(chocolate, 2)
+	vm.Restock("A1", 5)
+	vm.Restock("A2", 3)
+	vm.Restock("B1", 5)
+	vm.Restock("C1", 2)
+	vm.Restock("C2", 4)
+	if err := vm.Restock("A1", 20); err != nil {
+		fmt.Printf("错误: %v\n", err)
+	}
+
+	// 装入找零用的硬币
+	fmt.Println("\n--- 装入找零硬币 ---")
+	vm.LoadCoins(Dollar, 10)
+	vm.LoadCoins(HalfDollar, 10)
+	vm.LoadCoins(Quarter, 10)
 
 	// 显示产品列表
 	vm.DisplayProducts()
 
 	// 演示购买流程
 	fmt.Println("\n--- 演示1: 购买可乐 ---")
-	err := vm.SelectProduct(cola)
+	err := vm.SelectProduct("A1")
 	if err != nil {
 		fmt.Printf("错误: %v\n", err)
 	}
@@ -50,11 +70,11 @@ func main() {
 
 	// 返回找零
 	change := vm.ReturnChange()
-	fmt.Printf("交易完成，找零: %.2f元\n", change)
+	fmt.Printf("交易完成，找零: %s\n", change.Total())
 
 	// 演示购买流程2 - 使用纸币
 	fmt.Println("\n--- 演示2: 购买巧克力（使用纸币）---")
-	err = vm.SelectProduct(chocolate)
+	err = vm.SelectProduct("C1")
 	if err != nil {
 		fmt.Printf("错误: %v\n", err)
 	}
@@ -67,11 +87,11 @@ func main() {
 	}
 
 	change = vm.ReturnChange()
-	fmt.Printf("交易完成，找零: %.2f元\n", change)
+	fmt.Printf("交易完成，找零: %s\n", change.Total())
 
 	// 演示购买流程3 - 金额不足
 	fmt.Println("\n--- 演示3: 金额不足 ---")
-	err = vm.SelectProduct(chips)
+	err = vm.SelectProduct("A2")
 	if err != nil {
 		fmt.Printf("错误: %v\n", err)
 	}
@@ -85,13 +105,13 @@ func main() {
 
 	// 取消交易，退还金额
 	change = vm.ReturnChange()
-	fmt.Printf("取消交易，退还: %.2f元\n", change)
+	fmt.Printf("取消交易，退还: %s\n", change.Total())
 
 	// 演示购买流程4 - 产品售罄
 	fmt.Println("\n--- 演示4: 产品售罄 ---")
 	// 先买光巧克力
 	for i := 0; i < 3; i++ {
-		err = vm.SelectProduct(chocolate)
+		err = vm.SelectProduct("C1")
 		if err != nil {
 			fmt.Printf("错误: %v\n", err)
 			break
@@ -107,11 +127,11 @@ func main() {
 	// 取出收集的金钱
 	fmt.Println("\n--- 取出收集的金钱 ---")
 	collected := vm.CollectMoney()
-	fmt.Printf("总收入: %.2f元\n", collected)
+	fmt.Printf("总收入: %s\n", collected)
 
 	// 演示并发购买
 	fmt.Println("\n--- 演示5: 并发购买 ---")
-	vm.AddProduct(water, 20) // 补充库存
+	vm.Restock("B1", 5) // 补充库存
 
 	var wg sync.WaitGroup
 	for i := 0; i < 5; i++ {
@@ -120,7 +140,7 @@ func main() {
 			defer wg.Done()
 
 			// 每个goroutine执行完整的购买流程
-			err := vm.SelectProduct(water)
+			err := vm.SelectProduct("B1")
 			if err != nil {
 				fmt.Printf("[协程%d] 选择产品错误: %v\n", id, err)
 				return
@@ -148,12 +168,142 @@ func main() {
 			}
 
 			change := vm.ReturnChange()
-			fmt.Printf("[协程%d] 购买成功，找零: %.2f元\n", id, change)
+			fmt.Printf("[协程%d] 购买成功，找零: %s\n", id, change.Total())
 		}(i)
 	}
 
 	wg.Wait()
 
+	// 演示无法找零时拒收大面额纸币
+	fmt.Println("\n--- 演示6: 无法找零 ---")
+	vm.SelectProduct("B1")
+	if err := vm.InsertNote(Hundred); err != nil {
+		fmt.Printf("错误: %v\n", err)
+	}
+	vm.InsertNote(Five)
+	vm.DispenseProduct()
+	change = vm.ReturnChange()
+	fmt.Printf("交易完成，找零: %s\n", change.Total())
+
+	// 演示非现金支付
+	fmt.Println("\n--- 演示7: 非现金支付 ---")
+	gateway := NewFakeGateway()
+	gateway.AddAccount("CARD-6222", 50*Yuan)
+	gateway.AddAccount("QR-EMPTY", 1*Yuan)
+	vm.SetPaymentProvider(gateway)
+	vm.SetAuthTimeout(100 * time.Millisecond)
+
+	// 刷卡购买，发放时扣款
+	vm.SelectProduct("A2")
+	if err := vm.PayCashless("CARD-6222"); err != nil {
+		fmt.Printf("错误: %v\n", err)
+	}
+	vm.DispenseProduct()
+	vm.ReturnChange()
+
+	// 现金加扫码：余额不足时授权失败，改用刷卡支付剩余金额
+	vm.SelectProduct("C2")
+	vm.InsertCoin(Dollar)
+	if err := vm.PayCashless("QR-EMPTY"); err != nil {
+		fmt.Printf("错误: %v\n", err)
+	}
+	vm.PayCashless("CARD-6222")
+	vm.DispenseProduct()
+	vm.ReturnChange()
+
+	// 网关响应超时，授权失败后取消交易
+	gateway.SetDelay(time.Second)
+	vm.SelectProduct("A1")
+	if err := vm.PayCashless("CARD-6222"); err != nil {
+		fmt.Printf("错误: %v\n", err)
+	}
+	vm.ReturnChange()
+	gateway.SetDelay(0)
+
+	// 授权后取消交易，撤销授权
+	vm.SelectProduct("A1")
+	vm.PayCashless("CARD-6222")
+	vm.ReturnChange()
+	fmt.Printf("卡内余额: %s, 非现金收入: %s\n", gateway.GetBalance("CARD-6222"), vm.GetCashlessSales())
+
+	// 演示出货故障处理
+	fmt.Println("\n--- 演示8: 出货故障 ---")
+	driver := NewSimulatedDriver()
+	vm.SetHardware(driver, driver)
+
+	// 电机卡住一次，重试后出货成功
+	driver.InjectJam("B1", 1)
+	vm.SelectProduct("B1")
+	vm.InsertNote(Five)
+	if err := vm.DispenseProduct(); err != nil {
+		fmt.Printf("错误: %v\n", err)
+	}
+	vm.ReturnChange()
+
+	// 两次都没有检测到产品落下，退款
+	driver.InjectMissedDrop("A1", 2)
+	vm.SelectProduct("A1")
+	vm.InsertNote(Five)
+	if err := vm.DispenseProduct(); err != nil {
+		fmt.Printf("错误: %v\n", err)
+	}
+	vm.ReturnChange()
+
+	// 连续故障后暂停服务，维护后恢复
+	driver.InjectJam("A2", 4)
+	for i := 0; i < 2; i++ {
+		vm.SelectProduct("A2")
+		vm.InsertNote(Five)
+		if err := vm.DispenseProduct(); err != nil {
+			fmt.Printf("错误: %v\n", err)
+		}
+		vm.ReturnChange()
+	}
+	if err := vm.SelectProduct("B1"); err != nil {
+		fmt.Printf("错误: %v\n", err)
+	}
+	fmt.Printf("故障记录: %d 条\n", len(vm.GetFaults()))
+	vm.EnterMaintenance()
+	vm.ExitMaintenance()
+	vm.SelectProduct("A2")
+	vm.InsertNote(Five)
+	vm.DispenseProduct()
+	vm.ReturnChange()
+
+	// 演示多台售货机的车队管理
+	fmt.Println("\n--- 演示9: 车队管理 ---")
+	office := NewVendingMachine("VM-002")
+	office.AssignSlot("A1", water, 0)
+	office.AssignSlot("A2", cola, 0)
+	office.Restock("A1", 10)
+	office.Restock("A2", 2)
+	office.SelectProduct("A2")
+	office.InsertCoin(Dollar)
+	office.InsertCoin(Dollar)
+	office.InsertCoin(Dollar)
+	office.InsertCoin(HalfDollar)
+	office.DispenseProduct()
+	office.ReturnChange()
+
+	fleet := NewFleet()
+	fleet.AddMachine(vm)
+	fleet.AddMachine(office)
+	route := fleet.Collect()
+	totals := fleet.SalesByMachine()
+	for _, id := range []string{vm.ID(), office.ID()} {
+		fmt.Printf("售货机 %s 已收集的销售额: %s\n", id, totals[id])
+	}
+	fmt.Printf("故障记录: %d 条\n", len(fleet.GetFaults()))
+	if route != nil {
+		fmt.Printf("补货路线 %s:\n", route.ID)
+		for i, stop := range route.Stops {
+			fmt.Printf("  %d. %s\n", i+1, stop.MachineID)
+			for _, item := range stop.Items {
+				fmt.Printf("     %s %s 补 %d 件\n", item.SlotCode, item.Name, item.Quantity)
+			}
+		}
+	}
+
 	// 显示最终库存
 	fmt.Println("\n--- 最终库存 ---")
 	vm.DisplayProducts()
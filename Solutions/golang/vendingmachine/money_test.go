@@ -0,0 +1,138 @@
+package main
+
+import "testing"
+
+// forEachCombination 枚举每种硬币 0..maxCount 枚的所有组合
+func forEachCombination(maxCount int, fn func(counts map[Coin]int)) {
+	counts := make(map[Coin]int, len(allCoins))
+	var walk func(i int)
+	walk = func(i int) {
+		if i == len(allCoins) {
+			fn(counts)
+			return
+		}
+		for n := 0; n <= maxCount; n++ {
+			counts[allCoins[i]] = n
+			walk(i + 1)
+		}
+	}
+	walk(0)
+}
+
+func TestMoneyString(t *testing.T) {
+	tests := []struct {
+		money Money
+		want  string
+	}{
+		{0, "0.00元"},
+		{5 * Fen, "0.05元"},
+		{3*Yuan + 5*Jiao, "3.50元"},
+		{100 * Yuan, "100.00元"},
+		{-(2*Yuan + 1*Fen), "-2.01元"},
+	}
+	for _, tt := range tests {
+		if got := tt.money.String(); got != tt.want {
+			t.Errorf("Money(%d).String() = %q，期望 %q", int64(tt.money), got, tt.want)
+		}
+	}
+}
+
+// 测试所有硬币组合的金额都精确累加，不会出现 0.1+0.2 式的误差
+func TestCoinCombinationTotals(t *testing.T) {
+	forEachCombination(3, func(counts map[Coin]int) {
+		change := NewChange()
+		var want int64
+		for coin, n := range counts {
+			for i := 0; i < n; i++ {
+				change.addCoin(coin, 1)
+				want += int64(coin)
+			}
+		}
+		if change.Total() != Money(want) {
+			t.Fatalf("%v 合计 %s，期望 %d分", counts, change.Total(), want)
+		}
+	})
+}
+
+// 测试每种硬币3枚的钱箱对所有可凑出的金额都找出最少硬币的方案
+func TestMakeChangeAllCombinations(t *testing.T) {
+	box := NewCashBox()
+	for _, coin := range allCoins {
+		box.AddCoins(coin, 3)
+	}
+
+	// 穷举所有组合得到每个金额的最少硬币数
+	fewest := make(map[Money]int)
+	forEachCombination(3, func(counts map[Coin]int) {
+		var total Money
+		coins := 0
+		for coin, n := range counts {
+			total += coin.Value() * Money(n)
+			coins += n
+		}
+		if best, ok := fewest[total]; !ok || coins < best {
+			fewest[total] = coins
+		}
+	})
+
+	for amount := Money(0); amount <= box.CoinTotal(); amount++ {
+		change, ok := box.MakeChange(amount, NewChange())
+		best, reachable := fewest[amount]
+		if ok != reachable {
+			t.Fatalf("找零 %s: 结果 %v，期望 %v", amount, ok, reachable)
+		}
+		if !ok {
+			continue
+		}
+		coins := 0
+		for coin, n := range change.Coins {
+			if n > box.CoinCount(coin) {
+				t.Fatalf("找零 %s 使用 %d 枚 %s 硬币，钱箱只有 %d 枚", amount, n, coin.Value(), box.CoinCount(coin))
+			}
+			coins += n
+		}
+		if change.Total() != amount || coins != best {
+			t.Fatalf("找零 %s: 得到 %s 共 %d 枚，期望 %d 枚", amount, change, coins, best)
+		}
+	}
+}
+
+// 测试用任意硬币组合付清同额货款后收入精确、无需找零
+func TestPayWithAllCoinCombinations(t *testing.T) {
+	forEachCombination(2, func(counts map[Coin]int) {
+		var price Money
+		for coin, n := range counts {
+			price += coin.Value() * Money(n)
+		}
+		if price == 0 {
+			return
+		}
+
+		vm := NewVendingMachine("VM-TEST")
+		if err := vm.AssignSlot("A1", NewProduct("SKU-TEST", "测试", price), 0); err != nil {
+			t.Fatal(err)
+		}
+		if err := vm.Restock("A1", 1); err != nil {
+			t.Fatal(err)
+		}
+		if err := vm.SelectProduct("A1"); err != nil {
+			t.Fatal(err)
+		}
+		for _, coin := range allCoins {
+			for i := 0; i < counts[coin]; i++ {
+				if err := vm.InsertCoin(coin); err != nil {
+					t.Fatalf("%v 投入 %s 失败: %v", counts, coin.Value(), err)
+				}
+			}
+		}
+		if err := vm.DispenseProduct(); err != nil {
+			t.Fatalf("%v 发放失败: %v", counts, err)
+		}
+		if change := vm.ReturnChange(); !change.IsEmpty() {
+			t.Fatalf("%v 不应找零，实际找零 %s", counts, change)
+		}
+		if vm.GetCollectedMoney() != price {
+			t.Fatalf("%v 收入 %s，期望 %s", counts, vm.GetCollectedMoney(), price)
+		}
+	})
+}
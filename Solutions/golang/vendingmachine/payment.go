@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DefaultAuthTimeout 非现金支付授权的默认超时时间
+const DefaultAuthTimeout = 30 * time.Second
+
+// PaymentProvider 银行卡/二维码等非现金支付通道
+// 先授权冻结金额，发放产品时扣款，交易失败或取消时撤销授权
+type PaymentProvider interface {
+	// Authorize 冻结 token 对应账户的金额，返回授权号
+	Authorize(ctx context.Context, token string, amount Money) (string, error)
+	// Capture 扣取已授权的金额
+	Capture(authID string) error
+	// Void 撤销尚未扣款的授权，解冻金额
+	Void(authID string) error
+}
+
+// AuthorizationStatus 授权状态
+type AuthorizationStatus int
+
+const (
+	Authorized AuthorizationStatus = iota // 已授权，金额冻结
+	Captured                              // 已扣款
+	Voided                                // 已撤销
+)
+
+func (s AuthorizationStatus) String() string {
+	switch s {
+	case Authorized:
+		return "已授权"
+	case Captured:
+		return "已扣款"
+	case Voided:
+		return "已撤销"
+	default:
+		return "未知"
+	}
+}
+
+// Authorization 一笔非现金支付授权
+type Authorization struct {
+	ID     string
+	Token  string
+	Amount Money
+	Status AuthorizationStatus
+}
+
+// FakeGateway 进程内模拟的支付网关，按 token 维护账户余额
+type FakeGateway struct {
+	balances       map[string]Money
+	authorizations map[string]*Authorization
+	delay          time.Duration
+	seq            int
+	mu             sync.Mutex
+}
+
+// NewFakeGateway 创建模拟支付网关
+func NewFakeGateway() *FakeGateway {
+	return &FakeGateway{
+		balances:       make(map[string]Money),
+		authorizations: make(map[string]*Authorization),
+	}
+}
+
+// AddAccount 添加账户，token 为银行卡号或二维码
+func (g *FakeGateway) AddAccount(token string, balance Money) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.balances[token] = balance
+}
+
+// SetDelay 设置授权的响应延迟，用于模拟网络超时
+func (g *FakeGateway) SetDelay(delay time.Duration) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.delay = delay
+}
+
+// Authorize 冻结金额，超时或取消时不做授权
+func (g *FakeGateway) Authorize(ctx context.Context, token string, amount Money) (string, error) {
+	g.mu.Lock()
+	delay := g.delay
+	g.mu.Unlock()
+
+	select {
+	case <-time.After(delay):
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	balance, ok := g.balances[token]
+	if !ok {
+		return "", fmt.Errorf("无效的支付凭证: %s", token)
+	}
+	if balance < amount {
+		return "", fmt.Errorf("余额不足，可用: %s", balance)
+	}
+	g.balances[token] = balance - amount
+	g.seq++
+	auth := &Authorization{ID: fmt.Sprintf("AUTH-%06d", g.seq), Token: token, Amount: amount, Status: Authorized}
+	g.authorizations[auth.ID] = auth
+	return auth.ID, nil
+}
+
+// Capture 扣取已授权的金额
+func (g *FakeGateway) Capture(authID string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	auth, ok := g.authorizations[authID]
+	if !ok {
+		return fmt.Errorf("授权 %s 不存在", authID)
+	}
+	if auth.Status != Authorized {
+		return fmt.Errorf("授权 %s %s，无法扣款", authID, auth.Status)
+	}
+	auth.Status = Captured
+	return nil
+}
+
+// Void 撤销授权并解冻金额
+func (g *FakeGateway) Void(authID string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	auth, ok := g.authorizations[authID]
+	if !ok {
+		return fmt.Errorf("授权 %s 不存在", authID)
+	}
+	if auth.Status != Authorized {
+		return fmt.Errorf("授权 %s %s，无法撤销", authID, auth.Status)
+	}
+	auth.Status = Voided
+	g.balances[auth.Token] += auth.Amount
+	return nil
+}
+
+// GetAuthorization 查询授权
+func (g *FakeGateway) GetAuthorization(authID string) (Authorization, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	auth, ok := g.authorizations[authID]
+	if !ok {
+		return Authorization{}, false
+	}
+	return *auth, true
+}
+
+// GetBalance 查询账户可用余额
+func (g *FakeGateway) GetBalance(token string) Money {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.balances[token]
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newCashlessMachine 创建接入模拟网关的售货机，放入一件价格为 price 的产品并选中
+func newCashlessMachine(t *testing.T, price Money, balance Money) (*VendingMachine, *FakeGateway) {
+	t.Helper()
+	gateway := NewFakeGateway()
+	gateway.AddAccount("CARD-1", balance)
+	vm := newTestMachine(t, price)
+	vm.SetPaymentProvider(gateway)
+	return vm, gateway
+}
+
+// voidFailingGateway 撤销授权总是失败的网关
+type voidFailingGateway struct {
+	*FakeGateway
+}
+
+func (g voidFailingGateway) Void(authID string) error {
+	return errors.New("网关不可用")
+}
+
+// captureFailingGateway 扣款总是失败的网关
+type captureFailingGateway struct {
+	*FakeGateway
+}
+
+func (g captureFailingGateway) Capture(authID string) error {
+	return errors.New("网关不可用")
+}
+
+// 测试授权超时回到准备状态，不冻结金额
+func TestCashlessAuthorizationTimeout(t *testing.T) {
+	vm, gateway := newCashlessMachine(t, 3*Yuan, 10*Yuan)
+	gateway.SetDelay(200 * time.Millisecond)
+	vm.SetAuthTimeout(10 * time.Millisecond)
+
+	if err := vm.PayCashless("CARD-1"); err == nil || !strings.Contains(err.Error(), "授权超时") {
+		t.Fatalf("期望授权超时，实际: %v", err)
+	}
+	if _, ok := gateway.GetAuthorization("AUTH-000001"); ok {
+		t.Error("超时的授权不应生效")
+	}
+	if got := gateway.GetBalance("CARD-1"); got != 10*Yuan {
+		t.Errorf("余额应为 10.00元，实际: %s", got)
+	}
+
+	// 回到准备状态后可以改用现金
+	vm.InsertNote(One)
+	vm.InsertNote(One)
+	if err := vm.InsertNote(One); err != nil {
+		t.Fatal(err)
+	}
+	if err := vm.DispenseProduct(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// 测试余额不足时授权失败，可以重新支付
+func TestCashlessInsufficientBalance(t *testing.T) {
+	vm, gateway := newCashlessMachine(t, 3*Yuan, 2*Yuan)
+
+	if err := vm.PayCashless("CARD-1"); err == nil {
+		t.Fatal("余额不足时授权应失败")
+	}
+	if got := gateway.GetBalance("CARD-1"); got != 2*Yuan {
+		t.Errorf("余额应为 2.00元，实际: %s", got)
+	}
+	if err := vm.PayCashless("CARD-404"); err == nil {
+		t.Fatal("无效的支付凭证应失败")
+	}
+	gateway.AddAccount("CARD-2", 5*Yuan)
+	if err := vm.PayCashless("CARD-2"); err != nil {
+		t.Fatal(err)
+	}
+	if got := gateway.GetBalance("CARD-2"); got != 2*Yuan {
+		t.Errorf("授权后可用余额应为 2.00元，实际: %s", got)
+	}
+}
+
+// 测试产品落下后才扣款
+func TestCashlessCaptureOnDispense(t *testing.T) {
+	vm, gateway := newCashlessMachine(t, 3*Yuan, 10*Yuan)
+
+	if err := vm.PayCashless("CARD-1"); err != nil {
+		t.Fatal(err)
+	}
+	auth, ok := gateway.GetAuthorization("AUTH-000001")
+	if !ok || auth.Status != Authorized || auth.Amount != 3*Yuan || gateway.GetBalance("CARD-1") != 7*Yuan {
+		t.Fatalf("授权错误: %+v", auth)
+	}
+	if err := vm.DispenseProduct(); err != nil {
+		t.Fatal(err)
+	}
+	if auth, _ := gateway.GetAuthorization("AUTH-000001"); auth.Status != Captured {
+		t.Errorf("发放后应已扣款，实际: %s", auth.Status)
+	}
+	if change := vm.ReturnChange(); !change.IsEmpty() {
+		t.Errorf("非现金支付不应找零，实际: %s", change)
+	}
+	sales := vm.GetSales()
+	if vm.GetCashlessSales() != 3*Yuan || vm.GetCollectedMoney() != 0 || len(sales) != 1 || sales[0].Cashless != 3*Yuan {
+		t.Errorf("收入统计错误: 非现金 %s，现金 %s，销售 %+v", vm.GetCashlessSales(), vm.GetCollectedMoney(), sales)
+	}
+}
+
+// 测试取消交易或出货失败时撤销授权并解冻金额
+func TestCashlessVoid(t *testing.T) {
+	tests := []struct {
+		name   string
+		finish func(vm *VendingMachine, driver *SimulatedDriver) error
+	}{
+		{"取消交易", func(vm *VendingMachine, driver *SimulatedDriver) error {
+			vm.ReturnChange()
+			return nil
+		}},
+		{"出货失败", func(vm *VendingMachine, driver *SimulatedDriver) error {
+			driver.InjectJam("A1", DispenseAttempts)
+			return vm.DispenseProduct()
+		}},
+	}
+	for _, tt := range tests {
+		vm, gateway := newCashlessMachine(t, 3*Yuan, 10*Yuan)
+		driver := NewSimulatedDriver()
+		vm.SetHardware(driver, driver)
+		if err := vm.PayCashless("CARD-1"); err != nil {
+			t.Fatal(err)
+		}
+		tt.finish(vm, driver)
+		if auth, _ := gateway.GetAuthorization("AUTH-000001"); auth.Status != Voided {
+			t.Errorf("%s: 授权应已撤销，实际: %s", tt.name, auth.Status)
+		}
+		if got := gateway.GetBalance("CARD-1"); got != 10*Yuan {
+			t.Errorf("%s: 余额应恢复为 10.00元，实际: %s", tt.name, got)
+		}
+		if vm.GetCashlessSales() != 0 || len(vm.GetSales()) != 0 {
+			t.Errorf("%s: 不应计入收入", tt.name)
+		}
+	}
+}
+
+// 测试现金不足部分由非现金支付，现金进入钱箱且不找零
+func TestMixedCashAndCashless(t *testing.T) {
+	vm, gateway := newCashlessMachine(t, 3*Yuan+5*Jiao, 10*Yuan)
+	vm.LoadCoins(Quarter, 20)
+
+	vm.InsertCoin(Dollar)
+	vm.InsertCoin(HalfDollar)
+	if err := vm.PayCashless("CARD-1"); err != nil {
+		t.Fatal(err)
+	}
+	if auth, _ := gateway.GetAuthorization("AUTH-000001"); auth.Amount != 2*Yuan {
+		t.Errorf("应只授权现金未覆盖的 2.00元，实际: %s", auth.Amount)
+	}
+	if err := vm.DispenseProduct(); err != nil {
+		t.Fatal(err)
+	}
+	if change := vm.ReturnChange(); !change.IsEmpty() {
+		t.Errorf("混合支付不应找零，实际: %s", change)
+	}
+	box := vm.GetCashBox()
+	if box.CoinCount(Dollar) != 1 || box.CoinCount(HalfDollar) != 1 || box.CoinCount(Quarter) != 20 {
+		t.Errorf("投入的现金应进入钱箱: 1元 %d 枚，5角 %d 枚", box.CoinCount(Dollar), box.CoinCount(HalfDollar))
+	}
+	if vm.GetCollectedMoney() != 1*Yuan+5*Jiao || vm.GetCashlessSales() != 2*Yuan || gateway.GetBalance("CARD-1") != 8*Yuan {
+		t.Errorf("收入错误: 现金 %s，非现金 %s", vm.GetCollectedMoney(), vm.GetCashlessSales())
+	}
+
+	// 取消混合支付时退还现金并撤销授权
+	vm.Restock("A1", 1)
+	vm.SelectProduct("A1")
+	vm.InsertCoin(Dollar)
+	if err := vm.PayCashless("CARD-1"); err != nil {
+		t.Fatal(err)
+	}
+	refund := vm.ReturnChange()
+	if refund.Total() != 1*Yuan || refund.Coins[Dollar] != 1 {
+		t.Errorf("应退还投入的 1元，实际: %s", refund)
+	}
+	if auth, _ := gateway.GetAuthorization("AUTH-000002"); auth.Status != Voided || gateway.GetBalance("CARD-1") != 8*Yuan {
+		t.Errorf("授权应已撤销: %+v", auth)
+	}
+}
+
+// 测试撤销授权失败记为故障并出现在车队遥测中，但不计入连续出货故障
+func TestVoidFailureRecordedAsFault(t *testing.T) {
+	gateway := NewFakeGateway()
+	gateway.AddAccount("CARD-1", 10*Yuan)
+	vm := newTestMachine(t, 3*Yuan)
+	vm.SetPaymentProvider(voidFailingGateway{gateway})
+	fleet := NewFleet()
+	if err := fleet.AddMachine(vm); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := vm.PayCashless("CARD-1"); err != nil {
+		t.Fatal(err)
+	}
+	vm.ReturnChange()
+	faults := vm.GetFaults()
+	if len(faults) != 1 || faults[0].SlotCode != "A1" || !strings.Contains(faults[0].Reason, "AUTH-000001") {
+		t.Fatalf("撤销失败应记为故障: %+v", faults)
+	}
+	if vm.IsOutOfService() || vm.consecutiveFaults != 0 {
+		t.Error("撤销失败不应计入连续出货故障")
+	}
+	fleet.Collect()
+	if got := fleet.GetFaults(); len(got) != 1 || got[0].MachineID != "VM-TEST" {
+		t.Errorf("车队应收集到撤销失败的故障: %+v", got)
+	}
+}
+
+// 测试扣款失败记为故障，金额不计入非现金收入，销售记录标记为待对账
+func TestCaptureFailureNotCountedAsRevenue(t *testing.T) {
+	gateway := NewFakeGateway()
+	gateway.AddAccount("CARD-1", 10*Yuan)
+	vm := newTestMachine(t, 3*Yuan)
+	vm.SetPaymentProvider(captureFailingGateway{gateway})
+
+	vm.InsertCoin(Dollar)
+	if err := vm.PayCashless("CARD-1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := vm.DispenseProduct(); err != nil {
+		t.Fatal(err)
+	}
+	vm.ReturnChange()
+	faults := vm.GetFaults()
+	if len(faults) != 1 || faults[0].SlotCode != "A1" || !strings.Contains(faults[0].Reason, "AUTH-000001") {
+		t.Fatalf("扣款失败应记为故障: %+v", faults)
+	}
+	if vm.IsOutOfService() || vm.consecutiveFaults != 0 {
+		t.Error("扣款失败不应计入连续出货故障")
+	}
+	sales := vm.GetSales()
+	if vm.GetCashlessSales() != 0 || vm.GetCollectedMoney() != 1*Yuan || len(sales) != 1 {
+		t.Fatalf("扣款失败的金额不应计入收入: 非现金 %s，现金 %s", vm.GetCashlessSales(), vm.GetCollectedMoney())
+	}
+	if sales[0].Price != 1*Yuan || sales[0].Cashless != 0 || sales[0].Uncaptured != 2*Yuan {
+		t.Errorf("销售记录应标记待对账的金额: %+v", sales[0])
+	}
+}
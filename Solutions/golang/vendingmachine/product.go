@@ -1,14 +1,16 @@
 package main
 
-// Product 代表售货机中的一个产品
+// Product 代表售货机中的一个产品，以SKU唯一标识
 type Product struct {
+	SKU   string
 	Name  string
-	Price float64
+	Price Money // 建议零售价，货道可以单独定价
 }
 
 // NewProduct 创建一个新产品
-func NewProduct(name string, price float64) *Product {
+func NewProduct(sku, name string, price Money) *Product {
 	return &Product{
+		SKU:   sku,
 		Name:  name,
 		Price: price,
 	}
@@ -1,19 +1,24 @@
 package main
 
-import "fmt"
+import (
+	"fmt"
+	"time"
+)
 
 // VendingMachineState 定义售货机在不同状态下的行为接口
 type VendingMachineState interface {
-	// SelectProduct 选择产品
-	SelectProduct(product *Product) error
+	// SelectProduct 按货道编号选择产品
+	SelectProduct(slotCode string) error
 	// InsertCoin 投入硬币
 	InsertCoin(coin Coin) error
 	// InsertNote 投入纸币
 	InsertNote(note Note) error
+	// PayCashless 使用银行卡或二维码支付尚未付清的金额
+	PayCashless(token string) error
 	// DispenseProduct 发放产品
 	DispenseProduct() error
-	// ReturnChange 返回找零
-	ReturnChange() float64
+	// ReturnChange 返回找零，交易取消时退还投入的钱
+	ReturnChange() Change
 }
 
 // IdleState 空闲状态 - 等待用户选择产品
@@ -25,17 +30,21 @@ func NewIdleState(vm *VendingMachine) *IdleState {
 	return &IdleState{vendingMachine: vm}
 }
 
-func (s *IdleState) SelectProduct(product *Product) error {
+func (s *IdleState) SelectProduct(slotCode string) error {
 	vm := s.vendingMachine
 
-	// 检查产品是否有库存
-	if !vm.inventory.IsAvailable(product) {
-		return fmt.Errorf("产品 %s 已售罄", product.Name)
+	// 检查货道是否有货
+	slot, err := vm.availableSlot(slotCode)
+	if err != nil {
+		return err
 	}
 
-	vm.selectedProduct = product
+	vm.selectedSlot = slot
 	vm.SetState(vm.readyState)
-	fmt.Printf("已选择产品: %s, 价格: %.2f元\n", product.Name, product.Price)
+	fmt.Printf("已选择产品: %s %s, 价格: %s\n", slot.Code, slot.Product.Name, slot.Price)
+	if vm.exactChangeOnly() {
+		fmt.Println("零钱不足，请投入准确金额")
+	}
 	return nil
 }
 
@@ -47,13 +56,17 @@ func (s *IdleState) InsertNote(note Note) error {
 	return fmt.Errorf("请先选择产品")
 }
 
+func (s *IdleState) PayCashless(token string) error {
+	return fmt.Errorf("请先选择产品")
+}
+
 func (s *IdleState) DispenseProduct() error {
 	return fmt.Errorf("请先选择产品")
 }
 
-func (s *IdleState) ReturnChange() float64 {
+func (s *IdleState) ReturnChange() Change {
 	// 空闲状态没有找零
-	return 0
+	return NewChange()
 }
 
 // ReadyState 准备状态 - 等待用户投币
@@ -65,59 +78,161 @@ func NewReadyState(vm *VendingMachine) *ReadyState {
 	return &ReadyState{vendingMachine: vm}
 }
 
-func (s *ReadyState) SelectProduct(product *Product) error {
+func (s *ReadyState) SelectProduct(slotCode string) error {
 	// 允许重新选择产品
 	vm := s.vendingMachine
 
-	if !vm.inventory.IsAvailable(product) {
-		return fmt.Errorf("产品 %s 已售罄", product.Name)
+	slot, err := vm.availableSlot(slotCode)
+	if err != nil {
+		return err
 	}
 
-	vm.selectedProduct = product
-	fmt.Printf("已重新选择产品: %s, 价格: %.2f元\n", product.Name, product.Price)
-	return nil
+	vm.selectedSlot = slot
+	fmt.Printf("已重新选择产品: %s %s, 价格: %s\n", slot.Code, slot.Product.Name, slot.Price)
+	// 新产品更便宜时已投入的金额可能已经足够
+	return s.checkPaymentSufficient()
 }
 
 func (s *ReadyState) InsertCoin(coin Coin) error {
 	vm := s.vendingMachine
-	vm.totalPayment += coin.Value()
-	fmt.Printf("投入硬币: %.2f元, 当前已投入: %.2f元\n", coin.Value(), vm.totalPayment)
-
-	// 检查是否已支付足够金额
-	s.checkPaymentSufficient()
+	vm.escrow.addCoin(coin, 1)
+	vm.totalPayment = vm.escrow.Total()
+
+	// 检查是否已支付足够金额，无法找零时退回这枚硬币
+	if err := s.checkPaymentSufficient(); err != nil {
+		vm.escrow.addCoin(coin, -1)
+		vm.totalPayment = vm.escrow.Total()
+		fmt.Printf("退回硬币: %s\n", coin.Value())
+		return err
+	}
+	fmt.Printf("投入硬币: %s, 当前已投入: %s\n", coin.Value(), vm.totalPayment)
 	return nil
 }
 
 func (s *ReadyState) InsertNote(note Note) error {
 	vm := s.vendingMachine
-	vm.totalPayment += note.Value()
-	fmt.Printf("投入纸币: %.2f元, 当前已投入: %.2f元\n", note.Value(), vm.totalPayment)
+	vm.escrow.addNote(note, 1)
+	vm.totalPayment = vm.escrow.Total()
+
+	// 检查是否已支付足够金额，无法找零时退回这张纸币
+	if err := s.checkPaymentSufficient(); err != nil {
+		vm.escrow.addNote(note, -1)
+		vm.totalPayment = vm.escrow.Total()
+		fmt.Printf("退回纸币: %s\n", note.Value())
+		return err
+	}
+	fmt.Printf("投入纸币: %s, 当前已投入: %s\n", note.Value(), vm.totalPayment)
+	return nil
+}
 
-	// 检查是否已支付足够金额
-	s.checkPaymentSufficient()
+// PayCashless 已投入的现金之外的金额由非现金支付，进入授权状态
+func (s *ReadyState) PayCashless(token string) error {
+	vm := s.vendingMachine
+	if vm.paymentProvider == nil {
+		return fmt.Errorf("本机不支持非现金支付")
+	}
+	vm.cashlessAmount = vm.selectedSlot.Price - vm.escrow.Total()
+	vm.SetState(vm.authorizingState)
+	fmt.Printf("正在授权非现金支付: %s\n", vm.cashlessAmount)
 	return nil
 }
 
-func (s *ReadyState) checkPaymentSufficient() {
+// checkPaymentSufficient 金额足够时确定找零方案并进入发放状态
+// 零钱不足时只收准确金额，钱箱凑不出找零时拒绝这笔付款
+func (s *ReadyState) checkPaymentSufficient() error {
 	vm := s.vendingMachine
-	if vm.totalPayment >= vm.selectedProduct.Price {
-		vm.SetState(vm.dispenseState)
+	price := vm.selectedSlot.Price
+	paid := vm.escrow.Total()
+	if paid < price {
+		return nil
 	}
+	vm.pendingChange = NewChange()
+	if paid > price {
+		if vm.exactChangeOnly() {
+			return fmt.Errorf("零钱不足，请投入准确金额")
+		}
+		change, ok := vm.cashBox.MakeChange(paid-price, vm.escrow)
+		if !ok {
+			return fmt.Errorf("无法找零 %s，请投入较小面额", paid-price)
+		}
+		vm.pendingChange = change
+	}
+	vm.SetState(vm.dispenseState)
+	return nil
 }
 
 func (s *ReadyState) DispenseProduct() error {
-	return fmt.Errorf("金额不足，还需支付: %.2f元",
-		s.vendingMachine.selectedProduct.Price-s.vendingMachine.totalPayment)
+	return fmt.Errorf("金额不足，还需支付: %s",
+		s.vendingMachine.selectedSlot.Price-s.vendingMachine.totalPayment)
 }
 
-func (s *ReadyState) ReturnChange() float64 {
+func (s *ReadyState) ReturnChange() Change {
 	vm := s.vendingMachine
-	change := vm.totalPayment
+	// 退还投入的原币
+	refund := vm.escrow
+	vm.escrow = NewChange()
 	vm.totalPayment = 0
-	vm.selectedProduct = nil
+	vm.cashlessAmount = 0
+	vm.selectedSlot = nil
 	vm.SetState(vm.idleState)
-	fmt.Printf("取消交易，退还金额: %.2f元\n", change)
-	return change
+	fmt.Printf("取消交易，退还金额: %s (%s)\n", refund.Total(), refund)
+	return refund
+}
+
+// AuthorizingState 授权状态 - 等待支付通道授权非现金支付
+// 授权期间售货机不持锁，其他操作都会被拒绝
+type AuthorizingState struct {
+	vendingMachine *VendingMachine
+}
+
+func NewAuthorizingState(vm *VendingMachine) *AuthorizingState {
+	return &AuthorizingState{vendingMachine: vm}
+}
+
+func (s *AuthorizingState) SelectProduct(slotCode string) error {
+	return fmt.Errorf("正在授权支付，请稍候")
+}
+
+func (s *AuthorizingState) InsertCoin(coin Coin) error {
+	return fmt.Errorf("正在授权支付，请稍候")
+}
+
+func (s *AuthorizingState) InsertNote(note Note) error {
+	return fmt.Errorf("正在授权支付，请稍候")
+}
+
+func (s *AuthorizingState) PayCashless(token string) error {
+	return fmt.Errorf("正在授权支付，请稍候")
+}
+
+func (s *AuthorizingState) DispenseProduct() error {
+	return fmt.Errorf("正在授权支付，请稍候")
+}
+
+func (s *AuthorizingState) ReturnChange() Change {
+	// 授权结束前不能取消，授权结果返回后再退款
+	fmt.Println("正在授权支付，请稍候")
+	return NewChange()
+}
+
+// complete 处理授权结果：成功进入发放状态，失败撤销可能已完成的授权并回到准备状态
+func (s *AuthorizingState) complete(authID string, err error) error {
+	vm := s.vendingMachine
+	if err != nil {
+		if authID != "" {
+			vm.void(authID)
+		}
+		vm.cashlessAmount = 0
+		vm.SetState(vm.readyState)
+		fmt.Printf("授权失败: %v\n", err)
+		return fmt.Errorf("非现金支付失败: %w", err)
+	}
+
+	vm.authID = authID
+	vm.pendingChange = NewChange()
+	vm.SetState(vm.dispenseState)
+	fmt.Printf("授权成功: %s, 金额: %s\n", authID, vm.cashlessAmount)
+	return nil
 }
 
 // DispenseState 发放状态 - 准备发放产品和找零
@@ -129,7 +244,7 @@ func NewDispenseState(vm *VendingMachine) *DispenseState {
 	return &DispenseState{vendingMachine: vm}
 }
 
-func (s *DispenseState) SelectProduct(product *Product) error {
+func (s *DispenseState) SelectProduct(slotCode string) error {
 	return fmt.Errorf("正在处理当前交易，请稍候")
 }
 
@@ -141,35 +256,194 @@ func (s *DispenseState) InsertNote(note Note) error {
 	return fmt.Errorf("正在处理当前交易，请稍候")
 }
 
+func (s *DispenseState) PayCashless(token string) error {
+	return fmt.Errorf("正在处理当前交易，请稍候")
+}
+
 func (s *DispenseState) DispenseProduct() error {
 	vm := s.vendingMachine
+	if vm.dispensed {
+		return fmt.Errorf("产品已发放，请取回找零")
+	}
+	code := vm.selectedSlot.Code
 
-	// 从库存中移除产品
-	err := vm.inventory.RemoveProduct(vm.selectedProduct)
-	if err != nil {
+	// 货道已空时退款，不再收取货款
+	if !vm.inventory.IsAvailable(code) {
+		vm.refundTransaction()
 		vm.SetState(vm.idleState)
-		return err
+		return fmt.Errorf("货道 %s 已售罄，请取回退款", code)
+	}
+
+	// 出货失败重试一次，仍失败则记录故障并退款
+	var err error
+	unconfirmed := 0 // 电机转动但未检测到掉落的次数
+	for attempt := 1; attempt <= DispenseAttempts; attempt++ {
+		var rotated bool
+		if rotated, err = vm.vend(code); err == nil {
+			break
+		}
+		if rotated {
+			unconfirmed++
+		}
+		fmt.Printf("出货失败(第%d次): %v\n", attempt, err)
+	}
+	if err != nil {
+		vm.recordFault(code, err)
+		vm.refundTransaction()
+		if vm.consecutiveFaults >= MaxConsecutiveFaults {
+			vm.SetState(vm.outOfServiceState)
+			fmt.Printf("连续 %d 次出货故障，暂停服务\n", vm.consecutiveFaults)
+		} else {
+			vm.SetState(vm.idleState)
+		}
+		return fmt.Errorf("货道 %s 出货失败，请取回退款: %w", code, err)
+	}
+	vm.consecutiveFaults = 0
+	vm.dispensed = true
+	if unconfirmed > 0 {
+		// 未检测到掉落的那次转动可能已送出产品，库存只扣减了一件
+		vm.addFault(fmt.Errorf("货道 %s 电机转动 %d 次只扣减 1 件库存，请核对库存", code, unconfirmed+1))
 	}
 
-	fmt.Printf("发放产品: %s\n", vm.selectedProduct.Name)
+	if err := vm.inventory.RemoveProduct(code); err != nil {
+		// 产品已经落下，库存记录与实际不符
+		fmt.Printf("库存扣减失败: %v\n", err)
+	}
+	fmt.Printf("发放产品: %s %s\n", code, vm.selectedSlot.Product.Name)
+
+	// 产品落下后扣取非现金支付，扣款失败时保留授权待对账，金额不计入收入
+	if vm.authID != "" {
+		if err := vm.paymentProvider.Capture(vm.authID); err != nil {
+			vm.captureFailed = true
+			vm.addFault(fmt.Errorf("扣款失败，授权 %s 保留待对账: %w", vm.authID, err))
+			fmt.Printf("扣款失败，授权 %s 保留待对账: %v\n", vm.authID, err)
+		}
+	}
 	return nil
 }
 
-func (s *DispenseState) ReturnChange() float64 {
+func (s *DispenseState) ReturnChange() Change {
 	vm := s.vendingMachine
-	change := vm.totalPayment - vm.selectedProduct.Price
 
-	if change > 0 {
-		fmt.Printf("找零: %.2f元\n", change)
+	// 产品尚未发放时取消交易，撤销授权并退还投入的钱
+	if !vm.dispensed {
+		vm.voidAuthorization()
+		return vm.readyState.ReturnChange()
+	}
+
+	// 投入的钱进入钱箱，再按进入发放状态时确定的方案找零
+	change := vm.pendingChange
+	cash := vm.selectedSlot.Price - vm.cashlessAmount
+	if err := vm.cashBox.Exchange(vm.escrow, change); err != nil {
+		// 钱箱可能在交易期间被取走硬币，按现有硬币重新凑找零
+		recomputed, ok := vm.cashBox.MakeChange(change.Total(), vm.escrow)
+		if ok && vm.cashBox.Exchange(vm.escrow, recomputed) == nil {
+			change = recomputed
+		} else {
+			// 仍凑不出时原样退还投入的钱，现金货款不计收入，记录故障待对账
+			vm.addFault(fmt.Errorf("无法找零 %s，已退还投入的 %s: %w", change.Total(), vm.escrow.Total(), err))
+			fmt.Printf("无法找零，退还投入的金额: %s (%s)\n", vm.escrow.Total(), vm.escrow)
+			change, cash = vm.escrow, 0
+		}
+	}
+	if !change.IsEmpty() {
+		fmt.Printf("找零: %s (%s)\n", change.Total(), change)
 	}
 
-	// 更新收入
-	vm.collectedMoney += vm.selectedProduct.Price
+	// 更新收入，非现金部分单独统计；销售记录的金额为实际收到的货款，扣款失败的金额待对账
+	captured, uncaptured := vm.cashlessAmount, Money(0)
+	if vm.captureFailed {
+		captured, uncaptured = 0, vm.cashlessAmount
+	}
+	vm.collectedMoney += cash
+	vm.cashlessSales += captured
+	vm.sales = append(vm.sales, Sale{
+		MachineID:  vm.id,
+		Time:       time.Now(),
+		SlotCode:   vm.selectedSlot.Code,
+		SKU:        vm.selectedSlot.Product.SKU,
+		Price:      cash + captured,
+		Cashless:   captured,
+		Uncaptured: uncaptured,
+	})
 
 	// 重置状态
+	vm.escrow = NewChange()
+	vm.pendingChange = NewChange()
 	vm.totalPayment = 0
-	vm.selectedProduct = nil
+	vm.cashlessAmount = 0
+	vm.authID = ""
+	vm.captureFailed = false
+	vm.dispensed = false
+	vm.selectedSlot = nil
 	vm.SetState(vm.idleState)
 
 	return change
 }
+
+// OutOfServiceState 暂停服务状态 - 连续出货故障后拒绝交易，需进入维护模式处理
+type OutOfServiceState struct {
+	vendingMachine *VendingMachine
+}
+
+func NewOutOfServiceState(vm *VendingMachine) *OutOfServiceState {
+	return &OutOfServiceState{vendingMachine: vm}
+}
+
+func (s *OutOfServiceState) SelectProduct(slotCode string) error {
+	return fmt.Errorf("售货机故障，暂停服务")
+}
+
+func (s *OutOfServiceState) InsertCoin(coin Coin) error {
+	return fmt.Errorf("售货机故障，暂停服务")
+}
+
+func (s *OutOfServiceState) InsertNote(note Note) error {
+	return fmt.Errorf("售货机故障，暂停服务")
+}
+
+func (s *OutOfServiceState) PayCashless(token string) error {
+	return fmt.Errorf("售货机故障，暂停服务")
+}
+
+func (s *OutOfServiceState) DispenseProduct() error {
+	return fmt.Errorf("售货机故障，暂停服务")
+}
+
+func (s *OutOfServiceState) ReturnChange() Change {
+	// 出货失败的退款由售货机统一退还
+	return NewChange()
+}
+
+// MaintenanceState 维护状态 - 维修人员处理故障和补货期间拒绝交易
+type MaintenanceState struct {
+	vendingMachine *VendingMachine
+}
+
+func NewMaintenanceState(vm *VendingMachine) *MaintenanceState {
+	return &MaintenanceState{vendingMachine: vm}
+}
+
+func (s *MaintenanceState) SelectProduct(slotCode string) error {
+	return fmt.Errorf("售货机维护中")
+}
+
+func (s *MaintenanceState) InsertCoin(coin Coin) error {
+	return fmt.Errorf("售货机维护中")
+}
+
+func (s *MaintenanceState) InsertNote(note Note) error {
+	return fmt.Errorf("售货机维护中")
+}
+
+func (s *MaintenanceState) PayCashless(token string) error {
+	return fmt.Errorf("售货机维护中")
+}
+
+func (s *MaintenanceState) DispenseProduct() error {
+	return fmt.Errorf("售货机维护中")
+}
+
+func (s *MaintenanceState) ReturnChange() Change {
+	return NewChange()
+}
@@ -1,47 +1,76 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"sync"
+	"time"
 )
 
-// VendingMachine 自动售货机 - 单例模式
+// VendingMachine 自动售货机
 type VendingMachine struct {
-	inventory       *Inventory
-	currentState    VendingMachineState
-	idleState       VendingMachineState
-	readyState      VendingMachineState
-	dispenseState   VendingMachineState
-	selectedProduct *Product
-	totalPayment    float64
-	collectedMoney  float64 // 收集的金钱总额
-	mu              sync.Mutex
-}
-
-var (
-	instance *VendingMachine
-	once     sync.Once
-)
+	id                string
+	inventory         *Inventory
+	currentState      VendingMachineState
+	idleState         VendingMachineState
+	readyState        VendingMachineState
+	authorizingState  *AuthorizingState
+	dispenseState     VendingMachineState
+	outOfServiceState VendingMachineState
+	maintenanceState  VendingMachineState
+	selectedSlot      *Slot // 选中货道的副本，价格以选择时为准
+	totalPayment      Money
+	collectedMoney    Money    // 收集的金钱总额
+	cashBox           *CashBox // 钱箱，找零从中取出硬币
+	escrow            Change   // 本次交易投入的硬币和纸币，取消时原样退还
+	pendingChange     Change   // 进入发放状态时确定的找零方案
+	lowFloat          Money    // 硬币余额低于该金额时只收准确金额
+	paymentProvider   PaymentProvider
+	authTimeout       time.Duration
+	cashlessAmount    Money  // 本次交易由非现金支付的金额
+	authID            string // 本次交易的非现金支付授权号
+	dispensed         bool   // 本次交易的产品是否已发放
+	captureFailed     bool   // 本次交易的非现金扣款是否失败
+	cashlessSales     Money  // 非现金支付的收入总额
+	motor             Motor
+	sensor            DropSensor
+	refund            Change // 上一笔出货失败的交易待退还的钱
+	consecutiveFaults int    // 连续出货故障次数
+	faults            []Fault
+	sales             []Sale
+	mu                sync.Mutex
+}
 
-// GetVendingMachine 获取售货机单例
-func GetVendingMachine() *VendingMachine {
-	once.Do(func() {
-		instance = &VendingMachine{
-			inventory: NewInventory(),
-		}
-		// 初始化各种状态
-		instance.idleState = NewIdleState(instance)
-		instance.readyState = NewReadyState(instance)
-		instance.dispenseState = NewDispenseState(instance)
-		instance.currentState = instance.idleState
-	})
-	return instance
+// NewVendingMachine 创建售货机，id 用于车队管理中区分各台售货机
+func NewVendingMachine(id string) *VendingMachine {
+	vm := &VendingMachine{
+		id:            id,
+		inventory:     NewInventory(),
+		cashBox:       NewCashBox(),
+		escrow:        NewChange(),
+		pendingChange: NewChange(),
+		lowFloat:      DefaultLowFloat,
+		authTimeout:   DefaultAuthTimeout,
+		refund:        NewChange(),
+	}
+	driver := NewSimulatedDriver()
+	vm.motor = driver
+	vm.sensor = driver
+	// 初始化各种状态
+	vm.idleState = NewIdleState(vm)
+	vm.readyState = NewReadyState(vm)
+	vm.authorizingState = NewAuthorizingState(vm)
+	vm.dispenseState = NewDispenseState(vm)
+	vm.outOfServiceState = NewOutOfServiceState(vm)
+	vm.maintenanceState = NewMaintenanceState(vm)
+	vm.currentState = vm.idleState
+	return vm
 }
 
-// ResetInstance 重置单例（仅用于测试）
-func ResetInstance() {
-	once = sync.Once{}
-	instance = nil
+// ID 返回售货机编号
+func (vm *VendingMachine) ID() string {
+	return vm.id
 }
 
 // SetState 设置当前状态
@@ -49,11 +78,28 @@ func (vm *VendingMachine) SetState(state VendingMachineState) {
 	vm.currentState = state
 }
 
-// SelectProduct 选择产品
-func (vm *VendingMachine) SelectProduct(product *Product) error {
+// SelectProduct 按货道编号选择产品，如 "A1"
+// 开始新交易时清除上一笔交易未取走的退款，退款只属于出货失败的那笔交易
+func (vm *VendingMachine) SelectProduct(slotCode string) error {
 	vm.mu.Lock()
 	defer vm.mu.Unlock()
-	return vm.currentState.SelectProduct(product)
+	if err := vm.currentState.SelectProduct(slotCode); err != nil {
+		return err
+	}
+	vm.refund = NewChange()
+	return nil
+}
+
+// availableSlot 返回有货货道的副本
+func (vm *VendingMachine) availableSlot(slotCode string) (*Slot, error) {
+	slot, err := vm.inventory.GetSlot(slotCode)
+	if err != nil {
+		return nil, err
+	}
+	if slot.Product == nil || slot.Quantity <= 0 {
+		return nil, fmt.Errorf("货道 %s 已售罄", slot.Code)
+	}
+	return &slot, nil
 }
 
 // InsertCoin 投入硬币
@@ -70,6 +116,49 @@ func (vm *VendingMachine) InsertNote(note Note) error {
 	return vm.currentState.InsertNote(note)
 }
 
+// PayCashless 使用银行卡或二维码支付，已投入的现金计入货款
+// 授权期间释放锁，售货机处于授权状态，超时视为授权失败
+func (vm *VendingMachine) PayCashless(token string) error {
+	vm.mu.Lock()
+	if err := vm.currentState.PayCashless(token); err != nil {
+		vm.mu.Unlock()
+		return err
+	}
+	provider, amount, timeout := vm.paymentProvider, vm.cashlessAmount, vm.authTimeout
+	vm.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	authID, err := provider.Authorize(ctx, token, amount)
+	if errors.Is(err, context.DeadlineExceeded) {
+		err = fmt.Errorf("授权超时")
+	}
+
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+	return vm.authorizingState.complete(authID, err)
+}
+
+// voidAuthorization 撤销本次交易尚未扣款的授权
+func (vm *VendingMachine) voidAuthorization() {
+	if vm.authID == "" || vm.dispensed {
+		return
+	}
+	vm.void(vm.authID)
+	vm.authID = ""
+	vm.cashlessAmount = 0
+}
+
+// void 撤销授权，失败时记录故障以便对账，不计入连续出货故障
+func (vm *VendingMachine) void(authID string) {
+	if err := vm.paymentProvider.Void(authID); err != nil {
+		fmt.Printf("撤销授权失败: %v\n", err)
+		vm.addFault(fmt.Errorf("撤销授权 %s 失败: %w", authID, err))
+		return
+	}
+	fmt.Printf("已撤销授权: %s\n", authID)
+}
+
 // DispenseProduct 发放产品
 func (vm *VendingMachine) DispenseProduct() error {
 	vm.mu.Lock()
@@ -77,19 +166,188 @@ func (vm *VendingMachine) DispenseProduct() error {
 	return vm.currentState.DispenseProduct()
 }
 
-// ReturnChange 返回找零
-func (vm *VendingMachine) ReturnChange() float64 {
+// ReturnChange 返回找零；上一笔交易出货失败时返回该交易的退款
+func (vm *VendingMachine) ReturnChange() Change {
 	vm.mu.Lock()
 	defer vm.mu.Unlock()
-	return vm.currentState.ReturnChange()
+	change := vm.currentState.ReturnChange()
+	if !vm.refund.IsEmpty() {
+		fmt.Printf("退还出货失败的货款: %s (%s)\n", vm.refund.Total(), vm.refund)
+		change.addChange(vm.refund)
+		vm.refund = NewChange()
+	}
+	return change
 }
 
-// AddProduct 补充产品库存
-func (vm *VendingMachine) AddProduct(product *Product, quantity int) {
+// vend 转动货道电机并确认产品落下，rotated 表示电机已转动，此时产品可能已经落下
+func (vm *VendingMachine) vend(slotCode string) (rotated bool, err error) {
+	if err := vm.motor.Rotate(slotCode); err != nil {
+		return false, err
+	}
+	if !vm.sensor.ProductDropped() {
+		return true, fmt.Errorf("货道 %s 未检测到产品落下", slotCode)
+	}
+	return true, nil
+}
+
+// recordFault 记录出货故障，计入连续故障次数
+func (vm *VendingMachine) recordFault(slotCode string, err error) {
+	vm.consecutiveFaults++
+	vm.faults = append(vm.faults, Fault{MachineID: vm.id, Time: time.Now(), SlotCode: slotCode, Reason: err.Error()})
+}
+
+// addFault 记录本次交易中出货以外的故障，货道为当前选中的货道
+func (vm *VendingMachine) addFault(err error) {
+	fault := Fault{MachineID: vm.id, Time: time.Now(), Reason: err.Error()}
+	if vm.selectedSlot != nil {
+		fault.SlotCode = vm.selectedSlot.Code
+	}
+	vm.faults = append(vm.faults, fault)
+}
+
+// refundTransaction 出货失败时撤销授权，投入的钱作为本次交易的退款由 ReturnChange 退还
+func (vm *VendingMachine) refundTransaction() {
+	vm.voidAuthorization()
+	vm.refund = vm.escrow
+	vm.escrow = NewChange()
+	vm.pendingChange = NewChange()
+	vm.totalPayment = 0
+	vm.cashlessAmount = 0
+	vm.selectedSlot = nil
+}
+
+// SetHardware 设置货道电机和掉落传感器
+func (vm *VendingMachine) SetHardware(motor Motor, sensor DropSensor) {
 	vm.mu.Lock()
 	defer vm.mu.Unlock()
-	vm.inventory.AddProduct(product, quantity)
-	fmt.Printf("补充产品: %s, 数量: %d\n", product.Name, quantity)
+	vm.motor = motor
+	vm.sensor = sensor
+}
+
+// EnterMaintenance 进入维护模式，交易进行中时拒绝
+func (vm *VendingMachine) EnterMaintenance() error {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+	if vm.currentState != vm.idleState && vm.currentState != vm.outOfServiceState {
+		return fmt.Errorf("交易进行中，无法进入维护模式")
+	}
+	vm.SetState(vm.maintenanceState)
+	fmt.Println("进入维护模式")
+	return nil
+}
+
+// ExitMaintenance 退出维护模式，清除连续故障计数并恢复服务
+func (vm *VendingMachine) ExitMaintenance() error {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+	if vm.currentState != vm.maintenanceState {
+		return fmt.Errorf("售货机不在维护模式")
+	}
+	vm.consecutiveFaults = 0
+	vm.SetState(vm.idleState)
+	fmt.Println("退出维护模式，恢复服务")
+	return nil
+}
+
+// IsOutOfService 连续故障暂停服务时返回true
+func (vm *VendingMachine) IsOutOfService() bool {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+	return vm.currentState == vm.outOfServiceState
+}
+
+// GetFaults 获取出货故障记录，加入车队后只包含尚未被收集的记录
+func (vm *VendingMachine) GetFaults() []Fault {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+	return append([]Fault(nil), vm.faults...)
+}
+
+// takeRecords 取走销售和故障记录，由车队收集后售货机上不再保留
+func (vm *VendingMachine) takeRecords() ([]Sale, []Fault) {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+	sales, faults := vm.sales, vm.faults
+	vm.sales, vm.faults = nil, nil
+	return sales, faults
+}
+
+// AssignSlot 将产品放入货道并设置售价，price 不大于0时使用产品的建议零售价
+func (vm *VendingMachine) AssignSlot(slotCode string, product *Product, price Money) error {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+	return vm.inventory.AssignProduct(slotCode, product, price)
+}
+
+// SetSlotPrice 设置货道售价
+func (vm *VendingMachine) SetSlotPrice(slotCode string, price Money) error {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+	return vm.inventory.SetPrice(slotCode, price)
+}
+
+// SetSlotCapacity 设置货道容量
+func (vm *VendingMachine) SetSlotCapacity(slotCode string, capacity int) error {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+	return vm.inventory.SetCapacity(slotCode, capacity)
+}
+
+// Restock 为货道补货，超过货道容量时拒绝
+func (vm *VendingMachine) Restock(slotCode string, quantity int) error {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+	if err := vm.inventory.Restock(slotCode, quantity); err != nil {
+		return err
+	}
+	slot, _ := vm.inventory.GetSlot(slotCode)
+	fmt.Printf("补充产品: %s %s, 数量: %d, 库存: %d/%d\n", slot.Code, slot.Product.Name, quantity, slot.Quantity, slot.Capacity)
+	return nil
+}
+
+// SetPaymentProvider 设置非现金支付通道
+func (vm *VendingMachine) SetPaymentProvider(provider PaymentProvider) {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+	vm.paymentProvider = provider
+}
+
+// SetAuthTimeout 设置非现金支付授权的超时时间
+func (vm *VendingMachine) SetAuthTimeout(timeout time.Duration) {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+	vm.authTimeout = timeout
+}
+
+// LoadCoins 向钱箱补充找零用的硬币
+func (vm *VendingMachine) LoadCoins(coin Coin, count int) {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+	vm.cashBox.AddCoins(coin, count)
+	fmt.Printf("补充硬币: %s x %d\n", coin.Value(), count)
+}
+
+// SetLowFloat 设置进入只收准确金额模式的硬币余额下限
+func (vm *VendingMachine) SetLowFloat(amount Money) {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+	vm.lowFloat = amount
+}
+
+// ExactChangeOnly 硬币余额过低、只收准确金额时返回true
+func (vm *VendingMachine) ExactChangeOnly() bool {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+	return vm.exactChangeOnly()
+}
+
+func (vm *VendingMachine) exactChangeOnly() bool {
+	return vm.cashBox.CoinTotal() < vm.lowFloat
+}
+
+// GetCashBox 获取钱箱
+func (vm *VendingMachine) GetCashBox() *CashBox {
+	return vm.cashBox
 }
 
 // GetInventory 获取库存
@@ -98,19 +356,33 @@ func (vm *VendingMachine) GetInventory() *Inventory {
 }
 
 // GetCollectedMoney 获取收集的金钱总额
-func (vm *VendingMachine) GetCollectedMoney() float64 {
+func (vm *VendingMachine) GetCollectedMoney() Money {
 	vm.mu.Lock()
 	defer vm.mu.Unlock()
 	return vm.collectedMoney
 }
 
+// GetCashlessSales 获取非现金支付的收入总额
+func (vm *VendingMachine) GetCashlessSales() Money {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+	return vm.cashlessSales
+}
+
+// GetSales 获取销售记录，加入车队后只包含尚未被收集的记录
+func (vm *VendingMachine) GetSales() []Sale {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+	return append([]Sale(nil), vm.sales...)
+}
+
 // CollectMoney 取出收集的金钱
-func (vm *VendingMachine) CollectMoney() float64 {
+func (vm *VendingMachine) CollectMoney() Money {
 	vm.mu.Lock()
 	defer vm.mu.Unlock()
 	collected := vm.collectedMoney
 	vm.collectedMoney = 0
-	fmt.Printf("取出金钱: %.2f元\n", collected)
+	fmt.Printf("取出金钱: %s\n", collected)
 	return collected
 }
 
@@ -120,10 +392,15 @@ func (vm *VendingMachine) DisplayProducts() {
 	defer vm.mu.Unlock()
 
 	fmt.Println("\n========== 产品列表 ==========")
-	products := vm.inventory.GetAllProducts()
-	for product, quantity := range products {
-		fmt.Printf("产品: %s, 价格: %.2f元, 库存: %d\n",
-			product.Name, product.Price, quantity)
+	if vm.exactChangeOnly() {
+		fmt.Println("零钱不足，只收准确金额")
+	}
+	for _, slot := range vm.inventory.GetAllSlots() {
+		if slot.Product == nil {
+			continue
+		}
+		fmt.Printf("%s: %s (%s), 价格: %s, 库存: %d/%d\n",
+			slot.Code, slot.Product.Name, slot.Product.SKU, slot.Price, slot.Quantity, slot.Capacity)
 	}
 	fmt.Println("==============================")
 }